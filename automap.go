@@ -0,0 +1,256 @@
+package gsonapi
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	gas "github.com/obieq/gas"
+	validations "github.com/obieq/goar-validations"
+)
+
+var (
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// modelValidator => implemented by models that embed goar-validations' Validation
+type modelValidator interface {
+	HasErrors() bool
+	ErrorMap() map[string]*validations.ValidationError
+}
+
+// AutoMapToModel => maps a resource's attributes to the model's fields w/ the same name, or the name
+// of the attribute's `model:"..."` tag, converting between null.* types, pointers and plain values
+// NOTE: attributes absent from the request document are skipped and explicitly null ones clear the model's
+// field (see Resource.Has), unless the resource was not unmarshalled by UnmarshalRequest,
+// in which case null attributes are skipped, i.e., they are treated as absent from a PATCH request;
+// it is the default mapping, which resources pass themselves to since Resource cannot reach the resource embedding it
+// EX: func (r *SedanResource) MapToModel(model interface{}) error { return AutoMapToModel(r, model) }
+func AutoMapToModel(resource Resourcer, model interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(resource))
+	mv := reflect.ValueOf(model)
+	if mv.Kind() != reflect.Ptr || mv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gsonapi: model must be a pointer to a struct, not %T", model)
+	}
+	mv = mv.Elem()
+
+	if id := mv.FieldByName("ID"); id.IsValid() && id.Kind() == reflect.String && resource.GetID() != "" {
+		id.SetString(resource.GetID())
+	}
+
+	var present map[string]bool
+	if tracker, ok := resource.(presenceTracker); ok {
+		present = tracker.presentKeys()
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		name, ok := modelFieldName(field)
+		if !ok {
+			continue
+		}
+		dst := mv.FieldByName(name)
+		if !dst.IsValid() || !dst.CanSet() {
+			continue
+		}
+
+		if present != nil {
+			explicitNull, ok := present[attributeName(field)]
+			if !ok {
+				continue
+			}
+			if explicitNull {
+				dst.Set(reflect.Zero(dst.Type()))
+				continue
+			}
+		}
+
+		if err := assignToModel(dst, rv.Field(i)); err != nil {
+			return fmt.Errorf("gsonapi: cannot map %s to %s: %s", field.Name, name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// AutoMapFromModel => maps a model's fields to the resource's attributes, see AutoMapToModel
+// EX: func (r *SedanResource) MapFromModel(model interface{}) error { return AutoMapFromModel(r, model) }
+// NOTE: a model w/ validation errors only has its errors mapped
+func AutoMapFromModel(resource Resourcer, model interface{}) error {
+	mv := reflect.ValueOf(model)
+	if mv.Kind() != reflect.Ptr {
+		// copy the model so that the pointer methods of an embedded Validation can be called
+		ptr := reflect.New(mv.Type())
+		ptr.Elem().Set(mv)
+		mv = ptr
+	}
+
+	if v, ok := mv.Interface().(modelValidator); ok && v.HasErrors() {
+		resource.SetErrors(v.ErrorMap())
+		return nil
+	}
+
+	mv = mv.Elem()
+	if mv.Kind() != reflect.Struct {
+		return fmt.Errorf("gsonapi: model must be a struct, not %T", model)
+	}
+	rv := reflect.Indirect(reflect.ValueOf(resource))
+
+	if id := mv.FieldByName("ID"); id.IsValid() && id.Kind() == reflect.String {
+		resource.SetID(id.String())
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		name, ok := modelFieldName(field)
+		if !ok {
+			continue
+		}
+		src := mv.FieldByName(name)
+		if !src.IsValid() || !rv.Field(i).CanSet() {
+			continue
+		}
+
+		if err := assignFromModel(rv.Field(i), src); err != nil {
+			return fmt.Errorf("gsonapi: cannot map %s to %s: %s", name, field.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// modelFieldName => the name of the model field a resource field maps to
+// NOTE: embedded structs, fields that are not attributes (jsonapi:"-") and relations
+// are skipped unless they have a model tag
+func modelFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("model")
+	switch {
+	case tag == "-" || field.PkgPath != "":
+		return "", false
+	case tag != "":
+		return tag, true
+	case field.Anonymous || field.Tag.Get("jsonapi") == "-" || isRelation(field):
+		return "", false
+	}
+	return field.Name, true
+}
+
+// assignToModel => converts a resource value to the type of the model's field
+func assignToModel(dst reflect.Value, src reflect.Value) error {
+	// null.* types
+	if src.Type().Implements(valuerType) {
+		value, err := src.Interface().(driver.Valuer).Value()
+		if err != nil || value == nil {
+			return err
+		}
+		return assignValue(dst, reflect.ValueOf(value))
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return nil
+		}
+		return assignToModel(dst, src.Elem())
+	case reflect.Slice:
+		if src.IsNil() {
+			return nil
+		}
+		if interfaces, ok := src.Interface().([]interface{}); ok && dst.Kind() == reflect.Slice {
+			switch dst.Type().Elem().Kind() {
+			case reflect.Int:
+				ints, err := gas.Interfaces(interfaces).ToInts()
+				if err == nil {
+					dst.Set(reflect.ValueOf(ints))
+				}
+				return err
+			case reflect.String:
+				strings, err := gas.Interfaces(interfaces).ToStrings()
+				if err == nil {
+					dst.Set(reflect.ValueOf(strings))
+				}
+				return err
+			}
+		}
+	}
+
+	return assignValue(dst, src)
+}
+
+// assignFromModel => converts a model value to the type of the resource's field
+func assignFromModel(dst reflect.Value, src reflect.Value) error {
+	// null.* types
+	if reflect.PtrTo(dst.Type()).Implements(scannerType) {
+		if src.Kind() == reflect.Ptr {
+			if src.IsNil() {
+				dst.Set(reflect.Zero(dst.Type()))
+				return nil
+			}
+			src = src.Elem()
+		}
+		return dst.Addr().Interface().(sql.Scanner).Scan(src.Interface())
+	}
+
+	if src.Kind() == reflect.Slice {
+		switch t := src.Interface().(type) {
+		case []int:
+			if dst.Type() == reflect.TypeOf([]interface{}{}) {
+				interfaces, err := gas.Ints(t).ToInterfaces()
+				dst.Set(reflect.ValueOf(interfaces))
+				return err
+			}
+		case []string:
+			if dst.Type() == reflect.TypeOf([]interface{}{}) {
+				interfaces, err := gas.Strings(t).ToInterfaces()
+				dst.Set(reflect.ValueOf(interfaces))
+				return err
+			}
+		}
+	}
+
+	return assignValue(dst, src)
+}
+
+// assignValue => assigns or converts the value, allocating a pointer or dereferencing one as needed
+func assignValue(dst reflect.Value, src reflect.Value) error {
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case dst.Kind() == reflect.Ptr:
+		if src.Kind() == reflect.Ptr && src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		ptr := reflect.New(dst.Type().Elem())
+		if err := assignValue(ptr.Elem(), src); err != nil {
+			return err
+		}
+		dst.Set(ptr)
+	case src.Kind() == reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		return assignValue(dst, src.Elem())
+	case src.Kind() == reflect.Slice && dst.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			elem := src.Index(i)
+			if elem.Kind() == reflect.Interface && !elem.IsNil() {
+				elem = elem.Elem()
+			}
+			if err := assignValue(slice.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+	case src.Type().ConvertibleTo(dst.Type()) && (src.Kind() == reflect.String) == (dst.Kind() == reflect.String):
+		// NOTE: numbers are not converted to strings (or vice versa), i.e., 65 never becomes "A"
+		dst.Set(src.Convert(dst.Type()))
+	default:
+		return fmt.Errorf("%s is not convertible to %s", src.Type(), dst.Type())
+	}
+	return nil
+}
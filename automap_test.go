@@ -0,0 +1,150 @@
+package gsonapi
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Sedan Resource (mapped to an AutomobileModel by the default mapping)
+type SedanResource struct {
+	Resource `jsonapi:"-"`
+	Year     null.Int      `json:"year,omitempty" jsonapi:"name=year"`
+	Brand    null.String   `json:"brand,omitempty" jsonapi:"name=brand" model:"Make"`
+	Style    null.String   `json:"body-style,omitempty" jsonapi:"name=body-style" model:"BodyStyle"`
+	Active   null.Bool     `json:"active,omitempty" jsonapi:"name=active"`
+	Ages     []interface{} `json:"ages,omitempty" jsonapi:"name=ages"`
+	Notes    string        `json:"notes,omitempty" jsonapi:"name=notes" model:"-"`
+}
+
+func (r SedanResource) GetName() string {
+	return "sedans"
+}
+
+func (r *SedanResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *SedanResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+var _ = Describe("Automap", func() {
+	Context("MapToModel", func() {
+		It("should map fields by name and by model tag", func() {
+			r := SedanResource{Year: null.IntFrom(2015), Brand: null.StringFrom("Mazda"),
+				Style: null.StringFrom("sedan"), Active: null.BoolFrom(true), Ages: []interface{}{18, 21}}
+			r.SetID("abc")
+
+			m := AutomobileModel{}
+			Ω(r.MapToModel(&m)).Should(Succeed())
+			Ω(m.ID).Should(Equal("abc"))
+			Ω(m.Year).Should(Equal(2015))
+			Ω(m.Make).Should(Equal("Mazda"))
+			Ω(*m.BodyStyle).Should(Equal("sedan"))
+			Ω(m.Active).Should(BeTrue())
+			Ω(m.Ages).Should(Equal([]int{18, 21}))
+		})
+
+		It("should skip null attributes, i.e., those absent from a PATCH request", func() {
+			style := "coupe"
+			m := AutomobileModel{ID: "abc", Year: 2010, Make: "Honda", BodyStyle: &style, Ages: []int{30}}
+
+			r := SedanResource{Year: null.IntFrom(2012)}
+			Ω(r.MapToModel(&m)).Should(Succeed())
+			Ω(m.ID).Should(Equal("abc"))
+			Ω(m.Year).Should(Equal(2012))
+			Ω(m.Make).Should(Equal("Honda"))
+			Ω(*m.BodyStyle).Should(Equal("coupe"))
+			Ω(m.Ages).Should(Equal([]int{30}))
+		})
+
+		It("should skip absent attributes and clear explicitly null ones of an unmarshalled resource", func() {
+			style := "coupe"
+			m := AutomobileModel{Year: 2010, Make: "Honda", BodyStyle: &style, Active: true}
+
+			r := SedanResource{}
+			body := []byte(`{"data":{"type":"sedans","id":"1","attributes":{"brand":"Mazda","body-style":null,"active":null}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(r.MapToModel(&m)).Should(Succeed())
+			Ω(m.Year).Should(Equal(2010))
+			Ω(m.Make).Should(Equal("Mazda"))
+			Ω(m.BodyStyle).Should(BeNil())
+			Ω(m.Active).Should(BeFalse())
+		})
+
+		It("should map plain fields", func() {
+			r := DriverResource{Name: "Bob", Age: 40, Active: true}
+			r.SetID("1")
+
+			m := DriverModel{}
+			Ω(r.MapToModel(&m)).Should(Succeed())
+			Ω(m).Should(Equal(DriverModel{ID: "1", Name: "Bob", Age: 40, Active: true}))
+		})
+
+		It("should map a copy of an unmarshalled resource", func() {
+			r := SedanResource{}
+			body := []byte(`{"data":{"type":"sedans","id":"1","attributes":{"brand":"Mazda"}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)).Should(BeEmpty())
+
+			copied := r
+			m := AutomobileModel{Year: 2010}
+			Ω(copied.MapToModel(&m)).Should(Succeed())
+			Ω(m.Make).Should(Equal("Mazda"))
+			Ω(m.Year).Should(Equal(2010))
+		})
+
+		It("should fail when the model is not a pointer to a struct", func() {
+			r := DriverResource{}
+			Ω(r.MapToModel(DriverModel{})).ShouldNot(Succeed())
+		})
+
+		It("should fail when a value cannot be converted", func() {
+			r := SedanResource{Ages: []interface{}{"eighteen"}}
+			Ω(r.MapToModel(&AutomobileModel{})).ShouldNot(Succeed())
+		})
+	})
+
+	Context("MapFromModel", func() {
+		It("should map fields by name and by model tag", func() {
+			style := "sedan"
+			m := AutomobileModel{ID: "abc", Year: 2015, Make: "Mazda", BodyStyle: &style, Active: true, Ages: []int{18, 21}}
+
+			r := SedanResource{Notes: "unchanged"}
+			Ω(r.MapFromModel(m)).Should(Succeed())
+			Ω(r.GetID()).Should(Equal("abc"))
+			Ω(r.Year).Should(Equal(null.IntFrom(2015)))
+			Ω(r.Brand).Should(Equal(null.StringFrom("Mazda")))
+			Ω(r.Style).Should(Equal(null.StringFrom("sedan")))
+			Ω(r.Active).Should(Equal(null.BoolFrom(true)))
+			Ω(r.Ages).Should(Equal([]interface{}{18, 21}))
+			Ω(r.Notes).Should(Equal("unchanged"))
+		})
+
+		It("should map a nil pointer to null", func() {
+			r := SedanResource{Style: null.StringFrom("coupe")}
+			Ω(r.MapFromModel(&AutomobileModel{})).Should(Succeed())
+			Ω(r.Style.Valid).Should(BeFalse())
+		})
+
+		It("should map plain fields", func() {
+			r := DriverResource{}
+			Ω(r.MapFromModel(DriverModel{ID: "1", Name: "Bob", Age: 40})).Should(Succeed())
+			Ω(r.GetID()).Should(Equal("1"))
+			Ω(r.Name).Should(Equal("Bob"))
+			Ω(r.Age).Should(Equal(40))
+		})
+
+		It("should only map the errors of an invalid model", func() {
+			m := AutomobileModel{ID: "abc", Year: 2020}
+			m.Required("make", m.Make)
+
+			r := SedanResource{}
+			Ω(r.MapFromModel(m)).Should(Succeed())
+			Ω(r.GetID()).Should(BeEmpty())
+			Ω(r.Errors()).Should(HaveLen(1))
+			Ω(r.Errors()[0].Source.Pointer).Should(Equal("/data/attributes/make"))
+		})
+
+	})
+})
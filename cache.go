@@ -0,0 +1,321 @@
+package gsonapi
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-martini/martini"
+)
+
+// CACHE_HEADER => reports whether a GET response was served from the ResponseCache, i.e., HIT or MISS
+const CACHE_HEADER = "X-Cache"
+
+// CACHED_HEADERS => the response headers that are cached along w/ the body
+// NOTE: per request and credential headers, e.g., X-Request-ID and Set-Cookie, are never replayed
+var CACHED_HEADERS = []string{"Content-Type", "Content-Language", "Content-Location", "Vary", "ETag", "Last-Modified", "Link"}
+
+// ResponseCache => an in-memory read-through cache of the 200 responses of GET requests,
+// e.g., the ones rendered by HandleIndexResponse and HandleGetResponse
+// NOTE: responses are keyed by tenant, caller, path, normalized query, Accept header and the Vary headers,
+// and are invalidated when a POST, PATCH or DELETE succeeds on the same or a related resource type
+// EX: a successful PATCH /v1/drivers/1 invalidates the cached drivers and automobiles (which relate to drivers)
+type ResponseCache struct {
+	TTL  time.Duration // how long a response is cached
+	Size int           // max number of cached responses, the least recently used ones are evicted first
+	Vary []string      // request headers that are part of the key besides Accept, EX: Accept-Language
+
+	// Shared => true if the callers of a tenant share the cached responses, i.e., the key has no caller part
+	// NOTE: only set it if every caller may see the same records and attributes, see Policy and FieldPermission;
+	// otherwise the Authorization and Cookie headers, and the Caller of a JSONApiServerInfo mapped before the cache,
+	// are part of the key, where the caller is identified by its GetID or String method, or else its json encoding
+	Shared bool
+
+	// Tenants => resolves the tenant part of the key, i.e., the resolver given to TenantHandler
+	// NOTE: not needed if TenantHandler is used before the cache
+	Tenants TenantResolver
+
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // most recently used first
+	generation int64      // incremented by each invalidation so that in-flight misses are not cached
+	stats      CacheStats
+	now        func() time.Time
+}
+
+// CacheStats => the ResponseCache's metrics
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Evictions     int64 // responses that were evicted because the cache was full
+	Invalidations int64 // responses that were removed because a related resource type was mutated
+	Entries       int
+}
+
+// cacheEntry => a cached response
+type cacheEntry struct {
+	key     string
+	types   []string // the resource types of the request path
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// NewResponseCache => an empty cache
+// EX: m.Use(NewResponseCache(time.Minute, 1000).Handler())
+func NewResponseCache(ttl time.Duration, size int) *ResponseCache {
+	return &ResponseCache{
+		TTL:     ttl,
+		Size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Handler => martini middleware that serves cached GET responses and invalidates them on mutations
+// NOTE: use it before render.Renderer so that the rendered responses are recorded;
+// responses are not shared by the callers of a tenant unless the cache is Shared
+func (c *ResponseCache) Handler() martini.Handler {
+	return func(ctx martini.Context, req *http.Request, res http.ResponseWriter) {
+		switch req.Method {
+		case "GET":
+			c.serve(ctx, req, res)
+		case "POST", "PATCH", "DELETE":
+			ctx.Next()
+			if w, ok := res.(martini.ResponseWriter); ok && w.Status() >= 200 && w.Status() < 300 {
+				c.Invalidate(pathTypes(req.URL.Path)...)
+			}
+		}
+	}
+}
+
+// Stats => a snapshot of the cache's metrics
+func (c *ResponseCache) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// Invalidate => removes the cached responses of the resource types and of the types related to them
+// NOTE: removes all of the cached responses if no resource type is given
+func (c *ResponseCache) Invalidate(resourceTypes ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	affected := affectedTypes(resourceTypes)
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*cacheEntry)
+		if len(affected) == 0 || len(entry.types) == 0 || intersects(entry.types, affected) {
+			c.remove(e)
+			c.stats.Invalidations++
+		}
+		e = next
+	}
+}
+
+// serve => writes the cached response, or records the response of the next handlers
+func (c *ResponseCache) serve(ctx martini.Context, req *http.Request, res http.ResponseWriter) {
+	key := c.key(ctx, req)
+
+	c.mutex.Lock()
+	entry := c.get(key)
+	if entry != nil {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	generation := c.generation
+	c.mutex.Unlock()
+
+	if entry != nil {
+		for name, values := range entry.header {
+			res.Header()[name] = values
+		}
+		res.Header().Set(CACHE_HEADER, "HIT")
+		res.WriteHeader(entry.status)
+		res.Write(entry.body)
+		return
+	}
+
+	res.Header().Set(CACHE_HEADER, "MISS")
+	recorder := &cacheRecorder{ResponseWriter: res}
+	ctx.MapTo(recorder, (*http.ResponseWriter)(nil))
+	ctx.Next()
+
+	if recorder.status != 200 {
+		return
+	}
+	header := http.Header{}
+	for _, name := range CACHED_HEADERS {
+		if values, ok := res.Header()[http.CanonicalHeaderKey(name)]; ok {
+			header[http.CanonicalHeaderKey(name)] = append([]string{}, values...)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation == c.generation {
+		c.set(&cacheEntry{
+			key:     key,
+			types:   pathTypes(req.URL.Path),
+			status:  recorder.status,
+			header:  header,
+			body:    recorder.body.Bytes(),
+			expires: c.now().Add(c.TTL),
+		})
+	}
+}
+
+// key => the request's tenant, caller, path, normalized query, Accept header and Vary headers
+func (c *ResponseCache) key(ctx martini.Context, req *http.Request) string {
+	tenant := ""
+	if v := ctx.Get(reflect.TypeOf(Tenant(""))); v.IsValid() {
+		tenant = v.String()
+	} else if c.Tenants != nil {
+		tenant, _ = c.Tenants.ResolveTenant(req)
+	}
+
+	parts := []string{tenant, req.URL.Path, req.URL.Query().Encode(), req.Header.Get("Accept")}
+	if !c.Shared {
+		caller := ""
+		if v := ctx.Get(reflect.TypeOf(JSONApiServerInfo{})); v.IsValid() {
+			caller = callerKey(v.Interface().(JSONApiServerInfo).Caller)
+		}
+		parts = append(parts, caller, req.Header.Get("Authorization"), req.Header.Get("Cookie"))
+	}
+	for _, name := range c.Vary {
+		parts = append(parts, req.Header.Get(name))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// callerKey => a stable identity of a caller, i.e., its type and GetID or String, or else its json encoding,
+// as opposed to the address of a pointer caller
+func callerKey(caller interface{}) string {
+	if caller == nil {
+		return ""
+	}
+	switch c := caller.(type) {
+	case string:
+		return c
+	case interface{ GetID() string }:
+		return fmt.Sprintf("%T:%s", c, c.GetID())
+	case fmt.Stringer:
+		return fmt.Sprintf("%T:%s", c, c.String())
+	}
+	if j, err := json.Marshal(caller); err == nil {
+		return fmt.Sprintf("%T:%s", caller, j)
+	}
+	return fmt.Sprintf("%#v", reflect.Indirect(reflect.ValueOf(caller)).Interface())
+}
+
+// get => the unexpired entry of the key, which becomes the most recently used one
+func (c *ResponseCache) get(key string) *cacheEntry {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := e.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(e)
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return entry
+}
+
+// set => caches the entry, evicting the least recently used ones if the cache is full
+func (c *ResponseCache) set(entry *cacheEntry) {
+	if e, ok := c.entries[entry.key]; ok {
+		c.remove(e)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.Size > 0 && c.lru.Len() > c.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *ResponseCache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// cacheRecorder => records the status and body written to the response
+type cacheRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *cacheRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *cacheRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = 200
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// pathTypes => the registered resource types in a request path
+// EX: /v1/automobiles/1/relationships/drivers => automobiles and drivers
+func pathTypes(path string) []string {
+	types := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if _, ok := registry[segment]; ok && !contains(types, segment) {
+			types = append(types, segment)
+		}
+	}
+	return types
+}
+
+// affectedTypes => the resource types, the types they relate to and the types that relate to them
+func affectedTypes(resourceTypes []string) []string {
+	affected := append([]string{}, resourceTypes...)
+	add := func(t string) {
+		if t != "" && !contains(affected, t) {
+			affected = append(affected, t)
+		}
+	}
+
+	for t, rt := range registry {
+		for _, relation := range GetRelations(reflect.New(rt).Interface()) {
+			related := append([]string{relation.Type}, relation.Types...)
+			if contains(resourceTypes, t) {
+				for _, r := range related {
+					add(r)
+				}
+			}
+			if intersects(related, resourceTypes) {
+				add(t)
+			}
+		}
+	}
+	return affected
+}
+
+func intersects(a []string, b []string) bool {
+	for _, s := range a {
+		if contains(b, s) {
+			return true
+		}
+	}
+	return false
+}
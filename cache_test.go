@@ -0,0 +1,234 @@
+package gsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"github.com/modocache/gory"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResponseCache", func() {
+	var (
+		server *martini.ClassicMartini
+		cache  *ResponseCache
+		now    time.Time
+		calls  int
+		status int
+	)
+
+	request := func(method string, path string, header map[string]string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		RegisterResource(AutomobileResource{})
+		RegisterResource(DriverResource{})
+		RegisterResource(InvoiceResource{})
+
+		now = time.Now()
+		cache = NewResponseCache(time.Minute, 10)
+		cache.now = func() time.Time { return now }
+		calls, status = 0, 200
+		driver := gory.Build("driverResource1").(*DriverResource)
+		cache.Tenants = HeaderTenantResolver{Header: "X-Tenant-ID"}
+
+		server = martini.Classic()
+		server.Use(cache.Handler())
+		server.Use(render.Renderer())
+		server.Use(TenantHandler(cache.Tenants))
+
+		get := func(r render.Render) {
+			calls++
+			if status != 200 {
+				HandleGetResponse(TEST_SERVER_INFO, NewError(status).Build(), nil, r)
+				return
+			}
+			HandleGetResponse(TEST_SERVER_INFO, nil, driver, r)
+		}
+		server.Get("/v1/drivers/:id", get)
+		server.Get("/v1/automobiles", get)
+		server.Get("/v1/invoices", get)
+		server.Patch("/v1/drivers/:id", func(r render.Render) {
+			if status != 200 {
+				HandlePatchResponse(TEST_SERVER_INFO, false, NewError(status).Build(), driver, r)
+				return
+			}
+			HandlePatchResponse(TEST_SERVER_INFO, true, nil, driver, r)
+		})
+	})
+
+	AfterEach(func() {
+		registry = map[string]reflect.Type{}
+	})
+
+	tenant := map[string]string{"X-Tenant-ID": "acme"}
+
+	It("should serve the cached response of a GET request", func() {
+		miss := request("GET", "/v1/drivers/1", tenant)
+		Ω(miss.Code).Should(Equal(200))
+		Ω(miss.Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+
+		hit := request("GET", "/v1/drivers/1", tenant)
+		Ω(hit.Code).Should(Equal(200))
+		Ω(hit.Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+		Ω(hit.Header().Get("Content-Type")).Should(Equal(GSON_API_RESPONSE_HEADER))
+		Ω(hit.Body.String()).Should(Equal(miss.Body.String()))
+
+		Ω(calls).Should(Equal(1))
+		Ω(cache.Stats()).Should(Equal(CacheStats{Hits: 1, Misses: 1, Entries: 1}))
+	})
+
+	It("should key the responses by tenant, normalized query, Accept and Vary headers", func() {
+		cache.Vary = []string{"Accept-Language"}
+
+		request("GET", "/v1/automobiles?sort=name&page[size]=2", tenant)
+		Ω(request("GET", "/v1/automobiles?page[size]=2&sort=name", tenant).Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+
+		Ω(request("GET", "/v1/automobiles?sort=name&page[size]=2", map[string]string{"X-Tenant-ID": "globex"}).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+		Ω(request("GET", "/v1/automobiles?sort=name&page[size]=2", map[string]string{"X-Tenant-ID": "acme", "Accept": JSONAPI_MEDIA_TYPE}).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+		Ω(request("GET", "/v1/automobiles?sort=name&page[size]=2", map[string]string{"X-Tenant-ID": "acme", "Accept-Language": "fr"}).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+		Ω(calls).Should(Equal(4))
+	})
+
+	It("should not share the responses of different callers unless the cache is shared", func() {
+		RegisterPolicy("automobiles", AutomobilePolicy{})
+		defer delete(policies, "automobiles")
+
+		callers := martini.Classic()
+		callers.Use(func(c martini.Context, req *http.Request) {
+			jasi := TEST_SERVER_INFO
+			jasi.Caller = req.Header.Get("X-Caller")
+			c.Map(jasi)
+		})
+		callers.Use(cache.Handler())
+		callers.Use(render.Renderer())
+		callers.Get("/v1/automobiles/:id", func(jasi JSONApiServerInfo, r render.Render) {
+			calls++
+			automobile := AutomobileResource{}
+			automobile.SetID("aaaa-1111-bbbb-2222")
+			HandleGetResponse(jasi, nil, automobile, r)
+		})
+		get := func(header map[string]string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/v1/automobiles/aaaa-1111-bbbb-2222", nil)
+			for name, value := range header {
+				req.Header.Set(name, value)
+			}
+			callers.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Ω(get(map[string]string{"X-Caller": "admin"}).Code).Should(Equal(200))
+		Ω(get(map[string]string{"X-Caller": "guest"}).Code).Should(Equal(404))
+		Ω(get(map[string]string{"X-Caller": "admin"}).Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+
+		request("GET", "/v1/drivers/1", map[string]string{"X-Tenant-ID": "acme", "Authorization": "Bearer admin"})
+		Ω(request("GET", "/v1/drivers/1", map[string]string{"X-Tenant-ID": "acme", "Authorization": "Bearer guest"}).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+
+		cache.Shared = true
+		request("GET", "/v1/drivers/1", map[string]string{"X-Tenant-ID": "acme", "Authorization": "Bearer admin"})
+		Ω(request("GET", "/v1/drivers/1", map[string]string{"X-Tenant-ID": "acme", "Authorization": "Bearer guest"}).Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+	})
+
+	It("should key the responses of pointer callers by their identity", func() {
+		type caller struct{ Name string }
+		Ω(callerKey(&caller{Name: "admin"})).Should(Equal(callerKey(&caller{Name: "admin"})))
+		Ω(callerKey(&caller{Name: "admin"})).ShouldNot(Equal(callerKey(&caller{Name: "guest"})))
+		Ω(callerKey(&DriverResource{Resource: Resource{ID: "1"}})).Should(Equal(callerKey(&DriverResource{Resource: Resource{ID: "1"}, Name: "Bob"})))
+		Ω(callerKey(&DriverResource{Resource: Resource{ID: "1"}})).ShouldNot(Equal(callerKey(&DriverResource{Resource: Resource{ID: "2"}})))
+		Ω(callerKey(nil)).Should(BeEmpty())
+	})
+
+	It("should not replay per request and credential headers", func() {
+		headers := martini.Classic()
+		headers.Use(RequestIDHandler())
+		headers.Use(cache.Handler())
+		headers.Use(render.Renderer())
+		headers.Get("/v1/drivers/:id", func(res http.ResponseWriter, r render.Render) {
+			calls++
+			res.Header().Set("Set-Cookie", "session=admin")
+			res.Header().Set("ETag", `"1"`)
+			HandleGetResponse(TEST_SERVER_INFO, nil, gory.Build("driverResource1").(*DriverResource), r)
+		})
+		get := func(id string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/v1/drivers/1", nil)
+			req.Header.Set(REQUEST_ID_HEADER, id)
+			headers.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Ω(get("first").Header().Get(REQUEST_ID_HEADER)).Should(Equal("first"))
+		hit := get("second")
+		Ω(hit.Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+		Ω(hit.Header().Get(REQUEST_ID_HEADER)).Should(Equal("second"))
+		Ω(hit.Header().Get("Set-Cookie")).Should(BeEmpty())
+		Ω(hit.Header().Get("ETag")).Should(Equal(`"1"`))
+		Ω(hit.Header().Get("Content-Type")).Should(Equal(GSON_API_RESPONSE_HEADER))
+		Ω(calls).Should(Equal(1))
+	})
+
+	It("should not cache error responses", func() {
+		status = 404
+		Ω(request("GET", "/v1/drivers/1", tenant).Code).Should(Equal(404))
+		Ω(request("GET", "/v1/drivers/1", tenant).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+		Ω(cache.Stats().Entries).Should(Equal(0))
+	})
+
+	It("should expire the responses after the TTL", func() {
+		request("GET", "/v1/drivers/1", tenant)
+		now = now.Add(59 * time.Second)
+		Ω(request("GET", "/v1/drivers/1", tenant).Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+		now = now.Add(time.Second)
+		Ω(request("GET", "/v1/drivers/1", tenant).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+	})
+
+	It("should evict the least recently used responses", func() {
+		cache.Size = 2
+		request("GET", "/v1/drivers/1", tenant)
+		request("GET", "/v1/drivers/2", tenant)
+		request("GET", "/v1/drivers/1", tenant)
+		request("GET", "/v1/drivers/3", tenant)
+
+		Ω(cache.Stats().Evictions).Should(Equal(int64(1)))
+		Ω(request("GET", "/v1/drivers/1", tenant).Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+		Ω(request("GET", "/v1/drivers/2", tenant).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+	})
+
+	It("should invalidate the responses of the same and related types when a mutation succeeds", func() {
+		request("GET", "/v1/drivers/1", tenant)
+		request("GET", "/v1/automobiles", tenant)
+		request("GET", "/v1/invoices", tenant)
+
+		status = 400
+		Ω(request("PATCH", "/v1/drivers/1", tenant).Code).Should(Equal(400))
+		Ω(cache.Stats().Entries).Should(Equal(3))
+
+		status = 200
+		Ω(request("PATCH", "/v1/drivers/1", tenant).Code).Should(Equal(200))
+		Ω(cache.Stats().Invalidations).Should(Equal(int64(2)))
+
+		Ω(request("GET", "/v1/drivers/1", tenant).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+		Ω(request("GET", "/v1/automobiles", tenant).Header().Get(CACHE_HEADER)).Should(Equal("MISS"))
+		Ω(request("GET", "/v1/invoices", tenant).Header().Get(CACHE_HEADER)).Should(Equal("HIT"))
+	})
+
+	It("should invalidate all of the responses", func() {
+		request("GET", "/v1/drivers/1", tenant)
+		request("GET", "/v1/invoices", tenant)
+		cache.Invalidate()
+		Ω(cache.Stats().Entries).Should(Equal(0))
+	})
+})
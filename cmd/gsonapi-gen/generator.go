@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// HEADER => marks the generated resources file, which is skipped when parsing the models
+const HEADER = "// Code generated by gsonapi-gen. DO NOT EDIT."
+
+// Options => where the resources are generated
+type Options struct {
+	Package string // the generated package's name, defaults to the model package
+	Import  string // the model package's import path, required when Package differs from it
+}
+
+// generator => writes the source of a single generated file
+type generator struct {
+	buf       bytes.Buffer
+	pkg       string            // the model package
+	opts      Options           //
+	models    map[string]*Model // keyed by name
+	qualifier string            // prefixes the model package's types, EX: "models."
+	imports   map[string]bool   // import specs, EX: `null "gopkg.in/guregu/null.v3"`
+}
+
+func newGenerator(pkg string, models []*Model, opts Options) (*generator, error) {
+	g := &generator{pkg: pkg, opts: opts, models: map[string]*Model{}, imports: map[string]bool{}}
+	for _, m := range models {
+		g.models[m.Name] = m
+	}
+
+	if g.opts.Package == "" {
+		g.opts.Package = pkg
+	}
+	if g.opts.Package != pkg {
+		if g.opts.Import == "" {
+			return nil, fmt.Errorf("the import path of package %s is required to generate package %s", pkg, g.opts.Package)
+		}
+		g.qualifier = pkg + "."
+		g.importPath(pkg, g.opts.Import)
+	}
+
+	return g, nil
+}
+
+// Generate => the gofmt-ed source of the resources of the models
+func Generate(pkg string, models []*Model, opts Options) ([]byte, error) {
+	g, err := newGenerator(pkg, models, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	g.importPath("fmt", "fmt")
+	g.importPath("gsonapi", "github.com/obieq/gson-api")
+	for _, m := range models {
+		g.resource(m)
+	}
+
+	return g.source(HEADER)
+}
+
+// GenerateTests => the gofmt-ed source of a ginkgo test skeleton for the resources of the models
+// NOTE: the skeleton is meant to be edited, so it does not have the generated file header
+func GenerateTests(pkg string, models []*Model, opts Options) ([]byte, error) {
+	g, err := newGenerator(pkg, models, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	g.importPath(".", "github.com/onsi/ginkgo")
+	g.importPath(".", "github.com/onsi/gomega")
+	for _, m := range models {
+		g.test(m)
+	}
+
+	return g.source("// Test skeleton generated by gsonapi-gen, which never overwrites it.\n" +
+		"// NOTE: requires a ginkgo suite (ginkgo bootstrap) in the package")
+}
+
+// source => the header, package clause, imports and body, gofmt-ed
+func (g *generator) source(header string) ([]byte, error) {
+	std := []string{}
+	other := []string{}
+	for spec := range g.imports {
+		path := spec[strings.Index(spec, `"`)+1:]
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "%s\n\npackage %s\n\nimport (\n", header, g.opts.Package)
+	for _, spec := range std {
+		fmt.Fprintln(&src, spec)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		fmt.Fprintln(&src)
+	}
+	for _, spec := range other {
+		fmt.Fprintln(&src, spec)
+	}
+	fmt.Fprintf(&src, ")\n%s", g.buf.String())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("cannot format the generated source: %s", err.Error())
+	}
+	return formatted, nil
+}
+
+// p => writes a line of source
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format+"\n", args...)
+}
+
+// importPath => adds an import, named when the name differs from the path's last element
+func (g *generator) importPath(name string, path string) {
+	// EX: gopkg.in/guregu/null.v3 is imported as null
+	if name == strings.Split(path[strings.LastIndex(path, "/")+1:], ".")[0] {
+		g.imports[`"`+path+`"`] = true
+	} else {
+		g.imports[name+` "`+path+`"`] = true
+	}
+}
+
+// resource => the resource struct and its methods
+func (g *generator) resource(m *Model) {
+	model := g.qualifier + m.Name
+	relations := m.relations()
+
+	g.p("")
+	g.p("// %s => generated from %s", m.Resource, model)
+	g.p("type %s struct {", m.Resource)
+	g.p("gsonapi.Resource `jsonapi:\"-\"`")
+	for _, f := range m.Fields {
+		switch f.Kind {
+		case kindNull:
+			g.importPath("null", "gopkg.in/guregu/null.v3")
+			g.p("%s %s `json:\"%s,omitempty\" jsonapi:\"name=%s\"`", f.Name, nullTypes[f.Base][0], f.Attribute, f.Attribute)
+		case kindInts, kindStrings:
+			g.p("%s []interface{} `json:\"%s,omitempty\" jsonapi:\"name=%s\"`", f.Name, f.Attribute, f.Attribute)
+		case kindRelation:
+			g.p("%s []%s `json:\"%s,omitempty\" jsonapi:\"-\"`", f.Name, resourceName(f.Base), f.Attribute)
+			g.p("%sIDs []string `json:\"-\" jsonapi:\"-\"`", f.Name)
+		case kindPlain:
+			for _, name := range f.packages {
+				if path, ok := m.imports[name]; ok {
+					g.importPath(name, path)
+				}
+			}
+			g.p("%s %s `json:\"%s,omitempty\" jsonapi:\"name=%s\"`", f.Name, typeString(f.expr, g.qualifier), f.Attribute, f.Attribute)
+		}
+	}
+	g.p("}")
+
+	g.p("")
+	g.p("// GetName => the resource's type")
+	g.p("func (r %s) GetName() string {", m.Resource)
+	g.p("return %q", m.Type)
+	g.p("}")
+
+	if len(relations) > 0 {
+		g.relationships(m, relations)
+	}
+	g.mapToModel(m)
+	g.mapFromModel(m)
+}
+
+// relationships => the api2go methods of the resource's to-many relationships
+func (g *generator) relationships(m *Model, relations []Field) {
+	g.importPath("errors", "errors")
+	g.importPath("jsonapi", "github.com/manyminds/api2go/jsonapi")
+
+	g.p("")
+	g.p("// GetReferences => satisfies the jsonapi.MarshalReferences interface")
+	g.p("func (r %s) GetReferences() []jsonapi.Reference {", m.Resource)
+	g.p("return []jsonapi.Reference{")
+	for _, f := range relations {
+		g.p("{Type: %q, Name: %q},", g.models[f.Base].Type, f.Attribute)
+	}
+	g.p("}")
+	g.p("}")
+
+	g.p("")
+	g.p("// GetReferencedIDs => satisfies the jsonapi.MarshalLinkedRelations interface")
+	g.p("func (r %s) GetReferencedIDs() []jsonapi.ReferenceID {", m.Resource)
+	g.p("result := []jsonapi.ReferenceID{}")
+	for _, f := range relations {
+		g.p("for _, v := range r.%s {", f.Name)
+		g.p("result = append(result, jsonapi.ReferenceID{ID: v.GetID(), Name: %q, Type: %q})", f.Attribute, g.models[f.Base].Type)
+		g.p("}")
+	}
+	g.p("return result")
+	g.p("}")
+
+	g.p("")
+	g.p("// GetReferencedStructs => satisfies the jsonapi.MarshalIncludedRelations interface")
+	g.p("func (r %s) GetReferencedStructs() []jsonapi.MarshalIdentifier {", m.Resource)
+	g.p("result := []jsonapi.MarshalIdentifier{}")
+	for _, f := range relations {
+		g.p("for _, v := range r.%s {", f.Name)
+		g.p("result = append(result, v)")
+		g.p("}")
+	}
+	g.p("return result")
+	g.p("}")
+
+	g.p("")
+	g.p("// SetToManyReferenceIDs => satisfies the jsonapi.UnmarshalToManyRelations interface")
+	g.p("func (r *%s) SetToManyReferenceIDs(name string, IDs []string) error {", m.Resource)
+	g.p("switch name {")
+	for _, f := range relations {
+		g.p("case %q:", f.Attribute)
+		g.p("r.%sIDs = IDs", f.Name)
+	}
+	g.p("default:")
+	g.p("return errors.New(\"There is no to-many relationship with the name \" + name)")
+	g.p("}")
+	g.p("return nil")
+	g.p("}")
+}
+
+// mapToModel => the resource's MapToModel method
+func (g *generator) mapToModel(m *Model) {
+	model := g.qualifier + m.Name
+
+	g.p("")
+	g.p("// MapToModel => maps the resource to a *%s", model)
+	g.p("// NOTE: null attributes are skipped, i.e., they are treated as absent from a PATCH request,")
+	g.p("// unless they are explicitly null in the request document, which clears them")
+	g.p("func (r *%s) MapToModel(model interface{}) (err error) {", m.Resource)
+	g.p("m, ok := model.(*%s)", model)
+	g.p("if !ok {")
+	g.p("return fmt.Errorf(\"expected *%s, not %%T\", model)", model)
+	g.p("}")
+	g.p("")
+	g.p("if r.ID != \"\" {")
+	g.p("m.ID = r.ID")
+	g.p("}")
+
+	for _, f := range m.Fields {
+		switch f.Kind {
+		case kindNull:
+			value := convert(f.Base, nullTypes[f.Base][2], "r."+f.Name+"."+nullTypes[f.Base][1])
+			if f.Pointer {
+				g.p("if r.IsExplicitNull(%q) {", f.Attribute)
+				g.p("m.%s = nil", f.Name)
+				g.p("} else if r.%s.Valid {", f.Name)
+				g.p("v := %s", value)
+				g.p("m.%s = &v", f.Name)
+			} else {
+				// NOTE: the value of an explicit null is the zero value
+				g.p("if r.%s.Valid || r.IsExplicitNull(%q) {", f.Name, f.Attribute)
+				g.p("m.%s = %s", f.Name, value)
+			}
+			g.p("}")
+		case kindInts, kindStrings:
+			g.importPath("gas", "github.com/obieq/gas")
+			to := "ToInts"
+			if f.Kind == kindStrings {
+				to = "ToStrings"
+			}
+			g.p("if r.IsExplicitNull(%q) {", f.Attribute)
+			g.p("m.%s = nil", f.Name)
+			g.p("} else if r.%s != nil {", f.Name)
+			g.p("if m.%s, err = gas.Interfaces(r.%s).%s(); err != nil {", f.Name, f.Name, to)
+			g.p("return err")
+			g.p("}")
+			g.p("}")
+		case kindRelation:
+			elem := g.qualifier + f.Base
+			literal := elem
+			if f.Pointer {
+				elem = "*" + elem
+				literal = "&" + literal
+			}
+			g.p("if r.%sIDs != nil {", f.Name)
+			g.p("m.%s = make([]%s, len(r.%sIDs))", f.Name, elem, f.Name)
+			g.p("for i, id := range r.%sIDs {", f.Name)
+			g.p("m.%s[i] = %s{ID: id}", f.Name, literal)
+			g.p("}")
+			g.p("}")
+		case kindPlain:
+			if f.nillable() {
+				g.p("if r.%s != nil || r.IsExplicitNull(%q) {", f.Name, f.Attribute)
+				g.p("m.%s = r.%s", f.Name, f.Name)
+				g.p("}")
+			} else {
+				g.p("m.%s = r.%s", f.Name, f.Name)
+			}
+		}
+	}
+
+	g.p("")
+	g.p("return nil")
+	g.p("}")
+}
+
+// mapFromModel => the resource's MapFromModel method
+func (g *generator) mapFromModel(m *Model) {
+	model := g.qualifier + m.Name
+
+	g.p("")
+	g.p("// MapFromModel => maps the model, a %s or a pointer to one, to the resource", model)
+	if m.Validated {
+		g.p("// NOTE: a model w/ validation errors only has its errors mapped")
+	}
+	g.p("func (r *%s) MapFromModel(model interface{}) (err error) {", m.Resource)
+	g.p("var m *%s", model)
+	g.p("switch t := model.(type) {")
+	g.p("case %s:", model)
+	g.p("m = &t")
+	g.p("case *%s:", model)
+	g.p("m = t")
+	g.p("}")
+	g.p("if m == nil {")
+	g.p("return fmt.Errorf(\"expected %s, not %%T\", model)", model)
+	g.p("}")
+	if m.Validated {
+		g.p("")
+		g.p("if m.HasErrors() {")
+		g.p("r.SetErrors(m.ErrorMap())")
+		g.p("return nil")
+		g.p("}")
+	}
+	g.p("")
+	g.p("r.ID = m.ID")
+
+	for _, f := range m.Fields {
+		switch f.Kind {
+		case kindNull:
+			from := nullTypes[f.Base][0] + "From"
+			if f.Pointer {
+				g.p("r.%s = %s{}", f.Name, nullTypes[f.Base][0])
+				g.p("if m.%s != nil {", f.Name)
+				g.p("r.%s = %s(%s)", f.Name, from, convert(nullTypes[f.Base][2], f.Base, "*m."+f.Name))
+				g.p("}")
+			} else {
+				g.p("r.%s = %s(%s)", f.Name, from, convert(nullTypes[f.Base][2], f.Base, "m."+f.Name))
+			}
+		case kindInts, kindStrings:
+			g.importPath("gas", "github.com/obieq/gas")
+			slice := "gas.Ints"
+			if f.Kind == kindStrings {
+				slice = "gas.Strings"
+			}
+			g.p("if r.%s, err = %s(m.%s).ToInterfaces(); err != nil {", f.Name, slice, f.Name)
+			g.p("return err")
+			g.p("}")
+		case kindRelation:
+			g.p("r.%s = make([]%s, len(m.%s))", f.Name, resourceName(f.Base), f.Name)
+			g.p("for i := range m.%s {", f.Name)
+			g.p("if err = r.%s[i].MapFromModel(m.%s[i]); err != nil {", f.Name, f.Name)
+			g.p("return err")
+			g.p("}")
+			g.p("}")
+		case kindPlain:
+			g.p("r.%s = m.%s", f.Name, f.Name)
+		}
+	}
+
+	g.p("")
+	g.p("return nil")
+	g.p("}")
+}
+
+// test => the ginkgo skeleton of a resource
+func (g *generator) test(m *Model) {
+	model := g.qualifier + m.Name
+
+	g.p("")
+	g.p("var _ = Describe(%q, func() {", m.Resource)
+	g.p("It(\"should map to and from the model\", func() {")
+	g.p("model := %s{ID: \"1\"}", model)
+	g.p("// TODO: set the model's fields")
+	g.p("")
+	g.p("r := %s{}", m.Resource)
+	g.p("Ω(r.MapFromModel(model)).Should(Succeed())")
+	g.p("Ω(r.GetID()).Should(Equal(\"1\"))")
+	g.p("Ω(r.GetName()).Should(Equal(%q))", m.Type)
+	g.p("")
+	g.p("mapped := %s{}", model)
+	g.p("Ω(r.MapToModel(&mapped)).Should(Succeed())")
+	g.p("Ω(mapped.ID).Should(Equal(model.ID))")
+	g.p("// TODO: verify the mapped fields")
+	g.p("})")
+	g.p("})")
+}
+
+// relations => the model's to-many relationships
+func (m *Model) relations() []Field {
+	relations := []Field{}
+	for _, f := range m.Fields {
+		if f.Kind == kindRelation {
+			relations = append(relations, f)
+		}
+	}
+	return relations
+}
+
+// nillable => true if the field's zero value is nil
+func (f Field) nillable() bool {
+	for _, prefix := range []string{"*", "[]", "map[", "interface{", "func(", "chan "} {
+		if strings.HasPrefix(f.Type, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// convert => the expression converted from type from to type to, if they differ
+func convert(to string, from string, expr string) string {
+	if to == from {
+		return expr
+	}
+	return to + "(" + expr + ")"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"go/build"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generator", func() {
+	var (
+		dir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gsonapi-gen")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		src, err := ioutil.ReadFile(filepath.Join("testdata", "models", "models.go"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ioutil.WriteFile(filepath.Join(dir, "models.go"), src, 0644)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	read := func(filename string) string {
+		src, err := ioutil.ReadFile(filepath.Join(dir, filename))
+		Ω(err).ShouldNot(HaveOccurred())
+		return string(src)
+	}
+
+	Context("Parse", func() {
+		It("should parse the annotated models in source order", func() {
+			pkg, models, err := Parse(dir)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pkg).Should(Equal("models"))
+			Ω(models).Should(HaveLen(3))
+
+			Ω(models[0].Name).Should(Equal("AutomobileModel"))
+			Ω(models[0].Resource).Should(Equal("AutomobileResource"))
+			Ω(models[0].Type).Should(Equal("automobiles"))
+			Ω(models[0].Validated).Should(BeTrue())
+			Ω(models[1].Type).Should(Equal("drivers"))
+			Ω(models[2].Type).Should(Equal("people"))
+			Ω(models[2].Validated).Should(BeFalse())
+		})
+
+		It("should classify the fields", func() {
+			_, models, err := Parse(dir)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			kinds := map[string]int{}
+			attributes := map[string]string{}
+			for _, f := range models[0].Fields {
+				kinds[f.Name] = f.Kind
+				attributes[f.Name] = f.Attribute
+			}
+
+			Ω(kinds).Should(Equal(map[string]int{"Year": kindNull, "Make": kindNull, "BodyStyle": kindNull, "Active": kindNull,
+				"Price": kindNull, "SoldAt": kindNull, "Ages": kindInts, "Tags": kindStrings, "Inspections": kindPlain,
+				"Dates": kindPlain, "Drivers": kindRelation, "Owners": kindRelation}))
+			Ω(attributes["BodyStyle"]).Should(Equal("body-style"))
+			Ω(attributes["Price"]).Should(Equal("price"))
+		})
+
+		It("should require an ID", func() {
+			src := "package models\n\n// gsonapi:resource\ntype WheelModel struct {\n\tSize int\n}\n"
+			Ω(ioutil.WriteFile(filepath.Join(dir, "wheel.go"), []byte(src), 0644)).Should(Succeed())
+
+			_, _, err := Parse(dir)
+			Ω(err).Should(MatchError("WheelModel: a resource model must have an ID string field"))
+		})
+	})
+
+	Context("Generate", func() {
+		It("should generate gofmt-clean resources", func() {
+			pkg, models, _ := Parse(dir)
+			src, err := Generate(pkg, models, Options{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			formatted, err := format.Source(src)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(src)).Should(Equal(string(formatted)))
+		})
+
+		It("should generate the resource struct and its methods", func() {
+			pkg, models, _ := Parse(dir)
+			src, _ := Generate(pkg, models, Options{})
+
+			Ω(string(src)).Should(HavePrefix(HEADER + "\n\npackage models\n"))
+			Ω(string(src)).Should(ContainSubstring("BodyStyle        null.String      `json:\"body-style,omitempty\" jsonapi:\"name=body-style\"`"))
+			Ω(string(src)).Should(ContainSubstring("DriversIDs       []string         `json:\"-\" jsonapi:\"-\"`"))
+			Ω(string(src)).Should(ContainSubstring("func (r OwnerResource) GetName() string {\n\treturn \"people\"\n}"))
+			Ω(string(src)).Should(ContainSubstring("{Type: \"people\", Name: \"owners\"},"))
+			Ω(string(src)).Should(ContainSubstring("func (r *AutomobileResource) SetToManyReferenceIDs(name string, IDs []string) error {"))
+			Ω(string(src)).Should(ContainSubstring("m.Price = float32(r.Price.Float64)"))
+			Ω(string(src)).Should(ContainSubstring("if m.Ages, err = gas.Interfaces(r.Ages).ToInts(); err != nil {"))
+			Ω(string(src)).Should(ContainSubstring("r.SetErrors(m.ErrorMap())"))
+			Ω(string(src)).Should(ContainSubstring("if r.IsExplicitNull(\"body-style\") {\n\t\tm.BodyStyle = nil\n\t} else if r.BodyStyle.Valid {"))
+			Ω(string(src)).Should(ContainSubstring("if r.Year.Valid || r.IsExplicitNull(\"year\") {"))
+		})
+
+		It("should qualify the models when generating another package", func() {
+			pkg, models, _ := Parse(dir)
+			src, err := Generate(pkg, models, Options{Package: "api", Import: "example.com/models"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(src)).Should(ContainSubstring("package api"))
+			Ω(string(src)).Should(ContainSubstring("\"example.com/models\""))
+			Ω(string(src)).Should(ContainSubstring("m, ok := model.(*models.AutomobileModel)"))
+
+			_, err = Generate(pkg, models, Options{Package: "api"})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should generate a gofmt-clean test skeleton", func() {
+			pkg, models, _ := Parse(dir)
+			src, err := GenerateTests(pkg, models, Options{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			formatted, _ := format.Source(src)
+			Ω(string(src)).Should(Equal(string(formatted)))
+			Ω(string(src)).Should(ContainSubstring("var _ = Describe(\"DriverResource\", func() {"))
+		})
+	})
+
+	Context("Run", func() {
+		It("should be idempotent", func() {
+			Ω(Run(dir, dir, Options{}, true)).Should(Succeed())
+			resources := read(RESOURCES_FILENAME)
+			tests := read(TESTS_FILENAME)
+
+			// the generated file must not be parsed as models on the next run
+			Ω(Run(dir, dir, Options{}, true)).Should(Succeed())
+			Ω(read(RESOURCES_FILENAME)).Should(Equal(resources))
+			Ω(read(TESTS_FILENAME)).Should(Equal(tests))
+		})
+
+		It("should never overwrite the test skeleton", func() {
+			Ω(ioutil.WriteFile(filepath.Join(dir, TESTS_FILENAME), []byte("package models\n"), 0644)).Should(Succeed())
+			Ω(Run(dir, dir, Options{}, true)).Should(Succeed())
+			Ω(read(TESTS_FILENAME)).Should(Equal("package models\n"))
+		})
+
+		It("should generate resources and tests that compile in the models' package and in another package", func() {
+			goTool, err := exec.LookPath("go")
+			if err != nil {
+				Skip("the go tool is not installed")
+			}
+
+			// NOTE: a GOPATH w/ the models, this repo as github.com/obieq/gson-api and its Godeps workspace
+			gopath, err := ioutil.TempDir("", "gsonapi-gen-gopath")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(gopath)
+			repo, _ := filepath.Abs(filepath.Join("..", ".."))
+			Ω(os.MkdirAll(filepath.Join(gopath, "src", "github.com", "obieq"), 0755)).Should(Succeed())
+			Ω(os.Symlink(repo, filepath.Join(gopath, "src", "github.com", "obieq", "gson-api"))).Should(Succeed())
+			models := filepath.Join(gopath, "src", "example.com", "models")
+			api := filepath.Join(gopath, "src", "example.com", "api")
+			Ω(os.MkdirAll(models, 0755)).Should(Succeed())
+			Ω(os.MkdirAll(api, 0755)).Should(Succeed())
+			Ω(os.Rename(filepath.Join(dir, "models.go"), filepath.Join(models, "models.go"))).Should(Succeed())
+
+			Ω(Run(models, models, Options{}, true)).Should(Succeed())
+			Ω(Run(models, api, Options{Package: "api", Import: "example.com/models"}, true)).Should(Succeed())
+
+			// NOTE: vet type checks the packages w/ their test files
+			cmd := exec.Command(goTool, "vet", "example.com/models", "example.com/api")
+			cmd.Env = append(os.Environ(), "GO111MODULE=off",
+				"GOPATH="+gopath+string(os.PathListSeparator)+filepath.Join(repo, "Godeps", "_workspace")+
+					string(os.PathListSeparator)+build.Default.GOPATH)
+			output, err := cmd.CombinedOutput()
+			Ω(err).ShouldNot(HaveOccurred(), string(output))
+		})
+
+		It("should fail when there are no annotated models", func() {
+			empty, _ := ioutil.TempDir("", "gsonapi-gen")
+			defer os.RemoveAll(empty)
+			Ω(ioutil.WriteFile(filepath.Join(empty, "models.go"), []byte("package models\n"), 0644)).Should(Succeed())
+
+			Ω(Run(empty, empty, Options{}, true)).ShouldNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,86 @@
+// gsonapi-gen => generates gsonapi resources from the annotated model structs of a package
+//
+// EX: annotate a model w/
+//
+//	// gsonapi:resource
+//	type AutomobileModel struct { ... }
+//
+// and run gsonapi-gen in the model's package (or add //go:generate gsonapi-gen to one of its files)
+// to generate AutomobileResource, its GetName, relationship and mapping methods in gsonapi_resources.go,
+// plus a ginkgo test skeleton in gsonapi_resources_test.go
+//
+// NOTE: re-running gsonapi-gen is idempotent; gsonapi_resources.go is only rewritten when it changes
+// and the test skeleton is never overwritten
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// output file names
+const (
+	RESOURCES_FILENAME = "gsonapi_resources.go"
+	TESTS_FILENAME     = "gsonapi_resources_test.go"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "the model package's directory")
+	out := flag.String("out", "", "the output directory (defaults to -dir)")
+	pkg := flag.String("package", "", "the output package's name (defaults to the model package's name)")
+	importPath := flag.String("import", "", "the model package's import path (required when -package differs)")
+	tests := flag.Bool("tests", true, "generate a ginkgo test skeleton unless one exists")
+	flag.Parse()
+
+	if *out == "" {
+		*out = *dir
+	}
+
+	if err := Run(*dir, *out, Options{Package: *pkg, Import: *importPath}, *tests); err != nil {
+		log.Fatalln("gsonapi-gen:", err)
+	}
+}
+
+// Run => generates the resources of the models in dir into out
+func Run(dir string, out string, opts Options, tests bool) error {
+	pkg, models, err := Parse(dir)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("no models annotated w/ %s in %s", ANNOTATION, dir)
+	}
+
+	src, err := Generate(pkg, models, opts)
+	if err != nil {
+		return err
+	}
+	if err := writeIfChanged(filepath.Join(out, RESOURCES_FILENAME), src); err != nil {
+		return err
+	}
+
+	if !tests {
+		return nil
+	}
+	filename := filepath.Join(out, TESTS_FILENAME)
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+	if src, err = GenerateTests(pkg, models, opts); err != nil {
+		return err
+	}
+	return writeIfChanged(filename, src)
+}
+
+// writeIfChanged => writes the file unless it already has the contents
+func writeIfChanged(filename string, contents []byte) error {
+	if existing, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(existing, contents) {
+		return nil
+	}
+	return ioutil.WriteFile(filename, contents, 0644)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/obieq/gas"
+)
+
+// ANNOTATION => marks a model struct for generation
+// EX: // gsonapi:resource or // gsonapi:resource name=cars
+const ANNOTATION = "gsonapi:resource"
+
+// kinds of model fields
+const (
+	kindNull     = iota // a primitive (or a pointer to one), mapped to a null.* type
+	kindInts            // []int, mapped to []interface{} via gas
+	kindStrings         // []string, mapped to []interface{} via gas
+	kindRelation        // a slice of annotated models, mapped to a to-many relationship
+	kindPlain           // any other type, copied as is
+)
+
+// nullTypes => primitive type => null type, the null type's value field and that field's type
+var nullTypes = map[string][3]string{
+	"int":       {"null.Int", "Int64", "int64"},
+	"int8":      {"null.Int", "Int64", "int64"},
+	"int16":     {"null.Int", "Int64", "int64"},
+	"int32":     {"null.Int", "Int64", "int64"},
+	"int64":     {"null.Int", "Int64", "int64"},
+	"uint":      {"null.Int", "Int64", "int64"},
+	"uint8":     {"null.Int", "Int64", "int64"},
+	"uint16":    {"null.Int", "Int64", "int64"},
+	"uint32":    {"null.Int", "Int64", "int64"},
+	"float32":   {"null.Float", "Float64", "float64"},
+	"float64":   {"null.Float", "Float64", "float64"},
+	"string":    {"null.String", "String", "string"},
+	"bool":      {"null.Bool", "Bool", "bool"},
+	"time.Time": {"null.Time", "Time", "time.Time"},
+}
+
+// Model => an annotated model struct
+type Model struct {
+	Name      string // EX: AutomobileModel
+	Resource  string // EX: AutomobileResource
+	Type      string // EX: automobiles
+	Validated bool   // true if the model embeds goar-validations' Validation
+	Fields    []Field
+	imports   map[string]string // the model file's imports, name => path
+}
+
+// Field => a model field that is mapped to a resource attribute or relationship
+type Field struct {
+	Name      string
+	Attribute string // EX: body-style
+	Kind      int
+	Type      string // the model field's type, EX: *string
+	Base      string // the primitive type of a kindNull field, or the model of a kindRelation field
+	Pointer   bool   // true if the model field (or relation element) is a pointer
+	expr      ast.Expr
+	packages  []string
+}
+
+// Parse => parses the package in dir and returns its annotated models in source order
+// NOTE: test files and generated files are skipped
+func Parse(dir string) (string, []*Model, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(filenames)
+
+	fset := token.NewFileSet()
+	pkg := ""
+	models := []*Model{}
+	specs := map[*Model]*ast.StructType{}
+
+	for _, filename := range filenames {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		if isGenerated(file) {
+			continue
+		}
+		if pkg == "" {
+			pkg = file.Name.Name
+		}
+
+		imports := fileImports(file)
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				args, ok := annotation(doc)
+				if !ok {
+					continue
+				}
+
+				m := &Model{Name: ts.Name.Name, Resource: resourceName(ts.Name.Name), imports: imports}
+				m.Type = args["name"]
+				if m.Type == "" {
+					m.Type = gas.String(gas.String(strings.TrimSuffix(m.Name, "Model")).Dasherize()).Pluralize()
+				}
+				models = append(models, m)
+				specs[m] = st
+			}
+		}
+	}
+
+	names := map[string]bool{}
+	for _, m := range models {
+		names[m.Name] = true
+	}
+	for _, m := range models {
+		if err := m.parseFields(specs[m], names); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return pkg, models, nil
+}
+
+// parseFields => classifies the model's fields; names are the names of all annotated models
+func (m *Model) parseFields(st *ast.StructType, names map[string]bool) error {
+	hasID := false
+
+	for _, f := range st.Fields.List {
+		typ := typeString(f.Type, "")
+
+		if len(f.Names) == 0 {
+			// embedded
+			if typ == "Validation" || strings.HasSuffix(typ, ".Validation") {
+				m.Validated = true
+			}
+			continue
+		}
+
+		tag := reflect.StructTag("")
+		if f.Tag != nil {
+			if s, err := strconv.Unquote(f.Tag.Value); err == nil {
+				tag = reflect.StructTag(s)
+			}
+		}
+		jsonName := strings.Split(tag.Get("json"), ",")[0]
+		if jsonName == "-" || tag.Get("gsonapi") == "-" {
+			continue
+		}
+
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+			if name.Name == "ID" {
+				if typ != "string" {
+					return fmt.Errorf("%s: ID must be a string, not %s", m.Name, typ)
+				}
+				hasID = true
+				continue
+			}
+
+			field := Field{Name: name.Name, Attribute: jsonName, Type: typ}
+			if field.Attribute == "" {
+				field.Attribute = gas.String(name.Name).Dasherize()
+			}
+			field.classify(names)
+			field.expr = f.Type
+			field.packages = packages(f.Type)
+			m.Fields = append(m.Fields, field)
+		}
+	}
+
+	if !hasID {
+		return fmt.Errorf("%s: a resource model must have an ID string field", m.Name)
+	}
+	return nil
+}
+
+// classify => sets the field's kind, base type and pointer flag
+func (f *Field) classify(names map[string]bool) {
+	base := strings.TrimPrefix(f.Type, "*")
+	if _, ok := nullTypes[base]; ok {
+		f.Kind, f.Base, f.Pointer = kindNull, base, base != f.Type
+		return
+	}
+
+	switch f.Type {
+	case "[]int":
+		f.Kind = kindInts
+		return
+	case "[]string":
+		f.Kind = kindStrings
+		return
+	}
+
+	if strings.HasPrefix(f.Type, "[]") {
+		elem := strings.TrimPrefix(f.Type, "[]")
+		if names[strings.TrimPrefix(elem, "*")] {
+			f.Kind, f.Base, f.Pointer = kindRelation, strings.TrimPrefix(elem, "*"), strings.HasPrefix(elem, "*")
+			return
+		}
+	}
+
+	f.Kind = kindPlain
+}
+
+// annotation => the arguments of the comment's gsonapi:resource annotation, if any
+func annotation(doc *ast.CommentGroup) (map[string]string, bool) {
+	if doc == nil {
+		return nil, false
+	}
+
+	for _, c := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(text, ANNOTATION) {
+			continue
+		}
+
+		args := map[string]string{}
+		for _, arg := range strings.Fields(strings.TrimPrefix(text, ANNOTATION)) {
+			kv := strings.SplitN(arg, "=", 2)
+			if len(kv) == 2 {
+				args[kv[0]] = kv[1]
+			}
+		}
+		return args, true
+	}
+
+	return nil, false
+}
+
+// isGenerated => true if the file has a "Code generated ... DO NOT EDIT." comment
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "// Code generated ") && strings.HasSuffix(c.Text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resourceName => EX: AutomobileModel => AutomobileResource
+func resourceName(model string) string {
+	return strings.TrimSuffix(model, "Model") + "Resource"
+}
+
+// fileImports => the file's imports keyed by name
+func fileImports(file *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		// EX: gopkg.in/guregu/null.v3 => null
+		name := strings.Split(path[strings.LastIndex(path, "/")+1:], ".")[0]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+	return imports
+}
+
+// packages => the names of the packages referenced by a type expression
+func packages(expr ast.Expr) []string {
+	names := []string{}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				names = append(names, ident.Name)
+			}
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// typeString => the source representation of a type expression, whose exported identifiers
+// (i.e., the types declared in the model package) are qualified by qualifier, EX: "models."
+func typeString(expr ast.Expr, qualifier string) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if t.IsExported() {
+			return qualifier + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeString(t.X, "") + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + typeString(t.X, qualifier)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + typeString(t.Elt, qualifier)
+		}
+	case *ast.MapType:
+		return "map[" + typeString(t.Key, qualifier) + "]" + typeString(t.Value, qualifier)
+	}
+
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	validations "github.com/obieq/goar-validations"
+)
+
+// AutomobileModel => a car
+// gsonapi:resource
+type AutomobileModel struct {
+	validations.Validation
+	ID          string  `json:"id"`
+	Year        int     `json:"year,omitempty"`
+	Make        string  `json:"make,omitempty"`
+	BodyStyle   *string `json:"body-style,omitempty"`
+	Active      bool    `json:"active,omitempty"`
+	Price       float32
+	SoldAt      *time.Time    `json:"sold-at"`
+	Ages        []int         `json:"ages,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Inspections []interface{} `json:"inspections,omitempty"`
+	Dates       []time.Time   `json:"dates"`
+	Drivers     []DriverModel `json:"drivers,omitempty"`
+	Owners      []*OwnerModel `json:"owners"`
+	internal    int
+}
+
+//gsonapi:resource
+type DriverModel struct {
+	validations.Validation
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Age  int    `json:"age,omitempty"`
+}
+
+// gsonapi:resource name=people
+type OwnerModel struct {
+	ID   string
+	Name string
+}
+
+type NotAResource struct{ ID int }
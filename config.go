@@ -2,10 +2,19 @@ package gsonapi
 
 import (
 	"log"
+	"os"
+	"strings"
 
 	"github.com/obieq/gas"
+	"github.com/spf13/viper"
 )
 
+// DEFAULT_PAGE_SIZE_LIMIT => used when config.json does not contain a page_size_limit
+const DEFAULT_PAGE_SIZE_LIMIT = 100
+
+// DEFAULT_OPENAPI_PATH => used when config.json does not contain an openapi_path
+const DEFAULT_OPENAPI_PATH = "/openapi.json"
+
 // Config => global variable that stores the config values
 var Config *config
 
@@ -16,7 +25,30 @@ func init() {
 // config => stores a map[string]string of all values parse from an .env file
 type config struct {
 	gas.Config
-	URL string
+	URL         string
+	MaxPageSize int
+	OpenAPIPath string // where ServeOpenAPI serves the OpenAPI document
+	Tenants     map[string]TenantConfig
+	Webhooks    []WebhookConfig // see NewWebhookDispatcher
+}
+
+// TenantConfig => tenant specific overrides, parsed from config.json's "tenants" object
+// EX: "tenants": {"acme": {"page_size_limit": 25}}
+type TenantConfig struct {
+	PageSizeLimit int `mapstructure:"page_size_limit"`
+}
+
+// WebhookConfig => a webhook subscription, parsed from config.json's "webhooks" array, see WebhookResource
+// EX: "webhooks": [{"id": "audit", "url": "https://audit.example.com/events", "secret": "ENV[AUDIT_WEBHOOK_SECRET]",
+// "types": ["automobiles"]}]
+// NOTE: the url and secret may be read from the environment, like the other config values, so that secrets are not committed
+type WebhookConfig struct {
+	ID      string   `mapstructure:"id"`
+	URL     string   `mapstructure:"url"`
+	Secret  string   `mapstructure:"secret"`
+	Types   []string `mapstructure:"types"`
+	Actions []string `mapstructure:"actions"`
+	Tenant  string   `mapstructure:"tenant"` // only the tenant's events are sent, or those w/o a tenant if blank
 }
 
 func newConfig() *config {
@@ -34,9 +66,54 @@ func (c *config) ParseConfigFile(configFileName string) (err error) {
 	// get api base url
 	c.URL = gas.GetString("gson_api_url")
 
+	// get the default and tenant specific page size limits
+	if c.MaxPageSize = gas.GetInt("page_size_limit"); c.MaxPageSize == 0 {
+		c.MaxPageSize = DEFAULT_PAGE_SIZE_LIMIT
+	}
+	// get the path of the OpenAPI document
+	if c.OpenAPIPath = gas.GetString("openapi_path"); c.OpenAPIPath == "" {
+		c.OpenAPIPath = DEFAULT_OPENAPI_PATH
+	}
+
+	c.Tenants = map[string]TenantConfig{}
+	if err == nil {
+		err = viper.UnmarshalKey("tenants", &c.Tenants)
+	}
+
+	// get the configured webhooks
+	c.Webhooks = []WebhookConfig{}
+	if err == nil {
+		err = viper.UnmarshalKey("webhooks", &c.Webhooks)
+	}
+	for i, w := range c.Webhooks {
+		c.Webhooks[i].URL = envValue(w.URL, "webhooks."+w.ID+".url")
+		c.Webhooks[i].Secret = envValue(w.Secret, "webhooks."+w.ID+".secret")
+	}
+
 	return err
 }
 
+// PageSizeLimit => the tenant's page size limit, or the default limit if the tenant has no override
+func (c *config) PageSizeLimit(tenant string) int {
+	if t, ok := c.Tenants[tenant]; ok && t.PageSizeLimit > 0 {
+		return t.PageSizeLimit
+	}
+	return c.MaxPageSize
+}
+
+// envValue => the value of the environment variable of an ENV[NAME] config value, or the config value itself
+// NOTE: panics if the environment variable is blank, like gas.GetString
+func envValue(value string, path string) string {
+	if !strings.HasPrefix(value, "ENV[") || !strings.HasSuffix(value, "]") {
+		return value
+	}
+	env := os.Getenv(value[4 : len(value)-1])
+	if env == "" {
+		log.Panicln("env value cannot be blank:", path)
+	}
+	return env
+}
+
 func (c *config) Validate() {
 	if c.URL == "" {
 		log.Panicln("gson api config error: URL cannot be blank")
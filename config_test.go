@@ -2,6 +2,7 @@ package gsonapi
 
 import (
 	"log"
+	"os"
 
 	"github.com/obieq/gas"
 	. "github.com/onsi/ginkgo"
@@ -12,6 +13,43 @@ var _ = Describe("Config", func() {
 	BeforeEach(func() {
 	})
 
+	Context("Page Size Limits", func() {
+		It("should load the default and tenant specific page size limits", func() {
+			c := newConfig()
+			Ω(c.PageSizeLimit("")).Should(Equal(100))
+			Ω(c.PageSizeLimit("globex")).Should(Equal(100))
+			Ω(c.PageSizeLimit("acme")).Should(Equal(25))
+		})
+	})
+
+	Context("OpenAPI", func() {
+		It("should load the path of the OpenAPI document", func() {
+			Ω(newConfig().OpenAPIPath).Should(Equal("/v1/openapi.json"))
+		})
+	})
+
+	Context("Webhooks", func() {
+		It("should load the configured webhooks w/ their secrets from the environment", func() {
+			wd, _ := os.Getwd()
+			defer func() {
+				os.Chdir(wd)
+				os.Unsetenv("GSON_API_TEST_WEBHOOK_SECRET")
+				newConfig()
+			}()
+			Ω(newConfig().Webhooks).Should(BeEmpty())
+
+			os.Setenv("GSON_API_TEST_WEBHOOK_SECRET", "s3cr3t")
+			Ω(os.Chdir("testdata/webhooks")).Should(Succeed())
+			Ω(newConfig().Webhooks).Should(Equal([]WebhookConfig{{
+				ID:      "audit",
+				URL:     "https://audit.example.com/events",
+				Secret:  "s3cr3t",
+				Types:   []string{"automobiles"},
+				Actions: []string{"create", "delete"},
+			}}))
+		})
+	})
+
 	Context("Errors", func() {
 		It("should panic when loading the config.json file fails", func() {
 			defer func() {
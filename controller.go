@@ -2,6 +2,7 @@ package gsonapi
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/manyminds/api2go/jsonapi"
 	"github.com/martini-contrib/render"
@@ -10,21 +11,33 @@ import (
 const GSON_API_RESPONSE_HEADER = "application/vnd.api+json; charset=UTF-8"
 
 // JSONApiServerInfo => contains necessary info for building an api's route
+// as well as info about the current request's caller
 type JSONApiServerInfo struct {
-	BaseURL string
-	Prefix  string
+	BaseURL   string
+	Prefix    string
+	Caller    interface{} // the authenticated caller, passed to the resource type's Policy
+	Tenant    string      // the request's tenant, see TenantHandler
+	Version   *Version    // the request's version, see VersionHandler
+	Loader    *Loader     // the request's loader, see LoaderHandler
+	RequestID string      // the request's id, see RequestIDHandler
+
+	// ListDeleted => true if the index includes soft deleted records, i.e., filter[deleted]=true, see ScopeQuery
+	ListDeleted bool
 }
 
 // GetBaseURL => api routes base url
 // EX: https://test.myapi.com/.....
+// NOTE: a {tenant} placeholder is replaced w/ the request's tenant, EX: https://{tenant}.myapi.com
 func (jasi JSONApiServerInfo) GetBaseURL() string {
-	return jasi.BaseURL
+	return withTenant(jasi.BaseURL, jasi.Tenant)
 }
 
 // GetPrefix => api route's prefix
 // EX: https://xxxxx.com/v1/xxxxx (v1 is the prefix)
+// NOTE: a {tenant} placeholder is replaced w/ the request's tenant, EX: {tenant}/v1
+// and a {version} placeholder is replaced w/ the request's version, EX: {version} => v2
 func (jasi JSONApiServerInfo) GetPrefix() string {
-	return jasi.Prefix
+	return strings.Replace(withTenant(jasi.Prefix, jasi.Tenant), VERSION_PLACEHOLDER, jasi.Version.name(), -1)
 }
 
 // JSON => wraps the martini contrib Render method in order to set the
@@ -34,97 +47,128 @@ func JSON(r render.Render, status int, v interface{}) {
 	r.Header().Set("Content-Type", GSON_API_RESPONSE_HEADER)
 }
 
+// HandleIndexResponse => authorizes and renders a list of resources, e.g., the ones of FindAllWithScope
+// NOTE: the records must be fetched w/ a query restricted by ScopeQuery, since the records that are checked
+// against the tenant, the Policy and its Scope filters here are removed from a page that was already fetched
 func HandleIndexResponse(jasi JSONApiServerInfo, err *JsonApiError, result interface{}, r render.Render) {
-	var j []byte
-	var jsonError error
-	var response interface{}
+	if err == nil {
+		result, err = authorizeList(jasi, result)
+	}
+	if err == nil && jasi.Loader != nil {
+		err = jasi.Loader.Populate(jasi, result)
+	}
 
 	if err == nil {
+		result = afterLoad(jasi, result)
 		// JSON(r,200, map[string]interface{}{"links": link, "data": result}) // TODO: return links before data
-		if j, jsonError = jsonapi.MarshalToJSONWithURLs(result, jasi); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		if jsonError = json.Unmarshal(j, &response); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		JSON(r, 200, response)
+		renderData(jasi, 200, result, r)
 	} else {
-		JSON(r, 404, map[string]interface{}{"errors": err})
+		renderError(404, err, r)
 	}
 }
 
 func HandleGetResponse(jasi JSONApiServerInfo, err *JsonApiError, result interface{}, r render.Render) {
-	var j []byte
-	var jsonError error
-	var response interface{}
+	if record, ok := result.(jsonapi.MarshalIdentifier); ok && err == nil {
+		err = AuthorizeResource(jasi, ActionRead, record)
+	}
+	if err == nil && jasi.Loader != nil {
+		err = jasi.Loader.Populate(jasi, result)
+	}
 
 	if err == nil {
-		if j, jsonError = jsonapi.MarshalToJSONWithURLs(result, jasi); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		if jsonError = json.Unmarshal(j, &response); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		JSON(r, 200, response)
+		result = afterLoad(jasi, result)
+		renderData(jasi, 200, result, r)
 	} else {
-		JSON(r, 404, map[string]interface{}{"errors": err})
+		renderError(404, err, r)
 	}
 }
 
 // HandlePostResponse => formats appropriate JSON response based on success vs. error
+// NOTE: create the resource w/ CreateWithHooks, which authorizes the caller
 func HandlePostResponse(jasi JSONApiServerInfo, success bool, err *JsonApiError, resource JsonApiResourcer, r render.Render) {
 	// TODO: return 404 if resource not found
-	var j []byte
-	var jsonError error
-	var response interface{}
-
 	if success {
 		// TODO: retrieve from the database instead of re-using instance
 		// TODO: implement via Api2Go => r.Header().Set("Location", LinkSelfInstance(resource))
-		if j, jsonError = jsonapi.MarshalToJSONWithURLs(resource, jasi); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		if jsonError = json.Unmarshal(j, &response); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		JSON(r, 201, response)
+		renderData(jasi, 201, resource, r)
 	} else if err != nil {
-		// TODO: how do I parse the status code?
-		JSON(r, 400, map[string]interface{}{"errors": err})
+		renderError(400, err, r)
 	} else {
-		JSON(r, 422, map[string]interface{}{"errors": resource.Errors()})
+		JSON(r, 422, map[string]interface{}{"errors": ResourceErrors(resource)})
 	}
 }
 
 // HandlePatchResponse => formats appropriate JSON response based on success vs. error
+// NOTE: update the resource w/ UpdateWithHooks, which authorizes the caller against the stored record
 func HandlePatchResponse(jasi JSONApiServerInfo, success bool, err *JsonApiError, resource JsonApiResourcer, r render.Render) {
-	var j []byte
-	var jsonError error
-	var response interface{}
-
 	if success {
 		// TODO: retrieve from the database instead of re-using instance
 		// TODO: implement via Api2Go => r.Header().Set("Location", LinkSelfInstance(resource))
-		if j, jsonError = jsonapi.MarshalToJSONWithURLs(resource, jasi); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		if jsonError = json.Unmarshal(j, &response); jsonError != nil {
-			JSON(r, 400, map[string]interface{}{"errors": jsonError})
-		}
-		JSON(r, 200, response) // given that updated-at is set, a 200 w/ content must be returned
+		renderData(jasi, 200, resource, r) // given that updated-at is set, a 200 w/ content must be returned
 	} else if err != nil {
-		// TODO: how do I parse the status code?
-		JSON(r, 400, map[string]interface{}{"errors": err})
+		renderError(400, err, r)
 		//JSON(r,412, map[string]interface{}{"errors": err})
 	} else {
-		JSON(r, 422, map[string]interface{}{"errors": resource.Errors()})
+		JSON(r, 422, map[string]interface{}{"errors": ResourceErrors(resource)})
 	}
 }
 
+// HandleDeleteResponse => formats appropriate JSON response based on success vs. error
+// NOTE: delete the resource w/ DeleteWithHooks, which authorizes the caller; a soft deletable resource (see SoftDeleter)
+// is marked deleted-at and saved by the app rather than removed, EX:
+// DeleteWithHooks(jasi, &r, &m, func() *JsonApiError { SoftDelete(&r); return save(r) }) => HandleDeleteResponse
 func HandleDeleteResponse(err *JsonApiError, r render.Render) {
 	if err == nil {
 		JSON(r, 204, map[string]interface{}{})
 	} else {
-		JSON(r, 400, map[string]interface{}{"errors": err})
+		renderError(400, err, r)
+	}
+}
+
+// HandleErrorsResponse => renders a list of errors, e.g., the ones returned by UnmarshalRequest
+// NOTE: the status is shared by all of the errors, or the most general one of their classes (400 or 500)
+func HandleErrorsResponse(errors []JsonApiError, r render.Render) {
+	status := 0
+	for _, e := range errors {
+		s := e.StatusCode(400)
+		if status == 0 || status == s {
+			status = s
+		} else if status >= 500 || s >= 500 {
+			status = 500
+		} else {
+			status = 400
+		}
+	}
+	if status == 0 {
+		status = 400
+	}
+
+	JSON(r, status, map[string]interface{}{"errors": errors})
+}
+
+// renderData => marshals the data to jsonapi format and renders it w/ the given status
+func renderData(jasi JSONApiServerInfo, status int, data interface{}, r render.Render) {
+	var response interface{}
+
+	j, jsonError := jsonapi.MarshalToJSONWithURLs(data, jasi)
+	if jsonError == nil {
+		jsonError = json.Unmarshal(j, &response)
+	}
+	if jsonError == nil {
+		jsonError = renderRelationships(jasi, data, response)
+	}
+
+	if jsonError != nil {
+		renderError(400, NewError(400).Detail(jsonError.Error()).Build(), r)
+	} else {
+		stripHiddenAttributes(jasi, data, response)
+		jasi.Version.transformOutgoing(response)
+		formatKeys(data, response)
+		JSON(r, status, response)
 	}
 }
+
+// renderError => renders the error using its status, or the fallback status if it has none
+func renderError(fallback int, err *JsonApiError, r render.Render) {
+	JSON(r, err.StatusCode(fallback), map[string]interface{}{"errors": []JsonApiError{*err}})
+}
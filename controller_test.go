@@ -10,7 +10,6 @@ import (
 	"net/http/httptest"
 
 	"github.com/go-martini/martini"
-	"github.com/manyminds/api2go/jsonapi"
 	"github.com/martini-contrib/render"
 	"github.com/modocache/gory"
 	. "github.com/onsi/ginkgo"
@@ -56,9 +55,11 @@ func HandleGetAutomobile(r render.Render, err error) {
 }
 
 // func HandleCreateAutomobile(request JsonApiPayload, r render.Render, success bool, err error) {
-func HandleCreateAutomobile(request *http.Request, r render.Render, success bool, err error) {
+func HandleCreateAutomobile(request *http.Request, loader *Loader, r render.Render, success bool, err error) {
 	var resource AutomobileResource
 	var jsonApiError *JsonApiError
+	jasi := TEST_SERVER_INFO
+	jasi.Loader = loader
 
 	if success {
 		// map the resource to the model
@@ -70,8 +71,8 @@ func HandleCreateAutomobile(request *http.Request, r render.Render, success bool
 		body, _ := ioutil.ReadAll(request.Body)
 		log.Println(body)
 		log.Println(string(body))
-		err = jsonapi.UnmarshalFromJSON(body, &resource)
-		log.Println(err)
+		errs := UnmarshalRequest(jasi, ActionCreate, body, &resource)
+		log.Println(errs)
 		log.Println(resource)
 
 		resource.MapToModel(&m)
@@ -89,9 +90,11 @@ func HandleCreateAutomobile(request *http.Request, r render.Render, success bool
 	HandlePostResponse(TEST_SERVER_INFO, success, jsonApiError, &resource, r)
 }
 
-func HandlePatchAutomobile(args martini.Params, request *http.Request, r render.Render, success bool, err error) {
+func HandlePatchAutomobile(args martini.Params, request *http.Request, loader *Loader, r render.Render, success bool, err error) {
 	var resource AutomobileResource
 	var jsonApiError *JsonApiError
+	jasi := TEST_SERVER_INFO
+	jasi.Loader = loader
 
 	if success {
 		// map the resource to the model
@@ -103,8 +106,8 @@ func HandlePatchAutomobile(args martini.Params, request *http.Request, r render.
 		body, _ := ioutil.ReadAll(request.Body)
 		log.Println(body)
 		log.Println(string(body))
-		err = jsonapi.UnmarshalFromJSON(body, &resource)
-		log.Println(err)
+		errs := UnmarshalRequest(jasi, ActionUpdate, body, &resource)
+		log.Println(errs)
 		log.Println(resource)
 
 		rID := resource.GetID()
@@ -184,6 +187,7 @@ var _ = Describe("Controller", func() {
 		// Configure Martini
 		server = martini.Classic()
 		server.Use(render.Renderer())
+		server.Use(LoaderHandler())
 
 		// Record HTTP responses
 		recorder = httptest.NewRecorder()
@@ -229,7 +233,7 @@ var _ = Describe("Controller", func() {
 
 			// verify
 			Ω(recorder.Code).Should(Equal(404))
-			expectedResponse := `{"errors":{"status":"404","detail":"not found"}}`
+			expectedResponse := `{"errors":[{"status":"404","detail":"not found"}]}`
 			Ω(recorder.Body.String()).Should(MatchJSON(expectedResponse))
 		})
 
@@ -265,7 +269,7 @@ var _ = Describe("Controller", func() {
 
 			// verify
 			Ω(recorder.Code).Should(Equal(404))
-			expectedResponse := `{"errors":{"status":"404","detail":"not found"}}`
+			expectedResponse := `{"errors":[{"status":"404","detail":"not found"}]}`
 			Ω(recorder.Body.String()).Should(MatchJSON(expectedResponse))
 		})
 	})
@@ -308,7 +312,7 @@ var _ = Describe("Controller", func() {
 
 			// verify
 			Ω(recorder.Code).Should(Equal(400))
-			responseBody := `{"errors":{"status":"400","detail":"oops"}}`
+			responseBody := `{"errors":[{"status":"400","detail":"oops"}]}`
 			Ω(recorder.Body.String()).Should(Equal(responseBody))
 		})
 
@@ -327,15 +331,12 @@ var _ = Describe("Controller", func() {
 			// verify
 			Ω(recorder.Code).Should(Equal(422))
 
-			// NOTE: cannot perform deep equal on errors array, so have to take an alternate approach
-			responseBody1 := `{` +
-				`"errors":[{"status":"422","detail":"cannot be blank","source":{"pointer":"data/attributes/make"}},` +
-				`{"status":"422","detail":"cannot be greater than 2016","source":{"pointer":"data/attributes/year"}}]}`
-			responseBody2 := `{` +
-				`"errors":[{"status":"422","detail":"cannot be greater than 2016","source":{"pointer":"data/attributes/year"}},` +
-				`{"status":"422","detail":"cannot be blank","source":{"pointer":"data/attributes/make"}}]}`
+			// NOTE: errors are ordered by attribute declaration order
+			responseBody := `{` +
+				`"errors":[{"status":"422","detail":"cannot be greater than 2016","source":{"pointer":"/data/attributes/year"}},` +
+				`{"status":"422","detail":"cannot be blank","source":{"pointer":"/data/attributes/make"}}]}`
 
-			Ω([]string{responseBody1, responseBody2}).Should(ContainElement(recorder.Body.String()))
+			Ω(recorder.Body.String()).Should(Equal(responseBody))
 		})
 	}) // Context "HTTP POST"
 
@@ -405,7 +406,7 @@ var _ = Describe("Controller", func() {
 
 			// verify
 			Ω(recorder.Code).Should(Equal(400))
-			responseBody := `{"errors":{"status":"400","detail":"oops"}}`
+			responseBody := `{"errors":[{"status":"400","detail":"oops"}]}`
 			Ω(recorder.Body.String()).Should(Equal(responseBody))
 		})
 
@@ -424,15 +425,12 @@ var _ = Describe("Controller", func() {
 			// verify
 			Ω(recorder.Code).Should(Equal(422))
 
-			// NOTE: cannot perform deep equal on errors array, so have to take an alternate approach
-			responseBody1 := `{` +
-				`"errors":[{"status":"422","detail":"cannot be blank","source":{"pointer":"data/attributes/make"}},` +
-				`{"status":"422","detail":"cannot be greater than 2016","source":{"pointer":"data/attributes/year"}}]}`
-			responseBody2 := `{` +
-				`"errors":[{"status":"422","detail":"cannot be greater than 2016","source":{"pointer":"data/attributes/year"}},` +
-				`{"status":"422","detail":"cannot be blank","source":{"pointer":"data/attributes/make"}}]}`
+			// NOTE: errors are ordered by attribute declaration order
+			responseBody := `{` +
+				`"errors":[{"status":"422","detail":"cannot be greater than 2016","source":{"pointer":"/data/attributes/year"}},` +
+				`{"status":"422","detail":"cannot be blank","source":{"pointer":"/data/attributes/make"}}]}`
 
-			Ω([]string{responseBody1, responseBody2}).Should(ContainElement(recorder.Body.String()))
+			Ω(recorder.Body.String()).Should(Equal(responseBody))
 		})
 	}) // Context "HTTP PATCH"
 
@@ -464,7 +462,7 @@ var _ = Describe("Controller", func() {
 			// verify
 			Ω(recorder.Code).Should(Equal(400))
 			log.Println(recorder.Body.String())
-			expectedResponse := `{"errors":{"status":"400","detail":"oops"}}`
+			expectedResponse := `{"errors":[{"status":"400","detail":"oops"}]}`
 			Ω(recorder.Body.String()).Should(MatchJSON(expectedResponse))
 		})
 	}) // Context "HTTP DELETE"
@@ -0,0 +1,201 @@
+package gsonapi
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// JsonApiErrorLink => links of an error object
+type JsonApiErrorLink struct {
+	About string `json:"about,omitempty"` // link to further details about this particular occurrence of the problem
+	Type  string `json:"type,omitempty"`  // link that identifies the type of error
+}
+
+// JsonApiErrorSource => references to the primary source of an error
+type JsonApiErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`   // json pointer to the value in the request document, EX: /data/attributes/year
+	Parameter string `json:"parameter,omitempty"` // query parameter that caused the error, EX: page[size]
+	Header    string `json:"header,omitempty"`    // request header that caused the error, EX: X-Tenant-ID
+}
+
+// JsonApiError => a JSON:API 1.1 error object
+type JsonApiError struct {
+	ID     string                 `json:"id,omitempty"`
+	Links  *JsonApiErrorLink      `json:"links,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Source *JsonApiErrorSource    `json:"source,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// StatusCode => the error's status as an int, or the fallback if the status is blank or invalid
+func (e *JsonApiError) StatusCode(fallback int) int {
+	if status, err := strconv.Atoi(e.Status); err == nil {
+		return status
+	}
+	return fallback
+}
+
+// Error => satisfies the error interface
+func (e *JsonApiError) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Title
+}
+
+// ErrorBuilder => fluent builder for error objects
+// EX: NewError(409).Code("VEHICLE_RETIRED").Detail("retired in 2015").Pointer("/data/id").Build()
+type ErrorBuilder struct {
+	err JsonApiError
+}
+
+// NewError => starts building an error w/ the given status and the status' text as its title
+func NewError(status int) *ErrorBuilder {
+	return &ErrorBuilder{err: JsonApiError{Status: strconv.Itoa(status), Title: http.StatusText(status)}}
+}
+
+func (b *ErrorBuilder) ID(id string) *ErrorBuilder {
+	b.err.ID = id
+	return b
+}
+
+func (b *ErrorBuilder) Code(code string) *ErrorBuilder {
+	b.err.Code = code
+	return b
+}
+
+func (b *ErrorBuilder) Title(title string) *ErrorBuilder {
+	b.err.Title = title
+	return b
+}
+
+func (b *ErrorBuilder) Detail(detail string) *ErrorBuilder {
+	b.err.Detail = detail
+	return b
+}
+
+func (b *ErrorBuilder) About(url string) *ErrorBuilder {
+	b.links().About = url
+	return b
+}
+
+func (b *ErrorBuilder) Type(url string) *ErrorBuilder {
+	b.links().Type = url
+	return b
+}
+
+func (b *ErrorBuilder) Pointer(pointer string) *ErrorBuilder {
+	b.source().Pointer = pointer
+	return b
+}
+
+func (b *ErrorBuilder) Parameter(parameter string) *ErrorBuilder {
+	b.source().Parameter = parameter
+	return b
+}
+
+func (b *ErrorBuilder) Header(header string) *ErrorBuilder {
+	b.source().Header = header
+	return b
+}
+
+func (b *ErrorBuilder) Meta(key string, value interface{}) *ErrorBuilder {
+	if b.err.Meta == nil {
+		b.err.Meta = map[string]interface{}{}
+	}
+	b.err.Meta[key] = value
+	return b
+}
+
+// Build => returns a copy of the built error, so the builder can be reused
+func (b *ErrorBuilder) Build() *JsonApiError {
+	err := b.err
+	if b.err.Links != nil {
+		links := *b.err.Links
+		err.Links = &links
+	}
+	if b.err.Source != nil {
+		source := *b.err.Source
+		err.Source = &source
+	}
+	if b.err.Meta != nil {
+		err.Meta = map[string]interface{}{}
+		for k, v := range b.err.Meta {
+			err.Meta[k] = v
+		}
+	}
+	return &err
+}
+
+func (b *ErrorBuilder) links() *JsonApiErrorLink {
+	if b.err.Links == nil {
+		b.err.Links = &JsonApiErrorLink{}
+	}
+	return b.err.Links
+}
+
+func (b *ErrorBuilder) source() *JsonApiErrorSource {
+	if b.err.Source == nil {
+		b.err.Source = &JsonApiErrorSource{}
+	}
+	return b.err.Source
+}
+
+// ErrorDefinition => an application error code's title, default status and about url
+type ErrorDefinition struct {
+	Code   string
+	Title  string
+	Status int
+	About  string
+}
+
+// ErrorCatalog => registry of application error codes
+type ErrorCatalog struct {
+	definitions map[string]ErrorDefinition
+}
+
+// ErrCatalog => global error catalog
+// EX: ErrCatalog.Register("VEHICLE_RETIRED", "Vehicle Retired", 409, "https://carz.com/errors/vehicle-retired")
+// and then ErrCatalog.New("VEHICLE_RETIRED", "retired in 2015")
+var ErrCatalog = NewErrorCatalog()
+
+func NewErrorCatalog() *ErrorCatalog {
+	return &ErrorCatalog{definitions: map[string]ErrorDefinition{}}
+}
+
+// Register => adds an application error code to the catalog (call during app startup)
+func (c *ErrorCatalog) Register(code string, title string, status int, about string) {
+	c.definitions[code] = ErrorDefinition{Code: code, Title: title, Status: status, About: about}
+}
+
+// Lookup => the definition of an application error code
+func (c *ErrorCatalog) Lookup(code string) (ErrorDefinition, bool) {
+	d, ok := c.definitions[code]
+	return d, ok
+}
+
+// Builder => starts building an error for the application error code
+// NOTE: an unregistered code results in a 500 error, so that it is noticed
+func (c *ErrorCatalog) Builder(code string) *ErrorBuilder {
+	d, ok := c.Lookup(code)
+	if !ok {
+		return NewError(500).Code(code)
+	}
+
+	b := NewError(d.Status).Code(d.Code)
+	if d.Title != "" {
+		b.Title(d.Title)
+	}
+	if d.About != "" {
+		b.About(d.About)
+	}
+	return b
+}
+
+// New => an error for the application error code w/ the given detail
+func (c *ErrorCatalog) New(code string, detail string) *JsonApiError {
+	return c.Builder(code).Detail(detail).Build()
+}
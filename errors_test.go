@@ -0,0 +1,86 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+	Context("Error Object", func() {
+		It("should serialize every JSON:API 1.1 member", func() {
+			err := JsonApiError{ID: "1", Status: "409", Code: "VEHICLE_RETIRED", Title: "Vehicle Retired", Detail: "retired in 2015",
+				Links:  &JsonApiErrorLink{About: "https://carz.com/errors/1", Type: "https://carz.com/errors/vehicle-retired"},
+				Source: &JsonApiErrorSource{Pointer: "/data/id", Parameter: "filter[vin]", Header: "X-Tenant-ID"},
+				Meta:   map[string]interface{}{"retired": 2015}}
+
+			j, _ := json.Marshal(err)
+			Ω(string(j)).Should(MatchJSON(`{"id":"1","status":"409","code":"VEHICLE_RETIRED","title":"Vehicle Retired","detail":"retired in 2015",` +
+				`"links":{"about":"https://carz.com/errors/1","type":"https://carz.com/errors/vehicle-retired"},` +
+				`"source":{"pointer":"/data/id","parameter":"filter[vin]","header":"X-Tenant-ID"},"meta":{"retired":2015}}`))
+		})
+
+		It("should parse its status code", func() {
+			Ω((&JsonApiError{Status: "409"}).StatusCode(400)).Should(Equal(409))
+			Ω((&JsonApiError{}).StatusCode(400)).Should(Equal(400))
+		})
+	})
+
+	Context("Builder", func() {
+		It("should build an error", func() {
+			err := NewError(422).Code("TOO_NEW").Detail("cannot be greater than 2016").
+				Pointer("/data/attributes/year").Meta("max", 2016).About("https://carz.com/errors/too-new").Build()
+
+			Ω(err).Should(Equal(&JsonApiError{Status: "422", Code: "TOO_NEW", Title: "Unprocessable Entity",
+				Detail: "cannot be greater than 2016", Links: &JsonApiErrorLink{About: "https://carz.com/errors/too-new"},
+				Source: &JsonApiErrorSource{Pointer: "/data/attributes/year"}, Meta: map[string]interface{}{"max": 2016}}))
+		})
+
+		It("should build independent copies", func() {
+			b := NewError(400).Parameter("page[size]")
+			err1 := b.Build()
+			err2 := b.Parameter("page[number]").Build()
+
+			Ω(err1.Source.Parameter).Should(Equal("page[size]"))
+			Ω(err2.Source.Parameter).Should(Equal("page[number]"))
+		})
+	})
+
+	Context("Catalog", func() {
+		BeforeEach(func() {
+			ErrCatalog.Register("VEHICLE_RETIRED", "Vehicle Retired", 409, "https://carz.com/errors/vehicle-retired")
+		})
+
+		It("should create an error for a registered code", func() {
+			Ω(ErrCatalog.New("VEHICLE_RETIRED", "retired in 2015")).Should(Equal(&JsonApiError{Status: "409", Code: "VEHICLE_RETIRED",
+				Title: "Vehicle Retired", Detail: "retired in 2015", Links: &JsonApiErrorLink{About: "https://carz.com/errors/vehicle-retired"}}))
+		})
+
+		It("should create a 500 error for an unregistered code", func() {
+			err := ErrCatalog.New("UNKNOWN", "oops")
+			Ω(err.Status).Should(Equal("500"))
+			Ω(err.Code).Should(Equal("UNKNOWN"))
+		})
+
+		It("should render a consistent error document", func() {
+			server := martini.Classic()
+			server.Use(render.Renderer())
+			recorder := httptest.NewRecorder()
+			server.Delete("/v1/automobiles/:id", func(r render.Render) {
+				HandleDeleteResponse(ErrCatalog.Builder("VEHICLE_RETIRED").Pointer("/data/id").Build(), r)
+			})
+
+			request, _ := http.NewRequest("DELETE", "/v1/automobiles/1", nil)
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(409))
+			Ω(recorder.Body.String()).Should(MatchJSON(`{"errors":[{"status":"409","code":"VEHICLE_RETIRED","title":"Vehicle Retired",` +
+				`"links":{"about":"https://carz.com/errors/vehicle-retired"},"source":{"pointer":"/data/id"}}]}`))
+		})
+	})
+})
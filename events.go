@@ -0,0 +1,277 @@
+package gsonapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// REQUEST_ID_HEADER => the request header that carries the request's id, echoed in the response
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// RequestID => the id of a request; mapped into the martini context by RequestIDHandler
+type RequestID string
+
+// Event => a successful create, update (including relationship changes) or delete of a resource,
+// published by CreateWithHooks, UpdateWithHooks and DeleteWithHooks to Events
+type Event struct {
+	Type          string
+	ID            string
+	Action        Action            // ActionCreate, ActionUpdate or ActionDelete
+	Attributes    map[string]Change // the attributes that changed, keyed by name, w/o write only and role hidden ones
+	Relationships map[string]Change // the relationships that changed, keyed by name, w/ the ids of the related resources
+	Actor         interface{}       // JSONApiServerInfo.Caller
+	Tenant        string            // JSONApiServerInfo.Tenant
+	RequestID     string            // JSONApiServerInfo.RequestID
+	Time          time.Time
+}
+
+// Change => an attribute's (or relationship's) value before and after a mutation
+// NOTE: Before is nil for created resources, or if the previous value is unknown,
+// and After is nil for deleted resources
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// EventFilter => which events a subscriber receives; empty lists match everything
+// EX: EventFilter{Types: []string{"automobiles"}, Actions: []Action{ActionDelete}}
+type EventFilter struct {
+	Types   []string
+	Actions []Action
+	Match   func(event Event) bool // optional, EX: only the updates that changed the drivers relationship
+}
+
+// Matches => true if the filter matches the event
+func (f EventFilter) Matches(event Event) bool {
+	if !f.matches(event.Type, event.Action) {
+		return false
+	}
+	return f.Match == nil || f.Match(event)
+}
+
+func (f EventFilter) matches(resourceType string, action Action) bool {
+	if len(f.Types) > 0 && !contains(f.Types, resourceType) {
+		return false
+	}
+	if len(f.Actions) == 0 {
+		return true
+	}
+	for _, a := range f.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// EventHandler => receives the events of a subscription
+type EventHandler func(event Event)
+
+// EventBus => an in-process publish/subscribe bus of resource events
+// NOTE: handlers are called synchronously, in the order they subscribed, on the publishing request's goroutine,
+// so slow work (e.g., outbound notifications) should be handed off to another goroutine or a queue
+type EventBus struct {
+	mutex         sync.RWMutex
+	subscriptions []*subscription
+}
+
+type subscription struct {
+	filter  EventFilter
+	handler EventHandler
+}
+
+// Events => the bus the hooks functions publish to
+var Events = NewEventBus()
+
+// NewEventBus => a bus w/o subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe => calls the handler w/ the published events that match the filter until unsubscribe is called
+// EX: unsubscribe := Events.Subscribe(EventFilter{Types: []string{"automobiles"}}, audit)
+func (b *EventBus) Subscribe(filter EventFilter, handler EventHandler) (unsubscribe func()) {
+	s := &subscription{filter: filter, handler: handler}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscriptions = append(b.subscriptions, s)
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		for i, subscribed := range b.subscriptions {
+			if subscribed == s {
+				b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish => calls the handlers of the subscriptions that match the event
+func (b *EventBus) Publish(event Event) {
+	b.mutex.RLock()
+	subscriptions := b.subscriptions
+	b.mutex.RUnlock()
+
+	for _, s := range subscriptions {
+		if s.filter.Matches(event) {
+			s.handler(event)
+		}
+	}
+}
+
+// Subscribed => true if a subscription may match the resource type's events of the action
+// NOTE: used to skip building events nobody receives
+func (b *EventBus) Subscribed(resourceType string, action Action) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, s := range b.subscriptions {
+		if s.filter.matches(resourceType, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestIDHandler => martini middleware that maps the request's id as a RequestID and echoes it in the response
+// NOTE: the id is the X-Request-ID request header, or a random one if the header is missing;
+// set it as the request's JSONApiServerInfo.RequestID so that it is part of the request's events
+func RequestIDHandler() martini.Handler {
+	return func(c martini.Context, req *http.Request, res http.ResponseWriter) {
+		id := req.Header.Get(REQUEST_ID_HEADER)
+		if id == "" {
+			id = randomID()
+		}
+		res.Header().Set(REQUEST_ID_HEADER, id)
+		c.Map(RequestID(id))
+	}
+}
+
+// randomID => 16 random bytes, hex encoded
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// snapshot => a resource's attributes and the ids of its related resources, keyed by relationship name
+type snapshot struct {
+	attributes    map[string]interface{}
+	relationships map[string]interface{}
+}
+
+// takeSnapshot => the record's state, or nil if the record is nil
+// NOTE: write only attributes and attributes hidden from any role (see FieldPermission) are left out,
+// since the subscribers' audience is unknown
+func takeSnapshot(record interface{}) *snapshot {
+	identifier, ok := record.(jsonapi.MarshalIdentifier)
+	if !ok {
+		return nil
+	}
+	if val := reflect.ValueOf(record); val.Kind() == reflect.Ptr && val.IsNil() {
+		return nil
+	}
+
+	s := &snapshot{attributes: resourceAttributes(identifier), relationships: map[string]interface{}{}}
+	for name, p := range GetFieldPermissions(record) {
+		if p.WriteOnly || len(p.HiddenFor) > 0 {
+			delete(s.attributes, name)
+		}
+	}
+	for _, reference := range GetReferences(record) {
+		delete(s.attributes, reference.Name)
+		s.relationships[reference.Name] = []string{}
+	}
+	for _, reference := range GetReferencedIDs(record) {
+		ids, _ := s.relationships[reference.Name].([]string)
+		s.relationships[reference.Name] = append(ids, reference.ID)
+	}
+	return s
+}
+
+// previousSnapshot => the state of the resource's stored record, or nil if it has no repository or record
+func previousSnapshot(resource Resourcer) *snapshot {
+	repository, ok := RepositoryFor(resourceType(resource))
+	if !ok || resource.GetID() == "" {
+		return nil
+	}
+	record, err := repository.FindOne(resource.GetID())
+	if err != nil {
+		return nil
+	}
+	return takeSnapshot(record)
+}
+
+// changes => the values that differ between the before and after states
+// NOTE: if the before state is unknown, the values of the after state are changes; a nil present func compares
+// all values, otherwise only the present ones, i.e., those of the request document of a partial update
+func changes(before map[string]interface{}, after map[string]interface{}, known bool, present func(string) bool) map[string]Change {
+	changed := map[string]Change{}
+	for name, value := range after {
+		if present != nil && !present(name) {
+			continue
+		}
+		previous, ok := before[name]
+		if !known || !ok || !reflect.DeepEqual(previous, value) {
+			changed[name] = Change{Before: previous, After: value}
+		}
+	}
+	for name, previous := range before {
+		if present != nil && !present(name) {
+			continue
+		}
+		if _, ok := after[name]; !ok {
+			changed[name] = Change{Before: previous}
+		}
+	}
+	return changed
+}
+
+// newEvent => the event of a mutation from the resource's states before and after it
+// NOTE: a nil before state is unknown and a nil after state is empty, i.e., the resource was deleted
+func newEvent(jasi JSONApiServerInfo, action Action, resource Resourcer, model interface{}, before *snapshot, after *snapshot) Event {
+	event := Event{
+		Type:      resourceType(resource),
+		ID:        resource.GetID(),
+		Action:    action,
+		Actor:     jasi.Caller,
+		Tenant:    jasi.Tenant,
+		RequestID: jasi.RequestID,
+		Time:      time.Now().UTC(),
+	}
+	if event.ID == "" && model != nil {
+		// NOTE: the id of a created resource may only be set on its model until MapFromModel
+		if id := reflect.Indirect(reflect.ValueOf(model)).FieldByName("ID"); id.Kind() == reflect.String {
+			event.ID = id.String()
+		}
+	}
+
+	empty := &snapshot{}
+	if after == nil {
+		after = empty
+	}
+	known := before != nil
+	if before == nil {
+		before = empty
+	}
+
+	// NOTE: an updated resource unmarshalled from a PATCH request only holds the values of its request document
+	var present func(string) bool
+	if presence, ok := resource.(interface{ PresentKeys() []string }); ok && action == ActionUpdate && len(presence.PresentKeys()) > 0 {
+		keys := presence.PresentKeys()
+		present = func(name string) bool { return contains(keys, name) }
+	}
+	event.Attributes = changes(before.attributes, after.attributes, known, present)
+	event.Relationships = changes(before.relationships, after.relationships, known, present)
+	return event
+}
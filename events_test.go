@@ -0,0 +1,218 @@
+package gsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-martini/martini"
+	"github.com/modocache/gory"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+var _ = Describe("Events", func() {
+	var (
+		events      []Event
+		unsubscribe func()
+		jasi        JSONApiServerInfo
+	)
+
+	save := func() *JsonApiError { return nil }
+
+	subscribe := func(filter EventFilter) {
+		unsubscribe = Events.Subscribe(filter, func(event Event) {
+			events = append(events, event)
+		})
+	}
+
+	BeforeEach(func() {
+		events = nil
+		unsubscribe = func() {}
+		jasi = TEST_SERVER_INFO
+		jasi.Caller = "admin"
+		jasi.RequestID = "req-1"
+	})
+
+	AfterEach(func() {
+		unsubscribe()
+	})
+
+	It("should publish the attributes of a created resource", func() {
+		subscribe(EventFilter{})
+
+		r := LotResource{Name: "North", Spaces: 10}
+		model := &LotModel{}
+		Ω(CreateWithHooks(jasi, &r, model, func() *JsonApiError {
+			model.ID = "lot-1"
+			return nil
+		})).Should(BeNil())
+
+		Ω(events).Should(HaveLen(1))
+		event := events[0]
+		Ω(event.Type).Should(Equal("lots"))
+		Ω(event.ID).Should(Equal("lot-1"))
+		Ω(event.Action).Should(Equal(ActionCreate))
+		Ω(event.Actor).Should(Equal("admin"))
+		Ω(event.RequestID).Should(Equal("req-1"))
+		Ω(event.Time.IsZero()).Should(BeFalse())
+		Ω(event.Attributes).Should(Equal(map[string]Change{
+			"name":   {After: "North"},
+			"spaces": {After: float64(10)},
+			"full":   {After: false},
+		}))
+	})
+
+	It("should not publish write only and role hidden attributes", func() {
+		subscribe(EventFilter{})
+
+		webhook := WebhookResource{URL: "https://example.com", Secret: "topsecret"}
+		webhook.SetID("w1")
+		Ω(CreateWithHooks(jasi, &webhook, nil, save)).Should(BeNil())
+		invoice := InvoiceResource{Notes: null.StringFrom("paid"), Total: null.FloatFrom(10)}
+		invoice.SetID("i1")
+		Ω(CreateWithHooks(jasi, &invoice, nil, save)).Should(BeNil())
+
+		Ω(events).Should(HaveLen(2))
+		Ω(events[0].Attributes).Should(HaveKey("url"))
+		Ω(events[0].Attributes).ShouldNot(HaveKey("secret"))
+		Ω(events[1].Attributes).Should(HaveKey("notes"))
+		Ω(events[1].Attributes).ShouldNot(HaveKey("total"))
+	})
+
+	It("should publish the attributes of a deleted resource", func() {
+		subscribe(EventFilter{Actions: []Action{ActionDelete}})
+
+		r := LotResource{Name: "North"}
+		r.SetID("lot-1")
+		Ω(CreateWithHooks(jasi, &r, nil, save)).Should(BeNil())
+		Ω(DeleteWithHooks(jasi, &r, nil, save)).Should(BeNil())
+
+		Ω(events).Should(HaveLen(1))
+		Ω(events[0].Action).Should(Equal(ActionDelete))
+		Ω(events[0].Attributes).Should(Equal(map[string]Change{
+			"name":   {Before: "North"},
+			"spaces": {Before: float64(0)},
+			"full":   {Before: false},
+		}))
+	})
+
+	It("should diff an update against the record in the repository", func() {
+		subscribe(EventFilter{Types: []string{"drivers"}})
+
+		r := gory.Build("driverResource1").(*DriverResource)
+		r.Age = 41
+		Ω(UpdateWithHooks(jasi, r, nil, save)).Should(BeNil())
+
+		Ω(events).Should(HaveLen(1))
+		Ω(events[0].ID).Should(Equal("driver-id-1"))
+		Ω(events[0].Attributes).Should(Equal(map[string]Change{
+			"age": {Before: float64(40), After: float64(41)},
+		}))
+		Ω(events[0].Relationships).Should(BeEmpty())
+	})
+
+	It("should only diff the attributes present in a partial update", func() {
+		subscribe(EventFilter{Types: []string{"drivers"}})
+
+		r := DriverResource{}
+		body := []byte(`{"data": {"type": "drivers", "id": "driver-id-1", "attributes": {"age": 41}}}`)
+		Ω(UnmarshalRequest(jasi, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(UpdateWithHooks(jasi, &r, nil, save)).Should(BeNil())
+
+		Ω(events).Should(HaveLen(1))
+		Ω(events[0].Attributes).Should(Equal(map[string]Change{
+			"age": {Before: float64(40), After: float64(41)},
+		}))
+		Ω(events[0].Relationships).Should(BeEmpty())
+	})
+
+	It("should diff the relationships of an update", func() {
+		previous, _ := RepositoryFor("automobiles")
+		automobiles := NewMemoryRepository(AutomobileResource{})
+		RegisterRepository("automobiles", automobiles)
+		defer RegisterRepository("automobiles", previous)
+
+		driver1 := *gory.Build("driverResource1").(*DriverResource)
+		driver2 := *gory.Build("driverResource2").(*DriverResource)
+		r := &AutomobileResource{Drivers: []DriverResource{driver1}}
+		r.SetID("a1")
+		Ω(automobiles.Create(r)).Should(BeNil())
+
+		subscribe(EventFilter{Match: func(event Event) bool {
+			_, ok := event.Relationships["drivers"]
+			return ok
+		}})
+
+		r.Drivers = []DriverResource{driver1, driver2}
+		Ω(UpdateWithHooks(jasi, r, nil, save)).Should(BeNil())
+
+		Ω(events).Should(HaveLen(1))
+		Ω(events[0].Attributes).Should(BeEmpty())
+		Ω(events[0].Relationships).Should(Equal(map[string]Change{
+			"drivers": {Before: []string{"driver-id-1"}, After: []string{"driver-id-1", "driver-id-2"}},
+		}))
+	})
+
+	It("should report the attributes present in the request as changed if the record is unknown", func() {
+		subscribe(EventFilter{Types: []string{"lots"}})
+
+		r := LotResource{Name: "North", Spaces: 10}
+		body := []byte(`{"data": {"type": "lots", "id": "lot-1", "attributes": {"name": "South"}}}`)
+		Ω(UnmarshalRequest(jasi, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(UpdateWithHooks(jasi, &r, nil, save)).Should(BeNil())
+
+		Ω(events).Should(HaveLen(1))
+		Ω(events[0].Attributes).Should(Equal(map[string]Change{"name": {After: "South"}}))
+	})
+
+	It("should not publish events of failed or filtered out mutations", func() {
+		subscribe(EventFilter{Types: []string{"lots"}, Actions: []Action{ActionCreate}})
+
+		r := LotResource{Name: "North"}
+		Ω(CreateWithHooks(jasi, &r, nil, func() *JsonApiError { return NewError(500).Build() })).ShouldNot(BeNil())
+		Ω(DeleteWithHooks(jasi, &r, nil, save)).Should(BeNil())
+		Ω(CreateWithHooks(jasi, &LotResource{Spaces: -1}, nil, save)).ShouldNot(BeNil())
+		Ω(events).Should(BeEmpty())
+
+		Ω(Events.Subscribed("lots", ActionCreate)).Should(BeTrue())
+		Ω(Events.Subscribed("lots", ActionUpdate)).Should(BeFalse())
+		unsubscribe()
+		Ω(Events.Subscribed("lots", ActionCreate)).Should(BeFalse())
+	})
+
+	It("should call the subscribers in order until they unsubscribe", func() {
+		bus := NewEventBus()
+		calls := []string{}
+		first := bus.Subscribe(EventFilter{}, func(Event) { calls = append(calls, "first") })
+		bus.Subscribe(EventFilter{Types: []string{"lots"}}, func(Event) { calls = append(calls, "second") })
+
+		bus.Publish(Event{Type: "lots"})
+		first()
+		bus.Publish(Event{Type: "lots"})
+		bus.Publish(Event{Type: "drivers"})
+		Ω(calls).Should(Equal([]string{"first", "second", "second"}))
+	})
+
+	It("should map the request id", func() {
+		server := martini.Classic()
+		server.Use(RequestIDHandler())
+		var id RequestID
+		server.Get("/v1/lots", func(requestID RequestID) {
+			id = requestID
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/v1/lots", nil)
+		request.Header.Set(REQUEST_ID_HEADER, "abc")
+		server.ServeHTTP(recorder, request)
+		Ω(id).Should(Equal(RequestID("abc")))
+		Ω(recorder.Header().Get(REQUEST_ID_HEADER)).Should(Equal("abc"))
+
+		recorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/v1/lots", nil)
+		server.ServeHTTP(recorder, request)
+		Ω(id).Should(HaveLen(32))
+		Ω(recorder.Header().Get(REQUEST_ID_HEADER)).Should(Equal(string(id)))
+	})
+})
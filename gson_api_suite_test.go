@@ -1,10 +1,8 @@
 package gsonapi
 
 import (
-	"errors"
 	"log"
 
-	"github.com/manyminds/api2go/jsonapi"
 	"github.com/modocache/gory"
 	"github.com/obieq/gas"
 	validations "github.com/obieq/goar-validations"
@@ -28,14 +26,19 @@ func TestGsonApi(t *testing.T) {
 // ******************* BEGIN MODEL SECTION **************************** //
 type AutomobileModel struct {
 	validations.Validation
-	ID          string        `json:"id"`
-	Year        int           `json:"year,omitempty"`
-	Make        string        `json:"make,omitempty"`
-	BodyStyle   *string       `json:"body-style,omitempty"`
-	Active      bool          `json:"active,omitempty"`
-	Ages        []int         `json:"ages,omitempty"`
-	Inspections []interface{} `json:"inspections,omitempty"`
-	Drivers     []DriverModel `json:"drivers,omitempty"`
+	ID          string            `json:"id"`
+	Year        int               `json:"year,omitempty"`
+	Make        string            `json:"make,omitempty"`
+	BodyStyle   *string           `json:"body-style,omitempty"`
+	Active      bool              `json:"active,omitempty"`
+	Ages        []int             `json:"ages,omitempty"`
+	Inspections []InspectionModel `json:"inspections,omitempty"`
+	Drivers     []DriverModel     `json:"drivers,omitempty"`
+}
+
+type InspectionModel struct {
+	Name     string `json:"name,omitempty"`
+	Location string `json:"location,omitempty"`
 }
 
 type DriverModel struct {
@@ -55,14 +58,13 @@ type DriverModel struct {
 //       that's how we detect partial (PATCH) updates
 type AutomobileResource struct {
 	Resource    `jsonapi:"-"`
-	Year        null.Int         `json:"year,omitempty" jsonapi:"name=year"`
-	Make        null.String      `json:"make,omitempty" jsonapi:"name=make"`
-	BodyStyle   null.String      `json:"body-style,omitempty" jsonapi:"name=body-style"`
-	Active      null.Bool        `json:"active,omitempty" jsonapi:"name=active"`
-	Drivers     []DriverResource `json:"drivers,omitempty" jsonapi:"-"`
-	DriversIDs  []string         `json:"-" jsonapi:"-"`
-	Inspections []interface{}    `json:"inspections,omitempty" jsonapi:"name=inspections"`
-	Ages        []interface{}    `json:"ages,omitempty" jsonapi:"name=ages"`
+	Year        null.Int             `json:"year,omitempty" jsonapi:"name=year"`
+	Make        null.String          `json:"make,omitempty" jsonapi:"name=make"`
+	BodyStyle   null.String          `json:"body-style,omitempty" jsonapi:"name=body-style"`
+	Active      null.Bool            `json:"active,omitempty" jsonapi:"name=active"`
+	Drivers     []DriverResource     `json:"drivers,omitempty" jsonapi:"relation=drivers,type=drivers"`
+	Inspections []InspectionResource `json:"inspections,omitempty" jsonapi:"name=inspections"`
+	Ages        []interface{}        `json:"ages,omitempty" jsonapi:"name=ages"`
 }
 
 type InspectionResource struct {
@@ -78,49 +80,6 @@ type DriverResource struct {
 	Active   bool   `json:"active,omitempty" jsonapi:"name=active"`
 }
 
-// GetReferences to satisfy the jsonapi.MarshalReferences interface
-func (r AutomobileResource) GetReferences() []jsonapi.Reference {
-	return []jsonapi.Reference{
-		{
-			Type: "drivers",
-			Name: "drivers",
-		},
-	}
-}
-
-func (r AutomobileResource) GetReferencedIDs() []jsonapi.ReferenceID {
-	result := []jsonapi.ReferenceID{}
-	for _, driver := range r.Drivers {
-		result = append(result, jsonapi.ReferenceID{ID: driver.GetID(), Name: "drivers", Type: "drivers"})
-	}
-
-	return result
-}
-
-// SetToManyReferenceIDs sets the sweets reference IDs and satisfies the jsonapi.UnmarshalToManyRelations interface
-func (r *AutomobileResource) SetToManyReferenceIDs(name string, IDs []string) error {
-	var err error
-
-	switch name {
-	case "drivers":
-		r.DriversIDs = IDs
-	default:
-		err = errors.New("There is no to-many relationship with the name " + name)
-	}
-
-	return err
-}
-
-func (r AutomobileResource) GetReferencedStructs() []jsonapi.MarshalIdentifier {
-	result := []jsonapi.MarshalIdentifier{}
-
-	for key := range r.Drivers {
-		result = append(result, r.Drivers[key])
-	}
-
-	return result
-}
-
 func (r AutomobileResource) GetName() string {
 	return "automobiles"
 }
@@ -129,6 +88,14 @@ func (r DriverResource) GetName() string {
 	return "drivers"
 }
 
+func (r *DriverResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *DriverResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
 // MapFromModel => maps a model to a resource
 func (r *AutomobileResource) MapFromModel(model interface{}) (err error) {
 	log.Println(model)
@@ -140,7 +107,12 @@ func (r *AutomobileResource) MapFromModel(model interface{}) (err error) {
 		r.Year = null.IntFrom(int64(m.Year))
 		r.Make = null.StringFrom(m.Make)
 		r.Active = null.BoolFrom(m.Active)
-		r.Inspections = m.Inspections
+
+		// inspections (nested attributes)
+		r.Inspections = nil
+		for _, inspection := range m.Inspections {
+			r.Inspections = append(r.Inspections, InspectionResource(inspection))
+		}
 
 		// body style
 		if m.BodyStyle != nil {
@@ -171,7 +143,11 @@ func (r *AutomobileResource) MapFromModel(model interface{}) (err error) {
 func (r *AutomobileResource) MapToModel(model interface{}) (err error) {
 	m := model.(*AutomobileModel)
 
-	m.Inspections = r.Inspections
+	// inspections (nested attributes)
+	m.Inspections = nil
+	for _, inspection := range r.Inspections {
+		m.Inspections = append(m.Inspections, InspectionModel(inspection))
+	}
 
 	// year
 	if !r.Year.IsZero() {
@@ -184,8 +160,10 @@ func (r *AutomobileResource) MapToModel(model interface{}) (err error) {
 	}
 
 	// body style
-	// NOTE: model is a pointer, i.e., nullable
-	if !r.BodyStyle.IsZero() {
+	// NOTE: model is a pointer, i.e., nullable, so an explicit null clears it
+	if r.IsExplicitNull("body-style") {
+		m.BodyStyle = nil
+	} else if !r.BodyStyle.IsZero() {
 		bs := r.BodyStyle.String
 		m.BodyStyle = &bs
 	}
@@ -202,10 +180,28 @@ func (r *AutomobileResource) MapToModel(model interface{}) (err error) {
 	}
 
 	// drivers (a to-many relationship)
-	m.Drivers = make([]DriverModel, len(r.DriversIDs))
-	for i, id := range r.DriversIDs {
-		// here is where we would look up the corresponding model via db, cache, etc
-		dr := *gory.Build("driverResource" + id[len(id)-1:]).(*DriverResource)
+	// NOTE: the drivers are looked up w/ one FindMany, see Loader
+	ids := make([]string, len(r.Drivers))
+	for i, driver := range r.Drivers {
+		ids[i] = driver.GetID()
+	}
+	// NOTE: the request's loader caches the drivers for the whole request, a new one is used outside of a request;
+	// Loader.Get does not authorize the drivers, which are only linked to the automobile, not returned to the caller
+	loader := r.Loader()
+	if loader == nil {
+		loader = NewLoader()
+	}
+	if jsonErr := loader.Load("drivers", ids); jsonErr != nil {
+		return jsonErr
+	}
+
+	m.Drivers = make([]DriverModel, len(r.Drivers))
+	for i, id := range ids {
+		record, jsonErr := loader.Get("drivers", id)
+		if jsonErr != nil {
+			return jsonErr
+		}
+		dr := record.(*DriverResource)
 		driverModel := DriverModel{Name: dr.Name, Age: dr.Age, Active: dr.Active}
 		driverModel.ID = id
 		m.Drivers[i] = driverModel
@@ -257,6 +253,12 @@ var _ = BeforeSuite(func() {
 		factory["Active"] = false
 	})
 
+	// the drivers repository, see AutomobileResource.MapToModel
+	drivers := NewMemoryRepository(DriverResource{})
+	drivers.Create(gory.Build("driverResource1").(*DriverResource))
+	drivers.Create(gory.Build("driverResource2").(*DriverResource))
+	RegisterRepository("drivers", drivers)
+
 	// AUTOMOBILES
 	gory.Define("automobileResource1", AutomobileResource{}, func(factory gory.Factory) {
 		factory["ID"] = "aaaa-1111-bbbb-2222"
@@ -267,8 +269,7 @@ var _ = BeforeSuite(func() {
 
 		inspection1 := *gory.Build("inspectionResource1").(*InspectionResource)
 		inspection2 := *gory.Build("inspectionResource2").(*InspectionResource)
-		// factory["Inspections"] = []InspectionResource{inspection1, inspection2}
-		factory["Inspections"] = []interface{}{inspection1, inspection2}
+		factory["Inspections"] = []InspectionResource{inspection1, inspection2}
 
 		driver1 := *gory.Build("driverResource1").(*DriverResource)
 		driver2 := *gory.Build("driverResource2").(*DriverResource)
@@ -282,8 +283,7 @@ var _ = BeforeSuite(func() {
 		factory["Active"] = null.BoolFrom(true)
 
 		inspection1 := *gory.Build("inspectionResource1").(*InspectionResource)
-		// factory["Inspections"] = []InspectionResource{inspection1}
-		factory["Inspections"] = []interface{}{inspection1}
+		factory["Inspections"] = []InspectionResource{inspection1}
 	})
 
 	gory.Define("automobileResource3", AutomobileResource{}, func(factory gory.Factory) {
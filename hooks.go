@@ -0,0 +1,216 @@
+package gsonapi
+
+import (
+	"reflect"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// BeforeCreater => optional hook of resources and models, see CreateWithHooks
+// NOTE: a returned error aborts the request
+type BeforeCreater interface {
+	BeforeCreate(jasi JSONApiServerInfo) *JsonApiError
+}
+
+// AfterCreater => optional hook of resources and models, see CreateWithHooks
+type AfterCreater interface {
+	AfterCreate(jasi JSONApiServerInfo)
+}
+
+// BeforeUpdater => optional hook of resources and models, see UpdateWithHooks
+// NOTE: a returned error aborts the request
+type BeforeUpdater interface {
+	BeforeUpdate(jasi JSONApiServerInfo) *JsonApiError
+}
+
+// AfterUpdater => optional hook of resources and models, see UpdateWithHooks
+type AfterUpdater interface {
+	AfterUpdate(jasi JSONApiServerInfo)
+}
+
+// BeforeDeleter => optional hook of resources and models, see DeleteWithHooks
+// NOTE: a returned error aborts the request
+type BeforeDeleter interface {
+	BeforeDelete(jasi JSONApiServerInfo) *JsonApiError
+}
+
+// AfterDeleter => optional hook of resources and models, see DeleteWithHooks
+type AfterDeleter interface {
+	AfterDelete(jasi JSONApiServerInfo)
+}
+
+// AfterLoader => optional hook of resources, called by HandleGetResponse and HandleIndexResponse
+// before the resources are rendered, EX: to compute a derived attribute
+type AfterLoader interface {
+	AfterLoad(jasi JSONApiServerInfo)
+}
+
+// CreateWithHooks => authorizes the caller (see authorizeWrite), calls the BeforeCreate hooks of the resource
+// and then the model, saves the model and calls the AfterCreate hooks of the model and then the resource
+// NOTE: an authorization error, or an error returned by a BeforeCreate hook or save, aborts the request,
+// i.e., nothing else is called; pass nil for a model that has no hooks
+// NOTE: a successful save publishes an Event to Events, see EventBus
+// EX: UnmarshalRequest => MapToModel => CreateWithHooks => MapFromModel => HandlePostResponse
+func CreateWithHooks(jasi JSONApiServerInfo, resource Resourcer, model interface{}, save func() *JsonApiError) *JsonApiError {
+	return withHooks(jasi, ActionCreate, resource, model, save)
+}
+
+// UpdateWithHooks => calls the BeforeUpdate and AfterUpdate hooks around save, see CreateWithHooks
+// NOTE: the event's before values are those of the resource type's repository, if one is registered,
+// otherwise the attributes and relationships present in the request document are reported as changed
+func UpdateWithHooks(jasi JSONApiServerInfo, resource Resourcer, model interface{}, save func() *JsonApiError) *JsonApiError {
+	return withHooks(jasi, ActionUpdate, resource, model, save)
+}
+
+// DeleteWithHooks => calls the BeforeDelete and AfterDelete hooks around remove, see CreateWithHooks
+// EX: DeleteWithHooks => HandleDeleteResponse
+func DeleteWithHooks(jasi JSONApiServerInfo, resource Resourcer, model interface{}, remove func() *JsonApiError) *JsonApiError {
+	return withHooks(jasi, ActionDelete, resource, model, remove)
+}
+
+// withHooks => calls the action's Before hooks, the operation and then the action's After hooks in reverse order
+func withHooks(jasi JSONApiServerInfo, action Action, resource Resourcer, model interface{}, operation func() *JsonApiError) *JsonApiError {
+	if err := authorizeWrite(jasi, action, resource); err != nil {
+		return err
+	}
+
+	values := []interface{}{resource}
+	if model != nil {
+		values = append(values, model)
+	}
+
+	for _, v := range values {
+		if err := beforeHook(jasi, action, v); err != nil {
+			return err
+		}
+	}
+
+	// NOTE: events are only built if someone receives them, since the before state may cost a query
+	publish := Events.Subscribed(resourceType(resource), action)
+	var before *snapshot
+	if publish {
+		switch action {
+		case ActionCreate:
+			before = &snapshot{}
+		case ActionUpdate:
+			before = previousSnapshot(resource)
+		case ActionDelete:
+			before = takeSnapshot(resource)
+		}
+	}
+
+	if err := operation(); err != nil {
+		return err
+	}
+
+	for i := len(values) - 1; i >= 0; i-- {
+		afterHook(jasi, action, values[i])
+	}
+
+	if publish {
+		var after *snapshot
+		if action != ActionDelete {
+			after = takeSnapshot(resource)
+		}
+		Events.Publish(newEvent(jasi, action, resource, model, before, after))
+	}
+	return nil
+}
+
+// authorizeWrite => returns a 403 or 404 error if the caller may not perform the action against the resource,
+// i.e., against its stored record (see RegisterRepository) when it is updated or deleted, since a resource
+// unmarshalled from a PATCH request only holds the values of its request document
+// NOTE: a created resource is checked against the Policy, but not against the request's tenant,
+// which the app may only set in a BeforeCreate hook
+func authorizeWrite(jasi JSONApiServerInfo, action Action, resource Resourcer) *JsonApiError {
+	t := resourceType(resource)
+	if action == ActionCreate {
+		if err := Authorize(jasi, action, t); err != nil {
+			return err
+		}
+		if PolicyFor(t).AuthorizeRecord(jasi.Caller, action, resource) != Allow {
+			return forbiddenError(action, t)
+		}
+		return nil
+	}
+
+	var record jsonapi.MarshalIdentifier = resource
+	if repository, ok := RepositoryFor(t); ok && resource.GetID() != "" {
+		if stored, err := repository.FindOne(resource.GetID()); err == nil {
+			if identifier, ok := stored.(jsonapi.MarshalIdentifier); ok {
+				record = identifier
+			}
+		}
+	}
+	return AuthorizeResource(jasi, action, record)
+}
+
+// beforeHook => calls the value's Before hook for the action, if it has one
+func beforeHook(jasi JSONApiServerInfo, action Action, v interface{}) *JsonApiError {
+	switch action {
+	case ActionCreate:
+		if hook, ok := v.(BeforeCreater); ok {
+			return hook.BeforeCreate(jasi)
+		}
+	case ActionUpdate:
+		if hook, ok := v.(BeforeUpdater); ok {
+			return hook.BeforeUpdate(jasi)
+		}
+	case ActionDelete:
+		if hook, ok := v.(BeforeDeleter); ok {
+			return hook.BeforeDelete(jasi)
+		}
+	}
+	return nil
+}
+
+// afterHook => calls the value's After hook for the action, if it has one
+func afterHook(jasi JSONApiServerInfo, action Action, v interface{}) {
+	switch action {
+	case ActionCreate:
+		if hook, ok := v.(AfterCreater); ok {
+			hook.AfterCreate(jasi)
+		}
+	case ActionUpdate:
+		if hook, ok := v.(AfterUpdater); ok {
+			hook.AfterUpdate(jasi)
+		}
+	case ActionDelete:
+		if hook, ok := v.(AfterDeleter); ok {
+			hook.AfterDelete(jasi)
+		}
+	}
+}
+
+// afterLoad => calls the AfterLoad hook of the data's values, or the data itself, and returns the data
+// NOTE: values are passed by pointer so that pointer receivers may change them,
+// i.e., a slice's elements are changed in place and a struct is copied and the changed copy is returned
+func afterLoad(jasi JSONApiServerInfo, data interface{}) interface{} {
+	val := reflect.ValueOf(data)
+	if val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			elem := val.Index(i)
+			if elem.Kind() != reflect.Ptr {
+				elem = elem.Addr()
+			}
+			if hook, ok := elem.Interface().(AfterLoader); ok {
+				hook.AfterLoad(jasi)
+			}
+		}
+		return data
+	}
+
+	if val.Kind() == reflect.Struct {
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		if hook, ok := ptr.Interface().(AfterLoader); ok {
+			hook.AfterLoad(jasi)
+		}
+		return ptr.Elem().Interface()
+	}
+
+	if hook, ok := data.(AfterLoader); ok {
+		hook.AfterLoad(jasi)
+	}
+	return data
+}
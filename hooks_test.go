@@ -0,0 +1,136 @@
+package gsonapi
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var hookCalls []string
+
+// Lot Resource (w/ lifecycle hooks)
+type LotResource struct {
+	Resource `jsonapi:"-"`
+	Name     string `json:"name,omitempty" jsonapi:"name=name"`
+	Spaces   int    `json:"spaces,omitempty" jsonapi:"name=spaces"`
+	Full     bool   `json:"full,omitempty" jsonapi:"name=full;readonly"`
+}
+
+func (r LotResource) GetName() string {
+	return "lots"
+}
+
+func (r *LotResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *LotResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+func (r *LotResource) BeforeCreate(jasi JSONApiServerInfo) *JsonApiError {
+	hookCalls = append(hookCalls, "resource:before-create")
+	if r.Spaces < 0 {
+		return NewError(422).Detail("spaces cannot be negative").Build()
+	}
+	return nil
+}
+
+func (r *LotResource) AfterCreate(jasi JSONApiServerInfo) {
+	hookCalls = append(hookCalls, "resource:after-create")
+}
+
+func (r *LotResource) BeforeDelete(jasi JSONApiServerInfo) *JsonApiError {
+	hookCalls = append(hookCalls, "resource:before-delete")
+	return nil
+}
+
+func (r *LotResource) AfterLoad(jasi JSONApiServerInfo) {
+	r.Full = r.Spaces == 0
+}
+
+type LotModel struct {
+	ID     string
+	Name   string
+	Spaces int
+}
+
+func (m *LotModel) BeforeCreate(jasi JSONApiServerInfo) *JsonApiError {
+	hookCalls = append(hookCalls, "model:before-create")
+	return nil
+}
+
+func (m *LotModel) AfterCreate(jasi JSONApiServerInfo) {
+	hookCalls = append(hookCalls, "model:after-create")
+}
+
+func (m *LotModel) BeforeUpdate(jasi JSONApiServerInfo) *JsonApiError {
+	hookCalls = append(hookCalls, "model:before-update")
+	return NewError(409).Detail("the lot is locked").Build()
+}
+
+func (m *LotModel) AfterUpdate(jasi JSONApiServerInfo) {
+	hookCalls = append(hookCalls, "model:after-update")
+}
+
+var _ = Describe("Hooks", func() {
+	save := func() *JsonApiError {
+		hookCalls = append(hookCalls, "save")
+		return nil
+	}
+
+	BeforeEach(func() {
+		hookCalls = nil
+	})
+
+	It("should call the create hooks of the resource and model in order", func() {
+		r := LotResource{Name: "North", Spaces: 10}
+		Ω(CreateWithHooks(TEST_SERVER_INFO, &r, &LotModel{}, save)).Should(BeNil())
+		Ω(hookCalls).Should(Equal([]string{
+			"resource:before-create", "model:before-create", "save", "model:after-create", "resource:after-create",
+		}))
+	})
+
+	It("should abort w/ the error of a Before hook", func() {
+		r := LotResource{Name: "North", Spaces: -1}
+		err := CreateWithHooks(TEST_SERVER_INFO, &r, &LotModel{}, save)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Status).Should(Equal("422"))
+		Ω(err.Detail).Should(Equal("spaces cannot be negative"))
+		Ω(hookCalls).Should(Equal([]string{"resource:before-create"}))
+
+		hookCalls = nil
+		err = UpdateWithHooks(TEST_SERVER_INFO, &r, &LotModel{}, save)
+		Ω(err.Status).Should(Equal("409"))
+		Ω(hookCalls).Should(Equal([]string{"model:before-update"}))
+	})
+
+	It("should not call the After hooks when the operation fails", func() {
+		r := LotResource{Name: "North", Spaces: 10}
+		err := CreateWithHooks(TEST_SERVER_INFO, &r, nil, func() *JsonApiError {
+			return NewError(500).Detail("disk full").Build()
+		})
+		Ω(err.Detail).Should(Equal("disk full"))
+		Ω(hookCalls).Should(Equal([]string{"resource:before-create"}))
+	})
+
+	It("should call the delete hooks of a resource w/o a model", func() {
+		Ω(DeleteWithHooks(TEST_SERVER_INFO, &LotResource{}, nil, save)).Should(BeNil())
+		Ω(hookCalls).Should(Equal([]string{"resource:before-delete", "save"}))
+	})
+
+	It("should call the AfterLoad hook of a loaded resource", func() {
+		r := afterLoad(TEST_SERVER_INFO, LotResource{Spaces: 0}).(LotResource)
+		Ω(r.Full).Should(BeTrue())
+
+		p := &LotResource{Spaces: 0}
+		afterLoad(TEST_SERVER_INFO, p)
+		Ω(p.Full).Should(BeTrue())
+	})
+
+	It("should call the AfterLoad hook of each loaded resource", func() {
+		lots := []LotResource{{Spaces: 0}, {Spaces: 5}}
+		afterLoad(TEST_SERVER_INFO, lots)
+		Ω(lots[0].Full).Should(BeTrue())
+		Ω(lots[1].Full).Should(BeFalse())
+	})
+})
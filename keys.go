@@ -0,0 +1,215 @@
+package gsonapi
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// KeyFormatter => formats attribute and relationship names for the wire and parses them back
+// NOTE: resources declare canonical names (see attributeName), EX: `jsonapi:"name=body-style"`;
+// a KeyFormatter w/o functions leaves the names unchanged
+// EX: KeyFormatter{Format: strings.ToUpper, Parse: strings.ToLower}
+type KeyFormatter struct {
+	Format func(name string) string // EX: body-style => bodyStyle
+	Parse  func(key string) string  // EX: bodyStyle => body-style, see parseKey
+}
+
+var (
+	// DeclaredKeys => the declared names, EX: bodyStyle for an untagged BodyStyle field and sold_at for `jsonapi:"name=sold_at"`
+	DeclaredKeys = KeyFormatter{}
+	// DasherizedKeys => EX: body-style
+	DasherizedKeys = KeyFormatter{Format: dasherize, Parse: dasherize}
+	// CamelCaseKeys => EX: bodyStyle
+	CamelCaseKeys = KeyFormatter{Format: camelize, Parse: dasherize}
+	// SnakeCaseKeys => EX: body_style
+	SnakeCaseKeys = KeyFormatter{Format: underscore, Parse: dasherize}
+)
+
+// KeyFormat => the casing of the attribute and relationship names of request and response documents,
+// sort, filter and fields query parameters, error pointers and the OpenAPI document
+// NOTE: set it once, before serving requests, EX: gsonapi.KeyFormat = gsonapi.CamelCaseKeys
+var KeyFormat = DeclaredKeys
+
+// formatKey => a canonical name formatted for the wire
+func formatKey(name string) string {
+	if KeyFormat.Format == nil {
+		return name
+	}
+	return KeyFormat.Format(name)
+}
+
+// parseKey => a key from the wire parsed back into its canonical name
+// NOTE: a KeyFormatter w/o Parse leaves keys unchanged, see canonicalKeys
+func parseKey(key string) string {
+	if KeyFormat.Parse == nil {
+		return key
+	}
+	return KeyFormat.Parse(key)
+}
+
+// canonicalKeys => renames the attribute or relationship keys of a request document to the resource's names
+// NOTE: keys that match none of the formatted names are parsed (see parseKey) and matched against the names
+// in any casing, EX: bodyStyle => body-style; keys that still match none are left unchanged
+func canonicalKeys(names []string, members map[string]interface{}) {
+	canonical := map[string]string{}
+	for _, name := range names {
+		canonical[formatKey(name)] = name
+	}
+
+	for k, v := range members {
+		name, ok := canonical[k]
+		if !ok {
+			name = declaredName(names, parseKey(k))
+		}
+		if name != k {
+			delete(members, k)
+			members[name] = v
+		}
+	}
+}
+
+// parseKeys => renames the keys of a request document's attributes to their parsed names, see parseKey
+func parseKeys(members map[string]interface{}) {
+	for k, v := range members {
+		if name := parseKey(k); name != k {
+			delete(members, k)
+			members[name] = v
+		}
+	}
+}
+
+// declaredName => the name a key refers to, matched exactly or in any casing, or the key itself if none matches
+// EX: BodyStyle => body-style
+func declaredName(names []string, key string) string {
+	for _, name := range names {
+		if name == key {
+			return name
+		}
+	}
+	for _, name := range names {
+		if dasherize(name) == dasherize(key) {
+			return name
+		}
+	}
+	return key
+}
+
+// formatKeys => renames the attribute and relationship keys of a marshalled response's resource objects,
+// as well as the keys of their nested attributes
+func formatKeys(data interface{}, response interface{}) {
+	types := map[string]reflect.Type{}
+	for _, v := range marshalledValues(data) {
+		types[resourceType(v)] = reflect.Indirect(reflect.ValueOf(v)).Type()
+	}
+
+	for _, entry := range responseEntries(response) {
+		t, _ := entry["type"].(string)
+		for _, member := range []string{"attributes", "relationships"} {
+			members, ok := entry[member].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			formatted := make(map[string]interface{}, len(members))
+			for k, v := range members {
+				if member == "attributes" && types[t] != nil {
+					if field, ok := attributeField(types[t], k); ok && isNestedType(field.Type) {
+						v = formatNestedKeys(v, field.Type)
+					}
+				}
+				formatted[formatKey(k)] = v
+			}
+			entry[member] = formatted
+		}
+	}
+}
+
+// formatNestedKeys => renames the keys of a marshalled nested attribute of the given type
+func formatNestedKeys(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i := range v {
+				v[i] = formatNestedKeys(v[i], t.Elem())
+			}
+		}
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return v
+		}
+		formatted := make(map[string]interface{}, len(v))
+		for k, nested := range v {
+			if i, ok := nestedField(t, k); ok {
+				nested = formatNestedKeys(nested, t.Field(i).Type)
+			}
+			formatted[formatKey(k)] = nested
+		}
+		return formatted
+	}
+	return value
+}
+
+// attributeField => the field of a resource type's attribute
+func attributeField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); isAttribute(field) && attributeName(field) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// keyWords => the lower case words of a name in any casing
+// EX: body-style, bodyStyle, BodyStyle and body_style => [body style]
+func keyWords(name string) []string {
+	words := []string{}
+	word := []rune{}
+	runes := []rune(name)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = []rune{}
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0:
+			// NOTE: acronyms are one word, EX: VINNumber => [vin number]
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return words
+}
+
+func dasherize(name string) string {
+	return strings.Join(keyWords(name), "-")
+}
+
+func underscore(name string) string {
+	return strings.Join(keyWords(name), "_")
+}
+
+func camelize(name string) string {
+	words := keyWords(name)
+	for i := 1; i < len(words); i++ {
+		runes := []rune(words[i])
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, "")
+}
@@ -0,0 +1,160 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/manyminds/api2go/jsonapi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Coupe Resource (w/ attribute names in various casings)
+type CoupeResource struct {
+	Resource  `jsonapi:"-"`
+	BodyStyle null.String    `json:"bodyStyle,omitempty"`
+	SoldAt    null.String    `json:"sold_at,omitempty" jsonapi:"name=sold_at"`
+	LastVisit *VisitResource `json:"last-visit,omitempty" jsonapi:"name=last-visit"`
+}
+
+type VisitResource struct {
+	ServicedBy string `json:"serviced-by" validate:"required"`
+}
+
+func (r CoupeResource) GetName() string {
+	return "coupes"
+}
+
+func (r *CoupeResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *CoupeResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+var _ = Describe("Keys", func() {
+	AfterEach(func() {
+		KeyFormat = DeclaredKeys
+	})
+
+	It("should format names in any casing", func() {
+		for _, name := range []string{"body-style", "bodyStyle", "BodyStyle", "body_style"} {
+			Ω(dasherize(name)).Should(Equal("body-style"))
+			Ω(camelize(name)).Should(Equal("bodyStyle"))
+			Ω(underscore(name)).Should(Equal("body_style"))
+		}
+		Ω(dasherize("VINNumber")).Should(Equal("vin-number"))
+		Ω(camelize("line1")).Should(Equal("line1"))
+	})
+
+	It("should leave the declared names unchanged by default", func() {
+		car := CoupeResource{BodyStyle: null.StringFrom("coupe"), SoldAt: null.StringFrom("2016")}
+		car.SetID("c1")
+
+		var response map[string]interface{}
+		j, err := jsonapi.MarshalToJSONWithURLs(car, TEST_SERVER_INFO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(json.Unmarshal(j, &response)).Should(Succeed())
+		formatKeys(car, response)
+
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		Ω(attributes).Should(HaveKeyWithValue("bodyStyle", "coupe"))
+		Ω(attributes).Should(HaveKeyWithValue("sold_at", "2016"))
+
+		r := CoupeResource{}
+		body := []byte(`{"data":{"type":"coupes","id":"c1","attributes":{"bodyStyle":"sedan","sold_at":"2017"}}}`)
+		Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(r.BodyStyle.String).Should(Equal("sedan"))
+		Ω(r.SoldAt.String).Should(Equal("2017"))
+	})
+
+	It("should match the keys of a request to the declared names in any casing", func() {
+		KeyFormat = CamelCaseKeys
+		r := CoupeResource{}
+		body := []byte(`{"data":{"type":"coupes","id":"c1","attributes":{"bodyStyle":"sedan","soldAt":"2017"}}}`)
+		Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(r.BodyStyle.String).Should(Equal("sedan"))
+		Ω(r.SoldAt.String).Should(Equal("2017"))
+	})
+
+	It("should format the keys and error pointers of nested attributes", func() {
+		KeyFormat = CamelCaseKeys
+		car := CoupeResource{LastVisit: &VisitResource{ServicedBy: "Bob"}}
+		car.SetID("c1")
+
+		var response map[string]interface{}
+		j, err := jsonapi.MarshalToJSONWithURLs(car, TEST_SERVER_INFO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(json.Unmarshal(j, &response)).Should(Succeed())
+		formatKeys(car, response)
+
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		Ω(attributes).Should(HaveKeyWithValue("lastVisit", map[string]interface{}{"servicedBy": "Bob"}))
+
+		r := CoupeResource{}
+		body := []byte(`{"data":{"type":"coupes","id":"c1","attributes":{"lastVisit":{"servicedBy":""}}}}`)
+		errors := UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)
+		Ω(errors).Should(HaveLen(1))
+		Ω(errors[0].Source.Pointer).Should(Equal("/data/attributes/lastVisit/servicedBy"))
+	})
+
+	It("should use the custom functions of a KeyFormatter", func() {
+		KeyFormat = KeyFormatter{Format: strings.ToUpper}
+		Ω(formatKey("body-style")).Should(Equal("BODY-STYLE"))
+		Ω(parseKey("BODY-STYLE")).Should(Equal("BODY-STYLE"))
+		Ω(attributePointer("body-style")).Should(Equal("/data/attributes/BODY-STYLE"))
+	})
+
+	It("should format the keys of a response", func() {
+		KeyFormat = SnakeCaseKeys
+		car := AutomobileResource{}
+		car.SetID("a1")
+		car.BodyStyle.SetValid("coupe")
+
+		var response map[string]interface{}
+		j, err := jsonapi.MarshalToJSONWithURLs(car, TEST_SERVER_INFO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(json.Unmarshal(j, &response)).Should(Succeed())
+		formatKeys(car, response)
+
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		Ω(attributes).Should(HaveKeyWithValue("body_style", "coupe"))
+		Ω(attributes).ShouldNot(HaveKey("body-style"))
+	})
+
+	It("should parse the keys of a request", func() {
+		KeyFormat = CamelCaseKeys
+		r := SedanResource{}
+		body := []byte(`{"data":{"type":"sedans","id":"1","attributes":{"brand":"Mazda","bodyStyle":"coupe"}}}`)
+		Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(r.Style.String).Should(Equal("coupe"))
+		Ω(r.Has("body-style")).Should(BeTrue())
+	})
+
+	It("should match the keys of a request to a custom format's names", func() {
+		KeyFormat = KeyFormatter{Format: strings.ToUpper}
+		r := SedanResource{}
+		body := []byte(`{"data":{"type":"sedans","id":"1","attributes":{"BODY-STYLE":"coupe"}}}`)
+		Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(r.Style.String).Should(Equal("coupe"))
+	})
+
+	It("should parse the attribute names of query parameters", func() {
+		KeyFormat = CamelCaseKeys
+		q, err := ParseQuery(url.Values{"sort": {"-bodyStyle"}, "filter[bodyStyle]": {"coupe"}, "fields[sedans]": {"bodyStyle,brand"}})
+		Ω(err).Should(BeNil())
+		Ω(q.Sort).Should(Equal([]SortField{{Attribute: "body-style", Descending: true}}))
+		Ω(q.Filters).Should(Equal([]Filter{{Attribute: "body-style", Values: []string{"coupe"}}}))
+		Ω(q.Fields["sedans"]).Should(Equal([]string{"body-style", "brand"}))
+	})
+
+	It("should format the pointers of errors", func() {
+		KeyFormat = CamelCaseKeys
+		r := AutomobileResource{}
+		r.AddError(AttributeError{Key: "BodyStyle", Message: "is invalid"})
+		Ω(r.Errors()[0].Source.Pointer).Should(Equal("/data/attributes/bodyStyle"))
+	})
+})
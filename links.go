@@ -0,0 +1,97 @@
+package gsonapi
+
+import (
+	"strings"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// ResourceLinker => implemented by resources that render links in their resource object
+// EX: {"self": ResourceURL(jasi, r), "invoice": ResourceURL(jasi, invoice)}
+type ResourceLinker interface {
+	GetLinks(jasi JSONApiServerInfo) map[string]interface{}
+}
+
+// ResourceMetaer => implemented by resources that render meta in their resource object
+// EX: {"mileage-since-inspection": 1200}
+type ResourceMetaer interface {
+	GetMeta() map[string]interface{}
+}
+
+// RelationshipLinker => implemented by resources that render additional links in their relationship objects
+// NOTE: the links are merged w/ (and override) the relationship's self and related links
+type RelationshipLinker interface {
+	GetRelationshipLinks(jasi JSONApiServerInfo, name string) map[string]interface{}
+}
+
+// RelationshipMetaer => implemented by resources that render meta in their relationship objects
+// EX: {"count": 42} for a to-many relationship whose related resources are not loaded
+// NOTE: a relation tag's count option renders the number of loaded related resources,
+// EX: `jsonapi:"relation=drivers,count"`
+type RelationshipMetaer interface {
+	GetRelationshipMeta(name string) map[string]interface{}
+}
+
+// ResourceURL => the url of a resource, EX: https://my.domain/v1/automobiles/1
+func ResourceURL(jasi JSONApiServerInfo, resource jsonapi.MarshalIdentifier) string {
+	url := strings.Trim(jasi.GetBaseURL(), "/")
+	if prefix := strings.Trim(jasi.GetPrefix(), "/"); prefix != "" {
+		url += "/" + prefix
+	}
+	return url + "/" + resourceType(resource) + "/" + resource.GetID()
+}
+
+// renderLinksAndMeta => adds a resource's links and meta, and those of its relationships, to its resource object
+func renderLinksAndMeta(jasi JSONApiServerInfo, resource interface{}, entry map[string]interface{}) {
+	if linker, ok := resource.(ResourceLinker); ok {
+		if links := linker.GetLinks(jasi); len(links) > 0 {
+			entry["links"] = links
+		}
+	}
+	if metaer, ok := resource.(ResourceMetaer); ok {
+		if meta := metaer.GetMeta(); len(meta) > 0 {
+			entry["meta"] = meta
+		}
+	}
+
+	relationships, _ := entry["relationships"].(map[string]interface{})
+	for name, r := range relationships {
+		relationship, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if linker, ok := resource.(RelationshipLinker); ok {
+			if custom := linker.GetRelationshipLinks(jasi, name); len(custom) > 0 {
+				links := map[string]interface{}{}
+				switch t := relationship["links"].(type) {
+				case map[string]interface{}:
+					for k, v := range t {
+						links[k] = v
+					}
+				case map[string]string:
+					for k, v := range t {
+						links[k] = v
+					}
+				}
+				for k, v := range custom {
+					links[k] = v
+				}
+				relationship["links"] = links
+			}
+		}
+
+		meta := map[string]interface{}{}
+		if relation, ok := getRelation(resource, name); ok && relation.Count {
+			meta["count"] = len(relatedValues(resource, relation))
+		}
+		if metaer, ok := resource.(RelationshipMetaer); ok {
+			for k, v := range metaer.GetRelationshipMeta(name) {
+				meta[k] = v
+			}
+		}
+		if len(meta) > 0 {
+			relationship["meta"] = meta
+		}
+	}
+}
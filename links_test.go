@@ -0,0 +1,125 @@
+package gsonapi
+
+import (
+	"encoding/json"
+
+	"github.com/manyminds/api2go/jsonapi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Depot Resource (w/ resource and relationship links and meta)
+type DepotResource struct {
+	Resource  `jsonapi:"-"`
+	Name      string           `json:"name,omitempty" jsonapi:"name=name"`
+	Drivers   []DriverResource `json:"-" jsonapi:"relation=drivers,count"`
+	ManagerID string           `json:"-" jsonapi:"relation=manager,type=drivers"`
+	Mileage   int              `json:"-" jsonapi:"-"`
+	InvoiceID string           `json:"-" jsonapi:"-"`
+}
+
+func (r DepotResource) GetName() string {
+	return "depots"
+}
+
+func (r DepotResource) GetLinks(jasi JSONApiServerInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"self":    ResourceURL(jasi, r),
+		"invoice": ResourceURL(jasi, ResourceIdentifier{Type: "invoices", ID: r.InvoiceID}),
+	}
+}
+
+func (r DepotResource) GetMeta() map[string]interface{} {
+	return map[string]interface{}{"mileage-since-inspection": r.Mileage}
+}
+
+func (r DepotResource) GetRelationshipLinks(jasi JSONApiServerInfo, name string) map[string]interface{} {
+	if name != "drivers" {
+		return nil
+	}
+	return map[string]interface{}{"first": ResourceURL(jasi, r) + "/drivers?page[number]=1"}
+}
+
+func (r DepotResource) GetRelationshipMeta(name string) map[string]interface{} {
+	if name != "manager" {
+		return nil
+	}
+	return map[string]interface{}{"since": 2015}
+}
+
+var _ = Describe("Links", func() {
+	render := func(data interface{}) map[string]interface{} {
+		var response map[string]interface{}
+		j, err := jsonapi.MarshalToJSONWithURLs(data, TEST_SERVER_INFO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(json.Unmarshal(j, &response)).Should(Succeed())
+		Ω(renderRelationships(TEST_SERVER_INFO, data, response)).Should(Succeed())
+		return response
+	}
+
+	depot := func() DepotResource {
+		driver := DriverResource{Name: "Bob"}
+		driver.SetID("d1")
+		r := DepotResource{Name: "North", Drivers: []DriverResource{driver}, ManagerID: "d2", Mileage: 1200, InvoiceID: "i1"}
+		r.SetID("p1")
+		return r
+	}
+
+	It("should build a resource's url", func() {
+		Ω(ResourceURL(TEST_SERVER_INFO, ResourceIdentifier{Type: "trailers", ID: "t1"})).Should(Equal("http://my.domain/v1/trailers/t1"))
+		Ω(ResourceURL(JSONApiServerInfo{BaseURL: "http://my.domain/"}, ResourceIdentifier{Type: "trailers", ID: "t1"})).Should(Equal("http://my.domain/trailers/t1"))
+	})
+
+	It("should parse the count option of a relation tag", func() {
+		relation, ok := getRelation(DepotResource{}, "drivers")
+		Ω(ok).Should(BeTrue())
+		Ω(relation.Count).Should(BeTrue())
+		relation, _ = getRelation(DepotResource{}, "manager")
+		Ω(relation.Count).Should(BeFalse())
+	})
+
+	It("should render a resource's links and meta", func() {
+		entry := render(depot())["data"].(map[string]interface{})
+		Ω(entry["links"]).Should(Equal(map[string]interface{}{
+			"self":    "http://my.domain/v1/depots/p1",
+			"invoice": "http://my.domain/v1/invoices/i1",
+		}))
+		Ω(entry["meta"]).Should(Equal(map[string]interface{}{"mileage-since-inspection": 1200}))
+	})
+
+	It("should render a relationship's links and meta", func() {
+		relationships := render(depot())["data"].(map[string]interface{})["relationships"].(map[string]interface{})
+
+		drivers := relationships["drivers"].(map[string]interface{})
+		Ω(drivers["links"]).Should(Equal(map[string]interface{}{
+			"self":    "http://my.domain/v1/depots/p1/relationships/drivers",
+			"related": "http://my.domain/v1/depots/p1/drivers",
+			"first":   "http://my.domain/v1/depots/p1/drivers?page[number]=1",
+		}))
+		Ω(drivers["meta"]).Should(Equal(map[string]interface{}{"count": 1}))
+
+		manager := relationships["manager"].(map[string]interface{})
+		Ω(manager["meta"]).Should(Equal(map[string]interface{}{"since": 2015}))
+		Ω(manager["links"]).Should(HaveLen(2))
+	})
+
+	It("should render the meta of each resource in a collection", func() {
+		second := depot()
+		second.SetID("p2")
+		second.Mileage = 300
+		second.Drivers = nil
+
+		data := render([]DepotResource{depot(), second})["data"].([]interface{})
+		Ω(data[1].(map[string]interface{})["meta"]).Should(Equal(map[string]interface{}{"mileage-since-inspection": 300}))
+		drivers := data[1].(map[string]interface{})["relationships"].(map[string]interface{})["drivers"]
+		Ω(drivers.(map[string]interface{})["meta"]).Should(Equal(map[string]interface{}{"count": 0}))
+	})
+
+	It("should not render links or meta for resources that do not implement them", func() {
+		car := AutomobileResource{}
+		car.SetID("a1")
+		entry := render(car)["data"].(map[string]interface{})
+		Ω(entry).ShouldNot(HaveKey("meta"))
+		Ω(entry).ShouldNot(HaveKey("links"))
+	})
+})
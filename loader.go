@@ -0,0 +1,206 @@
+package gsonapi
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-martini/martini"
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// Loader => loads related records w/ one Repository.FindMany per resource type
+// and caches them for the lifetime of a request, see LoaderHandler
+// EX: loading the drivers of 50 automobiles is one FindMany instead of one FindOne per driver
+type Loader struct {
+	mutex   sync.Mutex
+	records map[string]map[string]interface{} // pointers to the records keyed by type and id, nil if not found
+}
+
+// NewLoader => an empty loader
+func NewLoader() *Loader {
+	return &Loader{records: map[string]map[string]interface{}{}}
+}
+
+// LoaderHandler => martini middleware that maps a new *Loader for each request,
+// as well as the JSONApiServerInfo mapped before it w/ its Loader set (or a new one if none was mapped)
+// NOTE: HandleIndexResponse and HandleGetResponse populate the relationships of the rendered resources
+// w/ the JSONApiServerInfo's Loader, see Loader.Populate
+func LoaderHandler() martini.Handler {
+	return func(c martini.Context) {
+		jasi := JSONApiServerInfo{}
+		if mapped := c.Get(reflect.TypeOf(jasi)); mapped.IsValid() {
+			jasi = mapped.Interface().(JSONApiServerInfo)
+		}
+		jasi.Loader = NewLoader()
+
+		c.Map(jasi.Loader)
+		c.Map(jasi)
+	}
+}
+
+// Load => fetches the records of the ids that are not cached yet w/ one FindMany
+// NOTE: returns a 500 error if no repository is registered for the resource type, see RegisterRepository
+func (l *Loader) Load(resourceType string, ids []string) *JsonApiError {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	cached := l.records[resourceType]
+	if cached == nil {
+		cached = map[string]interface{}{}
+		l.records[resourceType] = cached
+	}
+
+	missing := []string{}
+	for _, id := range ids {
+		if _, ok := cached[id]; !ok && !contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	repository, ok := RepositoryFor(resourceType)
+	if !ok {
+		return NewError(500).Detail("no repository for " + resourceType).Build()
+	}
+	records, err := repository.FindMany(missing)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: ids that were not found are cached as nil so that they are not fetched again
+	for _, id := range missing {
+		cached[id] = nil
+	}
+	val := reflect.ValueOf(records)
+	for i := 0; i < val.Len(); i++ {
+		record := val.Index(i)
+		if record.Kind() != reflect.Ptr {
+			record = record.Addr()
+		}
+		cached[relatedID(record)] = record.Interface()
+	}
+	return nil
+}
+
+// Get => a pointer to the record w/ the given type and id, loading it if it is not cached yet,
+// or a 404 error if it does not exist
+// NOTE: does not authorize the caller, e.g., so that MapToModel can link the related records of a request document;
+// call AuthorizeResource before rendering or otherwise returning the record to the caller (Populate does)
+func (l *Loader) Get(resourceType string, id string) (interface{}, *JsonApiError) {
+	if err := l.Load(resourceType, []string{id}); err != nil {
+		return nil, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if record := l.records[resourceType][id]; record != nil {
+		return record, nil
+	}
+	return nil, notFoundError(resourceType)
+}
+
+// LoadReferences => loads the related records of the data's values w/ one FindMany per resource type
+// NOTE: skips the resource types that have no repository
+func (l *Loader) LoadReferences(data interface{}) *JsonApiError {
+	ids := map[string][]string{}
+	for _, v := range primaryValues(data) {
+		for _, reference := range GetReferencedIDs(v) {
+			if reference.ID != "" {
+				ids[reference.Type] = append(ids[reference.Type], reference.ID)
+			}
+		}
+	}
+
+	types := make([]string, 0, len(ids))
+	for t := range ids {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+		if _, ok := RepositoryFor(t); !ok {
+			continue
+		}
+		if err := l.Load(t, ids[t]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Populate => replaces the related resources of the data's relation tag declared relationships
+// w/ the loaded records, e.g., so that included resources have their attributes
+// NOTE: the data must be a slice or a pointer; ID (string) fields, related records that were not loaded
+// and loaded records the caller may not read (see AuthorizeResource) are left unchanged, i.e., linkage only
+func (l *Loader) Populate(jasi JSONApiServerInfo, data interface{}) *JsonApiError {
+	if err := l.LoadReferences(data); err != nil {
+		return err
+	}
+
+	val := reflect.ValueOf(data)
+	resources := []reflect.Value{}
+	switch val.Kind() {
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			resources = append(resources, reflect.Indirect(val.Index(i)))
+		}
+	case reflect.Ptr:
+		resources = append(resources, val.Elem())
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, resource := range resources {
+		if !resource.CanSet() || resource.Kind() != reflect.Struct {
+			continue
+		}
+		for _, relation := range GetRelations(resource.Interface()) {
+			l.populate(jasi, resource.Field(relation.field), relation)
+		}
+	}
+	return nil
+}
+
+// populate => replaces a relation field's related resources w/ the loaded records the caller may read
+func (l *Loader) populate(jasi JSONApiServerInfo, field reflect.Value, relation Relation) {
+	replace := func(v reflect.Value) {
+		if v.Kind() == reflect.String || (v.Kind() == reflect.Ptr && v.IsNil()) {
+			return
+		}
+		actual := v
+		if actual.Kind() == reflect.Interface {
+			if actual.IsNil() {
+				return
+			}
+			actual = actual.Elem()
+		}
+		id := relatedID(actual)
+		record, ok := l.records[relatedType(relation, actual)][id]
+		if !ok || record == nil {
+			return
+		}
+		if identifier, ok := record.(jsonapi.MarshalIdentifier); !ok || AuthorizeResource(jasi, ActionRead, identifier) != nil {
+			return
+		}
+
+		loaded := reflect.ValueOf(record)
+		switch {
+		case loaded.Type().AssignableTo(v.Type()):
+			v.Set(loaded)
+		case loaded.Elem().Type().AssignableTo(v.Type()):
+			v.Set(loaded.Elem())
+		}
+	}
+
+	if relation.ToMany {
+		for i := 0; i < field.Len(); i++ {
+			replace(field.Index(i))
+		}
+	} else {
+		replace(field)
+	}
+}
@@ -0,0 +1,168 @@
+package gsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-martini/martini"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingRepository => counts the FindMany calls of a MemoryRepository
+type countingRepository struct {
+	*MemoryRepository
+	calls [][]string
+}
+
+func (c *countingRepository) FindMany(ids []string) (interface{}, *JsonApiError) {
+	c.calls = append(c.calls, ids)
+	return c.MemoryRepository.FindMany(ids)
+}
+
+// concealPolicy => conceals the record w/ the given id from every caller
+type concealPolicy struct {
+	AllowAll
+	id string
+}
+
+func (p concealPolicy) AuthorizeRecord(caller interface{}, action Action, record jsonapi.MarshalIdentifier) Decision {
+	if record.GetID() == p.id {
+		return Conceal
+	}
+	return Allow
+}
+
+var _ = Describe("Loader", func() {
+	var (
+		drivers  *countingRepository
+		previous Repository
+		loader   *Loader
+	)
+
+	driver := func(id string) DriverResource {
+		r := DriverResource{}
+		r.SetID(id)
+		return r
+	}
+	automobiles := func() []AutomobileResource {
+		a1, a2, a3 := AutomobileResource{}, AutomobileResource{}, AutomobileResource{}
+		a1.SetID("a1")
+		a1.Drivers = []DriverResource{driver("d1"), driver("d2")}
+		a2.SetID("a2")
+		a2.Drivers = []DriverResource{driver("d2")}
+		a3.SetID("a3")
+		a3.Drivers = []DriverResource{driver("d1"), driver("d9")}
+		return []AutomobileResource{a1, a2, a3}
+	}
+
+	BeforeEach(func() {
+		previous, _ = RepositoryFor("drivers")
+		drivers = &countingRepository{MemoryRepository: NewMemoryRepository(DriverResource{})}
+		drivers.Create(&DriverResource{Resource: Resource{ID: "d1"}, Name: "paul", Age: 40})
+		drivers.Create(&DriverResource{Resource: Resource{ID: "d2"}, Name: "steve", Age: 45})
+		RegisterRepository("drivers", drivers)
+		loader = NewLoader()
+	})
+
+	AfterEach(func() {
+		RegisterRepository("drivers", previous)
+	})
+
+	It("should load the references of a collection w/ one FindMany per type", func() {
+		Ω(loader.LoadReferences(automobiles())).Should(BeNil())
+		Ω(drivers.calls).Should(Equal([][]string{{"d1", "d2", "d9"}}))
+	})
+
+	It("should cache the loaded records, including the ones that were not found", func() {
+		Ω(loader.Load("drivers", []string{"d1", "d9"})).Should(BeNil())
+		Ω(loader.Load("drivers", []string{"d9", "d1", "d2"})).Should(BeNil())
+		Ω(drivers.calls).Should(Equal([][]string{{"d1", "d9"}, {"d2"}}))
+
+		record, err := loader.Get("drivers", "d2")
+		Ω(err).Should(BeNil())
+		Ω(record.(*DriverResource).Name).Should(Equal("steve"))
+		_, err = loader.Get("drivers", "d9")
+		Ω(err.Status).Should(Equal("404"))
+		Ω(drivers.calls).Should(HaveLen(2))
+	})
+
+	It("should skip types w/o a repository, unless they are loaded explicitly", func() {
+		dealer := DealerResource{MechanicIDs: []string{"m1"}}
+		Ω(loader.LoadReferences(dealer)).Should(BeNil())
+
+		err := loader.Load("mechanics", []string{"m1"})
+		Ω(err.Status).Should(Equal("500"))
+		Ω(err.Detail).Should(Equal("no repository for mechanics"))
+	})
+
+	It("should populate the related resources of a collection", func() {
+		data := automobiles()
+		Ω(loader.Populate(TEST_SERVER_INFO, data)).Should(BeNil())
+		Ω(drivers.calls).Should(HaveLen(1))
+
+		Ω(data[0].Drivers[0].Name).Should(Equal("paul"))
+		Ω(data[0].Drivers[1].Name).Should(Equal("steve"))
+		Ω(data[1].Drivers[0].Name).Should(Equal("steve"))
+		Ω(data[2].Drivers[1].GetID()).Should(Equal("d9"))
+		Ω(data[2].Drivers[1].Name).Should(BeEmpty())
+	})
+
+	It("should only populate the related resources the caller may read", func() {
+		RegisterPolicy("drivers", concealPolicy{id: "d2"})
+		defer delete(policies, "drivers")
+
+		data := automobiles()
+		Ω(loader.Populate(TEST_SERVER_INFO, data)).Should(BeNil())
+		Ω(data[0].Drivers[0].Name).Should(Equal("paul"))
+		Ω(data[0].Drivers[1].GetID()).Should(Equal("d2"))
+		Ω(data[0].Drivers[1].Name).Should(BeEmpty())
+	})
+
+	It("should record the request's loader on an unmarshalled resource", func() {
+		jasi := TEST_SERVER_INFO
+		jasi.Loader = loader
+		r := AutomobileResource{}
+		body := []byte(`{"data":{"type":"automobiles","relationships":{"drivers":{"data":[{"type":"drivers","id":"d1"}]}}}}`)
+		Ω(UnmarshalRequest(jasi, ActionCreate, body, &r)).Should(BeEmpty())
+		Ω(r.Loader() == loader).Should(BeTrue())
+
+		m := AutomobileModel{}
+		Ω(r.MapToModel(&m)).Should(Succeed())
+		Ω(r.MapToModel(&m)).Should(Succeed())
+		Ω(m.Drivers[0].Name).Should(Equal("paul"))
+		Ω(drivers.calls).Should(HaveLen(1))
+	})
+
+	It("should populate the related resource of a pointer", func() {
+		owner := DriverResource{}
+		owner.SetID("d2")
+		dealer := &DealerResource{Owner: &owner}
+		Ω(loader.Populate(TEST_SERVER_INFO, dealer)).Should(BeNil())
+		Ω(dealer.Owner.Name).Should(Equal("steve"))
+	})
+
+	It("should render the loaded records as included resources", func() {
+		server := martini.Classic()
+		server.Use(render.Renderer())
+		server.Use(func(c martini.Context) {
+			c.Map(TEST_SERVER_INFO)
+		})
+		server.Use(LoaderHandler())
+		server.Get("/v1/automobiles", func(jasi JSONApiServerInfo, loader *Loader, r render.Render) {
+			Ω(jasi.Loader == loader).Should(BeTrue())
+			Ω(jasi.BaseURL).Should(Equal(TEST_SERVER_INFO.BaseURL))
+			HandleIndexResponse(jasi, nil, automobiles(), r)
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/v1/automobiles", nil)
+		server.ServeHTTP(recorder, request)
+
+		Ω(recorder.Code).Should(Equal(200))
+		Ω(recorder.Body.String()).Should(ContainSubstring(`"name":"steve"`))
+		Ω(drivers.calls).Should(HaveLen(1))
+	})
+})
@@ -0,0 +1,184 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// isNestedType => true for attribute types that api2go cannot decode, i.e., structs,
+// pointers to structs and slices of them (excluding time.Time and json.Unmarshalers such as null.String)
+// EX: InspectionResource, *InspectionResource, []InspectionResource or []*InspectionResource
+func isNestedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return isNestedType(t.Elem())
+	case reflect.Struct:
+		return t != timeType && !reflect.PtrTo(t).Implements(jsonUnmarshalerType)
+	}
+	return false
+}
+
+// nestedFieldName => a nested attribute's name, i.e., the name tag, the json name or the jsonified field name
+func nestedFieldName(field reflect.StructField) string {
+	if name := jsonapi.GetTagValueByName(field, "name"); name != "" {
+		return name
+	}
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return jsonapi.Jsonify(field.Name)
+}
+
+// nestedField => the exported field of a struct w/ the nested attribute name
+// NOTE: the name may be in the KeyFormat's casing, see canonicalKeys
+func nestedField(t reflect.Type, name string) (int, bool) {
+	names, indexes := []string{}, []int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("jsonapi") == "-" || field.Tag.Get("json") == "-" {
+			continue
+		}
+		names, indexes = append(names, nestedFieldName(field)), append(indexes, i)
+	}
+
+	members := map[string]interface{}{name: nil}
+	canonicalKeys(names, members)
+	for i, n := range names {
+		if _, ok := members[n]; ok {
+			return indexes[i], true
+		}
+	}
+	return 0, false
+}
+
+// splitNestedAttributes => removes the resource's nested attributes from the attributes and returns them
+// NOTE: api2go decodes the remaining attributes and decodeNestedAttributes the nested ones
+func splitNestedAttributes(resource interface{}, attributes map[string]interface{}) map[string]interface{} {
+	nested := map[string]interface{}{}
+
+	t := reflect.Indirect(reflect.ValueOf(resource)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isAttribute(field) || !isNestedType(field.Type) {
+			continue
+		}
+		name := attributeName(field)
+		if value, ok := attributes[name]; ok {
+			nested[name] = value
+			delete(attributes, name)
+		}
+	}
+
+	return nested
+}
+
+// decodeNestedAttributes => decodes nested attributes into the resource's typed fields
+// NOTE: returns a 400 error for each unknown key or value of the wrong type,
+// EX: /data/attributes/inspections/1/location
+func decodeNestedAttributes(resource interface{}, nested map[string]interface{}) []JsonApiError {
+	errors := []JsonApiError{}
+
+	rv := reflect.Indirect(reflect.ValueOf(resource))
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !isAttribute(field) {
+			continue
+		}
+		name := attributeName(field)
+		if value, ok := nested[name]; ok {
+			errors = append(errors, decodeNested(value, rv.Field(i), attributePointer(name))...)
+		}
+	}
+
+	return errors
+}
+
+// decodeNested => decodes a json value into a struct, pointer, slice or plain value
+func decodeNested(value interface{}, dst reflect.Value, pointer string) []JsonApiError {
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if !isNestedType(dst.Type()) {
+			break
+		}
+		elem := reflect.New(dst.Type().Elem())
+		errors := decodeNested(value, elem.Elem(), pointer)
+		dst.Set(elem)
+		return errors
+
+	case reflect.Slice, reflect.Array:
+		if !isNestedType(dst.Type()) {
+			break
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			return []JsonApiError{nestedError(pointer, "type", "expected array, not "+jsonType(value))}
+		}
+
+		slice := reflect.New(dst.Type()).Elem()
+		if dst.Kind() == reflect.Slice {
+			slice = reflect.MakeSlice(dst.Type(), len(values), len(values))
+		} else if len(values) > dst.Len() {
+			return []JsonApiError{nestedError(pointer, "max-size", "expected at most "+strconv.Itoa(dst.Len())+" items")}
+		}
+
+		errors := []JsonApiError{}
+		for i, v := range values {
+			errors = append(errors, decodeNested(v, slice.Index(i), pointer+"/"+strconv.Itoa(i))...)
+		}
+		dst.Set(slice)
+		return errors
+
+	case reflect.Struct:
+		if !isNestedType(dst.Type()) {
+			break
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []JsonApiError{nestedError(pointer, "type", "expected object, not "+jsonType(value))}
+		}
+
+		keys := make([]string, 0, len(object))
+		for k := range object {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		errors := []JsonApiError{}
+		for _, k := range keys {
+			i, ok := nestedField(dst.Type(), k)
+			if !ok {
+				errors = append(errors, nestedError(pointer+"/"+pointerToken(k), "", "unknown attribute "+k))
+				continue
+			}
+			errors = append(errors, decodeNested(object[k], dst.Field(i), pointer+"/"+pointerToken(k))...)
+		}
+		return errors
+	}
+
+	// NOTE: plain values, null types and times are decoded by encoding/json
+	b, _ := json.Marshal(value)
+	decoded := reflect.New(dst.Type())
+	if err := json.Unmarshal(b, decoded.Interface()); err != nil {
+		return []JsonApiError{nestedError(pointer, "type", "expected "+strings.Join(schemaTypes(jsonSchema(dst.Type())), " or ")+
+			", not "+jsonType(value))}
+	}
+	dst.Set(decoded.Elem())
+	return nil
+}
+
+func nestedError(pointer string, code string, detail string) JsonApiError {
+	return JsonApiError{Status: "400", Code: code, Title: "Invalid Attribute", Detail: detail,
+		Source: &JsonApiErrorSource{Pointer: pointer}}
+}
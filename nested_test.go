@@ -0,0 +1,134 @@
+package gsonapi
+
+import (
+	"reflect"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Route Resource (w/ nested attributes)
+type RouteResource struct {
+	Resource `jsonapi:"-"`
+	Name     null.String    `json:"name,omitempty" jsonapi:"name=name"`
+	Start    *StopResource  `json:"start,omitempty" jsonapi:"name=start"`
+	Stops    []StopResource `json:"stops,omitempty" jsonapi:"name=stops" validate:"max-size=3"`
+}
+
+type StopResource struct {
+	Name     string      `json:"name,omitempty" jsonapi:"name=name" validate:"required"`
+	Minutes  null.Int    `json:"minutes,omitempty" jsonapi:"name=minutes" validate:"max=60"`
+	Location *string     `json:"location,omitempty" jsonapi:"name=location"`
+	Internal string      `json:"-" jsonapi:"-"`
+	Tags     []string    `json:"tags,omitempty" jsonapi:"name=tags"`
+	Arrival  *time.Time  `json:"arrival,omitempty" jsonapi:"name=arrival"`
+	Parent   *StopParent `json:"parent,omitempty" jsonapi:"name=parent"`
+}
+
+type StopParent struct {
+	Code string `json:"code"`
+}
+
+func (r RouteResource) GetName() string {
+	return "routes"
+}
+
+func (r *RouteResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *RouteResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+var _ = Describe("Nested", func() {
+	pointers := func(errors []JsonApiError) []string {
+		p := []string{}
+		for _, e := range errors {
+			p = append(p, e.Source.Pointer)
+		}
+		return p
+	}
+
+	It("should only consider structs, pointers to structs and slices of them nested", func() {
+		Ω(isNestedType(reflect.TypeOf(StopResource{}))).Should(BeTrue())
+		Ω(isNestedType(reflect.TypeOf(&StopResource{}))).Should(BeTrue())
+		Ω(isNestedType(reflect.TypeOf([]StopResource{}))).Should(BeTrue())
+		Ω(isNestedType(reflect.TypeOf([]*StopResource{}))).Should(BeTrue())
+		Ω(isNestedType(reflect.TypeOf(null.String{}))).Should(BeFalse())
+		Ω(isNestedType(reflect.TypeOf(time.Time{}))).Should(BeFalse())
+		Ω(isNestedType(reflect.TypeOf([]interface{}{}))).Should(BeFalse())
+	})
+
+	Context("UnmarshalRequest", func() {
+		It("should decode a slice of structs", func() {
+			r := AutomobileResource{}
+			body := []byte(`{"data":{"type":"automobiles","attributes":{"make":"Mazda",` +
+				`"inspections":[{"name":"one","location":"richmond"},{"name":"two"}]}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)).Should(BeEmpty())
+			Ω(r.Make).Should(Equal(null.StringFrom("Mazda")))
+			Ω(r.Inspections).Should(Equal([]InspectionResource{{Name: "one", Location: "richmond"}, {Name: "two"}}))
+		})
+
+		It("should decode nested structs, pointers, null types and times", func() {
+			r := RouteResource{}
+			body := []byte(`{"data":{"type":"routes","attributes":{"start":{"name":"home","minutes":5,` +
+				`"location":"richmond","tags":["a","b"],"arrival":"2016-01-02T03:04:05Z","parent":{"code":"X"}},` +
+				`"stops":[{"name":"work","minutes":null}]}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)).Should(BeEmpty())
+			Ω(r.Start.Name).Should(Equal("home"))
+			Ω(r.Start.Minutes).Should(Equal(null.IntFrom(5)))
+			Ω(*r.Start.Location).Should(Equal("richmond"))
+			Ω(r.Start.Tags).Should(Equal([]string{"a", "b"}))
+			Ω(r.Start.Arrival.Equal(time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC))).Should(BeTrue())
+			Ω(r.Start.Parent).Should(Equal(&StopParent{Code: "X"}))
+			Ω(r.Stops).Should(Equal([]StopResource{{Name: "work"}}))
+		})
+
+		It("should decode an explicit null", func() {
+			r := RouteResource{}
+			body := []byte(`{"data":{"type":"routes","id":"1","attributes":{"start":null}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(r.Start).Should(BeNil())
+			Ω(r.IsExplicitNull("start")).Should(BeTrue())
+		})
+
+		It("should point type errors into the structure", func() {
+			r := RouteResource{}
+			body := []byte(`{"data":{"type":"routes","attributes":{"stops":[{"name":"work"},{"name":1}]}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(pointers(errors)).Should(Equal([]string{"/data/attributes/stops/1/name"}))
+			Ω(errors[0].Status).Should(Equal("400"))
+		})
+
+		It("should reject unknown nested attributes", func() {
+			r := AutomobileResource{}
+			body := []byte(`{"data":{"type":"automobiles","attributes":{"inspections":[{"name":"one"},{"city":"richmond"}]}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(pointers(errors)).Should(Equal([]string{"/data/attributes/inspections/1/city"}))
+			Ω(errors[0].Detail).Should(Equal("unknown attribute city"))
+		})
+
+		It("should point validation errors into the structure", func() {
+			r := RouteResource{}
+			body := []byte(`{"data":{"type":"routes","attributes":{"start":{"minutes":90},` +
+				`"stops":[{"name":"work"},{"location":"richmond"}]}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(pointers(errors)).Should(Equal([]string{"/data/attributes/start/name", "/data/attributes/start/minutes",
+				"/data/attributes/stops/1/name"}))
+			Ω(errors[0].Status).Should(Equal("422"))
+			Ω(errors[2].Detail).Should(Equal("Required"))
+		})
+	})
+
+	It("should describe nested attributes by their names", func() {
+		schema := AttributesSchema(RouteResource{}, true)
+		start := schema["properties"].(map[string]interface{})["start"].(map[string]interface{})
+		Ω(start["type"]).Should(Equal([]string{"object", "null"}))
+		properties := start["properties"].(map[string]interface{})
+		Ω(properties).Should(HaveKey("minutes"))
+		Ω(properties).ShouldNot(HaveKey("Internal"))
+	})
+})
@@ -0,0 +1,341 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"gopkg.in/guregu/null.v3"
+)
+
+// OPENAPI_VERSION => the version of the OpenAPI specification the document conforms to
+const OPENAPI_VERSION = "3.1.0"
+
+// JSONAPI_MEDIA_TYPE => the media type of request and response documents
+const JSONAPI_MEDIA_TYPE = "application/vnd.api+json"
+
+// OPENAPI_MEDIA_TYPE => the media type of the OpenAPI document, see OpenAPIHandler
+const OPENAPI_MEDIA_TYPE = "application/vnd.oai.openapi+json"
+
+// OpenAPIInfo => the info object of an OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPI => an OpenAPI 3.1 document that describes the CRUD routes of the registered resources
+// under the JSONApiServerInfo's BaseURL and Prefix
+// NOTE: a {tenant} placeholder in the BaseURL becomes a server variable
+func OpenAPI(jasi JSONApiServerInfo, info OpenAPIInfo) map[string]interface{} {
+	server := map[string]interface{}{"url": jasi.BaseURL}
+	if strings.Contains(jasi.BaseURL, TENANT_PLACEHOLDER) {
+		server["variables"] = map[string]interface{}{"tenant": map[string]interface{}{"default": jasi.Tenant}}
+	}
+	jasi.Tenant = TENANT_PLACEHOLDER
+
+	schemas := map[string]interface{}{
+		"error":  jsonSchema(reflect.TypeOf(JsonApiError{})),
+		"errors": object(map[string]interface{}{"errors": array(ref("error"))}, "errors"),
+	}
+	paths := map[string]interface{}{}
+
+	for _, t := range ResourceTypes() {
+		prototype := reflect.New(registry[t]).Interface()
+		schemas[t] = resourceSchema(t, prototype, false)
+		schemas[t+"-new"] = resourceSchema(t, prototype, true)
+
+		path := ""
+		for _, segment := range strings.Split(jasi.GetPrefix(), "/") {
+			if segment != "" {
+				path += "/" + segment
+			}
+		}
+		path += "/" + t
+
+		// NOTE: a {tenant} placeholder in the Prefix becomes a path parameter
+		parameters := []interface{}{}
+		if strings.Contains(path, TENANT_PLACEHOLDER) {
+			parameters = append(parameters, pathParameter("tenant"))
+		}
+
+		collection := map[string]interface{}{
+			"get": operation("list-"+t, "list "+t, queryParameters(t, prototype), nil,
+				map[string]interface{}{"200": document(array(ref(t)))}, 400, 403),
+			"post": operation("create-"+t, "create a resource of type "+t, nil, ref(t+"-new"),
+				map[string]interface{}{"201": document(ref(t))}, 400, 403, 409, 422),
+		}
+		if len(parameters) > 0 {
+			collection["parameters"] = parameters
+		}
+		paths[path] = collection
+		paths[path+"/{id}"] = map[string]interface{}{
+			"parameters": append(parameters, pathParameter("id")),
+			"get": operation("get-"+t, "get a resource of type "+t, nil, nil,
+				map[string]interface{}{"200": document(ref(t))}, 403, 404),
+			"patch": operation("update-"+t, "update a resource of type "+t, nil, ref(t),
+				map[string]interface{}{"200": document(ref(t))}, 400, 403, 404, 409, 422),
+			"delete": operation("delete-"+t, "delete a resource of type "+t, nil, nil,
+				map[string]interface{}{"204": map[string]interface{}{"description": http.StatusText(204)}}, 403, 404),
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi":    OPENAPI_VERSION,
+		"info":       info,
+		"servers":    []interface{}{server},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// OpenAPIHandler => martini handler that renders the OpenAPI document as OPENAPI_MEDIA_TYPE
+// NOTE: not w/ r.JSON, which would set the Content-Type to application/json
+func OpenAPIHandler(jasi JSONApiServerInfo, info OpenAPIInfo) martini.Handler {
+	return func(r render.Render) {
+		j, err := json.Marshal(OpenAPI(jasi, info))
+		if err != nil {
+			renderError(500, NewError(500).Detail(err.Error()).Build(), r)
+			return
+		}
+		r.Header().Set("Content-Type", OPENAPI_MEDIA_TYPE)
+		r.Data(200, j)
+	}
+}
+
+// ServeOpenAPI => serves the OpenAPI document at the path configured by config.json's "openapi_path"
+func ServeOpenAPI(router martini.Router, jasi JSONApiServerInfo, info OpenAPIInfo) {
+	router.Get(Config.OpenAPIPath, OpenAPIHandler(jasi, info))
+}
+
+// WriteOpenAPI => writes the OpenAPI document to a file, e.g., for client generation
+func WriteOpenAPI(filename string, jasi JSONApiServerInfo, info OpenAPIInfo) error {
+	j, err := json.MarshalIndent(OpenAPI(jasi, info), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(j, '\n'), 0644)
+}
+
+// resourceSchema => the schema of a resource object w/ its attributes and relationships
+// NOTE: the id of a new resource is optional, but its required attributes (see AttributesSchema) are not
+func resourceSchema(t string, prototype interface{}, creating bool) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type":       map[string]interface{}{"const": t},
+		"id":         map[string]interface{}{"type": "string"},
+		"attributes": formatSchemaKeys(AttributesSchema(prototype, creating)),
+	}
+
+	if references := GetReferences(prototype); len(references) > 0 {
+		relationships := map[string]interface{}{}
+		for _, reference := range references {
+			// NOTE: the related resources of a polymorphic relationship have one of its allowed types
+			typeSchema := map[string]interface{}{"const": reference.Type}
+			if relation, ok := getRelation(prototype, reference.Name); ok && relation.Type == "" {
+				typeSchema = map[string]interface{}{"type": "string"}
+				if len(relation.Types) > 0 {
+					typeSchema = map[string]interface{}{"enum": relation.Types}
+				}
+			}
+			identifier := object(map[string]interface{}{
+				"type": typeSchema,
+				"id":   map[string]interface{}{"type": "string"},
+			}, "type", "id")
+
+			data := nullable(identifier)
+			if isToMany(prototype, reference.Name) {
+				data = array(identifier)
+			}
+			relationships[formatKey(reference.Name)] = object(map[string]interface{}{"data": data})
+		}
+		properties["relationships"] = object(relationships)
+	}
+
+	if creating {
+		return object(properties, "type")
+	}
+	return object(properties, "type", "id")
+}
+
+// queryParameters => the query parameters of an index request, see ParseQuery
+func queryParameters(t string, prototype interface{}) []interface{} {
+	filters := map[string]interface{}{}
+	for _, name := range attributeNames(prototype) {
+		filters[formatKey(name)] = map[string]interface{}{"type": "string", "description": "comma separated values, any of which may match"}
+	}
+
+	parameter := func(name string, description string, schema map[string]interface{}) map[string]interface{} {
+		p := map[string]interface{}{"name": name, "in": "query", "description": description, "schema": schema}
+		if schema["type"] == "object" {
+			p["style"] = "deepObject"
+			p["explode"] = true
+		}
+		return p
+	}
+	positive := map[string]interface{}{"type": "integer", "minimum": 1}
+
+	return []interface{}{
+		parameter("include", "comma separated relationship paths", map[string]interface{}{"type": "string"}),
+		parameter("fields", "comma separated fields by type, EX: fields["+t+"]=...",
+			map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}}),
+		parameter("sort", "comma separated attributes, prefixed w/ - for descending order", map[string]interface{}{"type": "string"}),
+		parameter("filter", "filters by attribute, EX: filter[make]=Mazda,Honda", object(filters)),
+		parameter("page", "page[number] and page[size]", object(map[string]interface{}{"number": positive, "size": positive})),
+	}
+}
+
+// pathParameter => a required string path parameter
+func pathParameter(name string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}}
+}
+
+// operation => an operation object whose error statuses render the errors document
+func operation(id string, summary string, parameters []interface{}, body map[string]interface{},
+	responses map[string]interface{}, errorStatuses ...int) map[string]interface{} {
+	for _, status := range errorStatuses {
+		responses[strconv.Itoa(status)] = map[string]interface{}{"description": http.StatusText(status),
+			"content": map[string]interface{}{JSONAPI_MEDIA_TYPE: map[string]interface{}{"schema": ref("errors")}}}
+	}
+
+	op := map[string]interface{}{"operationId": id, "summary": summary, "responses": responses}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	if body != nil {
+		op["requestBody"] = map[string]interface{}{"required": true,
+			"content": map[string]interface{}{JSONAPI_MEDIA_TYPE: map[string]interface{}{
+				"schema": object(map[string]interface{}{"data": body}, "data")}}}
+	}
+	return op
+}
+
+// document => the response object of a document w/ the given primary data
+func document(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": "a JSON:API document",
+		"content": map[string]interface{}{JSONAPI_MEDIA_TYPE: map[string]interface{}{
+			"schema": object(map[string]interface{}{"data": data, "included": array(map[string]interface{}{})}, "data")}},
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	nullIntType    = reflect.TypeOf(null.Int{})
+	nullFloatType  = reflect.TypeOf(null.Float{})
+	nullStringType = reflect.TypeOf(null.String{})
+	nullBoolType   = reflect.TypeOf(null.Bool{})
+	nullTimeType   = reflect.TypeOf(null.Time{})
+)
+
+// jsonSchema => the json schema of a go type, as encoded by encoding/json
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	return jsonSchemaOf(t, map[reflect.Type]bool{})
+}
+
+func jsonSchemaOf(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case nullIntType:
+		return nullable(map[string]interface{}{"type": "integer"})
+	case nullFloatType:
+		return nullable(map[string]interface{}{"type": "number"})
+	case nullStringType:
+		return nullable(map[string]interface{}{"type": "string"})
+	case nullBoolType:
+		return nullable(map[string]interface{}{"type": "boolean"})
+	case nullTimeType:
+		return nullable(map[string]interface{}{"type": "string", "format": "date-time"})
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Ptr:
+		return nullable(jsonSchemaOf(t.Elem(), seen))
+	case reflect.Slice, reflect.Array:
+		return array(jsonSchemaOf(t.Elem(), seen))
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("jsonapi") == "-" || field.Tag.Get("json") == "-" {
+				continue
+			}
+			properties[nestedFieldName(field)] = jsonSchemaOf(field.Type, seen)
+		}
+		return object(properties)
+	}
+
+	// interfaces, etc. may be anything
+	return map[string]interface{}{}
+}
+
+// object => the schema of an object w/ the given properties
+func object(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// formatSchemaKeys => an object schema w/ its property names formatted w/ the KeyFormat, see AttributesSchema
+func formatSchemaKeys(schema map[string]interface{}) map[string]interface{} {
+	properties, _ := schema["properties"].(map[string]interface{})
+	formatted := map[string]interface{}{}
+	for name, property := range properties {
+		formatted[formatKey(name)] = property
+	}
+	required := []string{}
+	if names, ok := schema["required"].([]string); ok {
+		for _, name := range names {
+			required = append(required, formatKey(name))
+		}
+	}
+	return object(formatted, required...)
+}
+
+// array => the schema of an array w/ the given items
+func array(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": items}
+}
+
+// nullable => a copy of the schema that also allows null
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if t, ok := schema["type"].(string); ok {
+		copied := map[string]interface{}{}
+		for k, v := range schema {
+			copied[k] = v
+		}
+		copied["type"] = []string{t, "null"}
+		return copied
+	}
+	return map[string]interface{}{"oneOf": []interface{}{schema, map[string]interface{}{"type": "null"}}}
+}
+
+// ref => a reference to a component schema
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
@@ -0,0 +1,161 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OpenAPI", func() {
+	var (
+		info = OpenAPIInfo{Title: "Carz", Version: "1.0.0"}
+		doc  map[string]interface{}
+	)
+
+	// get => the value at a path of the json roundtripped document
+	get := func(keys ...string) interface{} {
+		var v interface{} = doc
+		for _, k := range keys {
+			v = v.(map[string]interface{})[k]
+		}
+		return v
+	}
+
+	BeforeEach(func() {
+		RegisterResource(AutomobileResource{})
+		RegisterResource(DriverResource{})
+		RegisterResource(InvoiceResource{})
+
+		j, err := json.Marshal(OpenAPI(TEST_SERVER_INFO, info))
+		Ω(err).ShouldNot(HaveOccurred())
+		doc = map[string]interface{}{}
+		Ω(json.Unmarshal(j, &doc)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		registry = map[string]reflect.Type{}
+	})
+
+	It("should describe the api", func() {
+		Ω(get("openapi")).Should(Equal("3.1.0"))
+		Ω(get("info", "title")).Should(Equal("Carz"))
+		Ω(get("servers")).Should(Equal([]interface{}{map[string]interface{}{"url": "http://my.domain"}}))
+	})
+
+	It("should describe the CRUD routes under the prefix", func() {
+		Ω(get("paths", "/v1/automobiles")).Should(HaveKey("get"))
+		Ω(get("paths", "/v1/automobiles")).Should(HaveKey("post"))
+		Ω(get("paths", "/v1/automobiles/{id}")).Should(HaveKey("get"))
+		Ω(get("paths", "/v1/automobiles/{id}")).Should(HaveKey("patch"))
+		Ω(get("paths", "/v1/automobiles/{id}")).Should(HaveKey("delete"))
+		Ω(get("paths", "/v1/drivers/{id}", "delete", "responses")).Should(HaveKey("204"))
+		Ω(get("paths", "/v1/drivers", "post", "responses", "422", "content", JSONAPI_MEDIA_TYPE, "schema")).Should(
+			Equal(map[string]interface{}{"$ref": "#/components/schemas/errors"}))
+	})
+
+	It("should describe the query parameters of an index request", func() {
+		names := []string{}
+		for _, p := range get("paths", "/v1/automobiles", "get", "parameters").([]interface{}) {
+			names = append(names, p.(map[string]interface{})["name"].(string))
+		}
+		Ω(names).Should(Equal([]string{"include", "fields", "sort", "filter", "page"}))
+
+		filter := get("paths", "/v1/automobiles", "get", "parameters").([]interface{})[3].(map[string]interface{})
+		Ω(filter["style"]).Should(Equal("deepObject"))
+		Ω(filter["schema"].(map[string]interface{})["properties"]).Should(HaveKey("body-style"))
+	})
+
+	It("should describe the attributes by their go types", func() {
+		attributes := get("components", "schemas", "automobiles", "properties", "attributes", "properties")
+		Ω(attributes).Should(HaveKeyWithValue("year", map[string]interface{}{"type": []interface{}{"integer", "null"}}))
+		Ω(attributes).Should(HaveKeyWithValue("body-style", map[string]interface{}{"type": []interface{}{"string", "null"}}))
+		Ω(attributes).Should(HaveKeyWithValue("ages", map[string]interface{}{"type": "array", "items": map[string]interface{}{}}))
+		Ω(attributes).ShouldNot(HaveKey("drivers"))
+
+		attributes = get("components", "schemas", "drivers", "properties", "attributes", "properties")
+		Ω(attributes).Should(HaveKeyWithValue("age", map[string]interface{}{"type": "integer"}))
+	})
+
+	It("should mark read-only attributes", func() {
+		Ω(get("components", "schemas", "invoices", "properties", "attributes", "properties", "updated-at")).Should(
+			HaveKeyWithValue("readOnly", true))
+	})
+
+	It("should describe the relationships", func() {
+		drivers := get("components", "schemas", "automobiles", "properties", "relationships", "properties", "drivers")
+		Ω(drivers).Should(Equal(map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+			"data": map[string]interface{}{"type": "array", "items": map[string]interface{}{
+				"type": "object", "required": []interface{}{"type", "id"}, "properties": map[string]interface{}{
+					"type": map[string]interface{}{"const": "drivers"}, "id": map[string]interface{}{"type": "string"}}}}}}))
+	})
+
+	It("should describe the schema rules of the attributes", func() {
+		RegisterResource(TruckResource{})
+		j, _ := json.Marshal(OpenAPI(TEST_SERVER_INFO, info))
+		Ω(json.Unmarshal(j, &doc)).Should(Succeed())
+
+		Ω(get("components", "schemas", "trucks-new", "properties", "attributes", "required")).Should(Equal([]interface{}{"year", "make"}))
+		Ω(get("components", "schemas", "trucks", "properties", "attributes")).ShouldNot(HaveKey("required"))
+		Ω(get("components", "schemas", "trucks", "properties", "attributes", "properties", "email", "format")).Should(Equal("email"))
+	})
+
+	It("should not require the id of a new resource", func() {
+		Ω(get("components", "schemas", "automobiles", "required")).Should(Equal([]interface{}{"type", "id"}))
+		Ω(get("components", "schemas", "automobiles-new", "required")).Should(Equal([]interface{}{"type"}))
+	})
+
+	It("should derive the error schema from JsonApiError", func() {
+		properties := get("components", "schemas", "error", "properties").(map[string]interface{})
+		keys := []string{}
+		for k := range properties {
+			keys = append(keys, k)
+		}
+		Ω(keys).Should(ConsistOf("id", "links", "status", "code", "title", "detail", "source", "meta"))
+		Ω(properties["source"].(map[string]interface{})["type"]).Should(Equal([]interface{}{"object", "null"}))
+	})
+
+	It("should describe tenant placeholders as variables and parameters", func() {
+		j, _ := json.Marshal(OpenAPI(JSONApiServerInfo{BaseURL: "https://{tenant}.carz.com", Prefix: "{tenant}/v1"}, info))
+		Ω(json.Unmarshal(j, &doc)).Should(Succeed())
+
+		Ω(get("servers").([]interface{})[0]).Should(HaveKey("variables"))
+		Ω(get("paths")).Should(HaveKey("/{tenant}/v1/automobiles/{id}"))
+		Ω(get("paths", "/{tenant}/v1/automobiles/{id}", "parameters")).Should(HaveLen(2))
+	})
+
+	It("should serve the document at the configured path", func() {
+		server := martini.Classic()
+		server.Use(render.Renderer())
+		ServeOpenAPI(server, TEST_SERVER_INFO, info)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/v1/openapi.json", nil)
+		server.ServeHTTP(recorder, request)
+
+		Ω(recorder.Code).Should(Equal(200))
+		Ω(recorder.Header().Get("Content-Type")).Should(Equal("application/vnd.oai.openapi+json"))
+		Ω(json.Unmarshal(recorder.Body.Bytes(), &doc)).Should(Succeed())
+		Ω(get("paths")).Should(HaveKey("/v1/automobiles"))
+	})
+
+	It("should write the document to a file", func() {
+		dir, _ := ioutil.TempDir("", "openapi")
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "openapi.json")
+
+		Ω(WriteOpenAPI(filename, TEST_SERVER_INFO, info)).Should(Succeed())
+		j, err := ioutil.ReadFile(filename)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(json.Unmarshal(j, &doc)).Should(Succeed())
+		Ω(get("components", "schemas")).Should(HaveKey("drivers"))
+	})
+})
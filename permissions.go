@@ -0,0 +1,198 @@
+package gsonapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// FieldPermission => read and write rules for a single attribute
+type FieldPermission struct {
+	ReadOnly  bool     // may never be written by a client, e.g., updated-at
+	WriteOnce bool     // may only be written when the resource is created
+	WriteOnly bool     // may never be seen by a client, e.g., a secret
+	HiddenFor []string // roles that may not see the attribute
+}
+
+// FieldPermissions => field permissions keyed by attribute name
+type FieldPermissions map[string]FieldPermission
+
+// FieldPermissioner => optional interface for declaring field permissions in code
+// NOTE: declared permissions replace the ones parsed from the jsonapi struct tags
+type FieldPermissioner interface {
+	FieldPermissions() FieldPermissions
+}
+
+// Roler => optional interface for callers (see JSONApiServerInfo.Caller) that have roles
+type Roler interface {
+	HasRole(role string) bool
+}
+
+// GetFieldPermissions => parses a resource's field permissions from its jsonapi struct tags
+// EX: `jsonapi:"name=updated-at;readonly"`, `jsonapi:"name=vin;writeonce"`
+// `jsonapi:"name=secret;writeonly"` and `jsonapi:"name=cost;hidden=guest|driver"`
+func GetFieldPermissions(resource interface{}) FieldPermissions {
+	permissions := FieldPermissions{}
+
+	t := reflect.TypeOf(resource)
+	if t == nil {
+		return permissions
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return permissions
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isAttribute(field) {
+			continue
+		}
+
+		p := FieldPermission{
+			ReadOnly:  jsonapi.GetTagValueByName(field, "readonly") != "",
+			WriteOnce: jsonapi.GetTagValueByName(field, "writeonce") != "",
+			WriteOnly: jsonapi.GetTagValueByName(field, "writeonly") != "",
+		}
+		if hidden := jsonapi.GetTagValueByName(field, "hidden"); hidden != "" {
+			p.HiddenFor = strings.Split(hidden, "|")
+		}
+
+		if p.ReadOnly || p.WriteOnce || p.WriteOnly || len(p.HiddenFor) > 0 {
+			permissions[attributeName(field)] = p
+		}
+	}
+
+	if permissioner, ok := reflect.New(t).Interface().(FieldPermissioner); ok {
+		for name, p := range permissioner.FieldPermissions() {
+			permissions[name] = p
+		}
+	}
+
+	return permissions
+}
+
+// CheckWritePermissions => returns a 403 error for each attribute the request may not write
+func CheckWritePermissions(action Action, resource interface{}, attributes map[string]interface{}) []JsonApiError {
+	errors := []JsonApiError{}
+	permissions := GetFieldPermissions(resource)
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := permissions[name]
+		if p.ReadOnly || (p.WriteOnce && action != ActionCreate) {
+			errors = append(errors, JsonApiError{Status: "403", Title: "Forbidden",
+				Detail: name + " cannot be written", Source: &JsonApiErrorSource{Pointer: attributePointer(name)}})
+		}
+	}
+
+	return errors
+}
+
+// hiddenFor => true if the attribute is write only or the caller has one of the roles the attribute is hidden from
+func (p FieldPermission) hiddenFor(caller interface{}) bool {
+	if p.WriteOnly {
+		return true
+	}
+
+	roler, ok := caller.(Roler)
+	if !ok {
+		return false
+	}
+
+	for _, role := range p.HiddenFor {
+		if roler.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripHiddenAttributes => removes the attributes the caller may not see from a marshalled response
+func stripHiddenAttributes(jasi JSONApiServerInfo, data interface{}, response interface{}) {
+	permissions := map[string]FieldPermissions{}
+	for _, v := range marshalledValues(data) {
+		if _, ok := permissions[resourceType(v)]; !ok {
+			permissions[resourceType(v)] = GetFieldPermissions(v)
+		}
+	}
+
+	for _, entry := range responseEntries(response) {
+		attributes, _ := entry["attributes"].(map[string]interface{})
+		t, _ := entry["type"].(string)
+		for name, p := range permissions[t] {
+			if p.hiddenFor(jasi.Caller) {
+				delete(attributes, name)
+			}
+		}
+	}
+}
+
+// attributeName => an attribute's name as derived by api2go, i.e., the name tag or the jsonified field name
+func attributeName(field reflect.StructField) string {
+	if name := jsonapi.GetTagValueByName(field, "name"); name != "" {
+		return name
+	}
+	return jsonapi.Jsonify(field.Name)
+}
+
+// marshalledValues => the primary data and included values that are marshalled, see GetReferencedStructs
+func marshalledValues(data interface{}) []interface{} {
+	values := primaryValues(data)
+	for _, v := range primaryValues(data) {
+		for _, s := range GetReferencedStructs(v) {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// primaryValues => the primary data, i.e., the data's values or the data itself
+func primaryValues(data interface{}) []interface{} {
+	values := []interface{}{}
+
+	val := reflect.ValueOf(data)
+	if val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			values = append(values, val.Index(i).Interface())
+		}
+	} else if data != nil {
+		values = append(values, data)
+	}
+
+	return values
+}
+
+// responseEntries => the resource objects within a marshalled response's data and included members
+func responseEntries(response interface{}) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+
+	doc, ok := response.(map[string]interface{})
+	if !ok {
+		return entries
+	}
+
+	for _, key := range []string{"data", "included"} {
+		switch t := doc[key].(type) {
+		case map[string]interface{}:
+			entries = append(entries, t)
+		case []interface{}:
+			for _, e := range t {
+				if entry, ok := e.(map[string]interface{}); ok {
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+
+	return entries
+}
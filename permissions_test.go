@@ -0,0 +1,161 @@
+package gsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Invoice Resource
+type InvoiceResource struct {
+	Resource  `jsonapi:"-"`
+	Number    null.String `json:"number,omitempty" jsonapi:"name=number;writeonce"`
+	Total     null.Float  `json:"total,omitempty" jsonapi:"name=total;hidden=guest|driver"`
+	Notes     null.String `json:"notes,omitempty" jsonapi:"name=notes"`
+	UpdatedAt null.String `json:"updated-at,omitempty" jsonapi:"name=updated-at;readonly"`
+}
+
+func (r InvoiceResource) GetName() string {
+	return "invoices"
+}
+
+func (r *InvoiceResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *InvoiceResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+func (r InvoiceResource) FieldPermissions() FieldPermissions {
+	return FieldPermissions{"id": {ReadOnly: true}}
+}
+
+// roles => a caller that has roles
+type roles []string
+
+func (r roles) HasRole(role string) bool {
+	for _, v := range r {
+		if v == role {
+			return true
+		}
+	}
+	return false
+}
+
+var _ = Describe("Permissions", func() {
+	Context("Parsing", func() {
+		It("should merge the struct tags with the declared permissions", func() {
+			Ω(GetFieldPermissions(InvoiceResource{})).Should(Equal(FieldPermissions{
+				"id":         {ReadOnly: true},
+				"number":     {WriteOnce: true},
+				"total":      {HiddenFor: []string{"guest", "driver"}},
+				"updated-at": {ReadOnly: true},
+			}))
+		})
+
+		It("should parse write-only attributes, which are hidden for every caller", func() {
+			p := GetFieldPermissions(WebhookResource{})["secret"]
+			Ω(p).Should(Equal(FieldPermission{WriteOnly: true}))
+			Ω(p.hiddenFor(nil)).Should(BeTrue())
+			Ω(p.hiddenFor(roles{"admin"})).Should(BeTrue())
+		})
+
+		It("should return no permissions for resources without any", func() {
+			Ω(GetFieldPermissions(DriverResource{})).Should(BeEmpty())
+		})
+	})
+
+	Context("Writing", func() {
+		It("should allow writing a write-once attribute on create", func() {
+			resource := InvoiceResource{}
+			body := []byte(`{"data":{"type":"invoices","attributes":{"number":"INV-1","notes":"paid"}}}`)
+
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &resource)).Should(BeEmpty())
+			Ω(resource.Number.String).Should(Equal("INV-1"))
+			Ω(resource.Notes.String).Should(Equal("paid"))
+		})
+
+		It("should return 403 errors for read-only and write-once attributes on update", func() {
+			resource := InvoiceResource{}
+			body := []byte(`{"data":{"type":"invoices","id":"1","attributes":{"number":"INV-2","updated-at":"2015-01-01","notes":"paid"}}}`)
+
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &resource)
+			Ω(errors).Should(HaveLen(2))
+			Ω(errors[0].Status).Should(Equal("403"))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/attributes/number"))
+			Ω(errors[1].Status).Should(Equal("403"))
+			Ω(errors[1].Source.Pointer).Should(Equal("/data/attributes/updated-at"))
+		})
+
+		It("should return a 403 error for a client generated id", func() {
+			body := []byte(`{"data":{"type":"invoices","id":"1","attributes":{"notes":"paid"}}}`)
+
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &InvoiceResource{})
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/id"))
+		})
+
+		It("should return a 400 error for a malformed document", func() {
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, []byte(`{"data":[]}`), &InvoiceResource{})
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("400"))
+		})
+
+		It("should render the errors with their shared status", func() {
+			server := martini.Classic()
+			server.Use(render.Renderer())
+			recorder := httptest.NewRecorder()
+			server.Patch("/v1/invoices/:id", func(r render.Render) {
+				HandleErrorsResponse(CheckWritePermissions(ActionUpdate, InvoiceResource{}, map[string]interface{}{"number": "INV-2"}), r)
+			})
+
+			request, _ := http.NewRequest("PATCH", "/v1/invoices/1", nil)
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(403))
+			Ω(recorder.Body.String()).Should(MatchJSON(`{"errors":[{"status":"403","title":"Forbidden",` +
+				`"detail":"number cannot be written","source":{"pointer":"/data/attributes/number"}}]}`))
+		})
+	})
+
+	Context("Reading", func() {
+		var recorder *httptest.ResponseRecorder
+
+		serve := func(caller interface{}) {
+			server := martini.Classic()
+			server.Use(render.Renderer())
+			recorder = httptest.NewRecorder()
+			server.Get("/v1/invoices", func(r render.Render) {
+				jasi := TEST_SERVER_INFO
+				jasi.Caller = caller
+				invoice := InvoiceResource{Number: null.StringFrom("INV-1"), Total: null.FloatFrom(9.5)}
+				invoice.SetID("1")
+				HandleIndexResponse(jasi, nil, []InvoiceResource{invoice}, r)
+			})
+
+			request, _ := http.NewRequest("GET", "/v1/invoices", nil)
+			server.ServeHTTP(recorder, request)
+		}
+
+		It("should strip attributes that are hidden for the caller's roles", func() {
+			serve(roles{"guest"})
+
+			Ω(recorder.Code).Should(Equal(200))
+			Ω(recorder.Body.String()).Should(MatchJSON(`{"data":[{"type":"invoices","id":"1",` +
+				`"attributes":{"number":"INV-1","notes":null,"updated-at":null}}]}`))
+		})
+
+		It("should render hidden attributes for other roles", func() {
+			serve(roles{"admin"})
+
+			Ω(recorder.Code).Should(Equal(200))
+			Ω(recorder.Body.String()).Should(ContainSubstring(`"total":9.5`))
+		})
+	})
+})
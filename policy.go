@@ -0,0 +1,180 @@
+package gsonapi
+
+import (
+	"reflect"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// Action => an operation a caller can attempt against a resource type
+type Action string
+
+const (
+	ActionList   Action = "list"
+	ActionRead   Action = "read"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Decision => outcome of a record level authorization check
+type Decision int
+
+const (
+	// Allow => the caller may perform the action against the record
+	Allow Decision = iota
+	// Deny => the caller may know the record exists, but may not act on it (403)
+	Deny
+	// Conceal => the caller may not know the record exists (404)
+	Conceal
+)
+
+// Policy => decides what an authenticated caller may do with a resource type
+// NOTE: the caller is whatever the app's authentication layer stored
+// in JSONApiServerInfo.Caller
+type Policy interface {
+	// Authorize => may the caller perform the action against the resource type at all
+	Authorize(caller interface{}, action Action, resourceType string) bool
+	// AuthorizeRecord => may the caller perform the action against a specific record
+	AuthorizeRecord(caller interface{}, action Action, record jsonapi.MarshalIdentifier) Decision
+	// Scope => filters that restrict which records an index query may return
+	Scope(caller interface{}, resourceType string) []Filter
+}
+
+// AllowAll => default policy for resource types without a registered policy
+// NOTE: embed it in a custom policy to only override what's needed
+type AllowAll struct{}
+
+func (AllowAll) Authorize(caller interface{}, action Action, resourceType string) bool {
+	return true
+}
+
+func (AllowAll) AuthorizeRecord(caller interface{}, action Action, record jsonapi.MarshalIdentifier) Decision {
+	return Allow
+}
+
+func (AllowAll) Scope(caller interface{}, resourceType string) []Filter {
+	return nil
+}
+
+// policies => registered policies keyed by resource type
+var policies = map[string]Policy{}
+
+// RegisterPolicy => sets the policy for a resource type (call during app startup)
+// EX: RegisterPolicy("automobiles", AutomobilePolicy{})
+func RegisterPolicy(resourceType string, policy Policy) {
+	policies[resourceType] = policy
+}
+
+// PolicyFor => returns the policy registered for a resource type, or AllowAll
+func PolicyFor(resourceType string) Policy {
+	if p, ok := policies[resourceType]; ok {
+		return p
+	}
+	return AllowAll{}
+}
+
+// Authorize => returns a 403 error if the caller may not perform the action against the resource type
+// NOTE: CreateWithHooks, UpdateWithHooks and DeleteWithHooks authorize the write, see authorizeWrite
+func Authorize(jasi JSONApiServerInfo, action Action, resourceType string) *JsonApiError {
+	if !PolicyFor(resourceType).Authorize(jasi.Caller, action, resourceType) {
+		return forbiddenError(action, resourceType)
+	}
+	return nil
+}
+
+// AuthorizeResource => returns a 403 or 404 error if the caller may not perform the action
+// against the resource type or the specific record
+// NOTE: soft deleted records may only be restored, see SoftDeleter and GoneReporter
+func AuthorizeResource(jasi JSONApiServerInfo, action Action, record jsonapi.MarshalIdentifier) *JsonApiError {
+	t := resourceType(record)
+	if err := authorizeTenant(jasi, record); err != nil {
+		return err
+	}
+	if err := Authorize(jasi, action, t); err != nil {
+		return err
+	}
+
+	switch PolicyFor(t).AuthorizeRecord(jasi.Caller, action, record) {
+	case Deny:
+		return forbiddenError(action, t)
+	case Conceal:
+		return notFoundError(t)
+	}
+	return authorizeDeleted(action, record)
+}
+
+// ScopeQuery => restricts an index query to the caller's tenant and the rows the caller may list
+// NOTE: returns a 403 error if the query includes soft deleted records (filter[deleted]=true)
+// and the caller may not list them, see ActionListDeleted; otherwise set jasi.ListDeleted to q.Deleted
+// so that HandleIndexResponse renders them; FindAllWithScope applies it to a Repository's FindAll
+func ScopeQuery(jasi JSONApiServerInfo, resourceType string, q *Query) *JsonApiError {
+	q.Tenant = jasi.Tenant
+	q.AddFilters(PolicyFor(resourceType).Scope(jasi.Caller, resourceType)...)
+	if q.Deleted {
+		return Authorize(jasi, ActionListDeleted, resourceType)
+	}
+	return nil
+}
+
+// FindAllWithScope => the page of the records the caller may list and their total, i.e., the repository's
+// FindAll of the query once ScopeQuery restricted it, so that the page is filled and the total is right
+// EX: ParseQuery => FindAllWithScope => HandleIndexResponse
+// NOTE: returns a 403 error if the caller may not list the resource type
+func FindAllWithScope(jasi JSONApiServerInfo, resourceType string, repository Repository, q *Query) (interface{}, int, *JsonApiError) {
+	if err := Authorize(jasi, ActionList, resourceType); err != nil {
+		return nil, 0, err
+	}
+	if err := ScopeQuery(jasi, resourceType, q); err != nil {
+		return nil, 0, err
+	}
+	return repository.FindAll(q)
+}
+
+// authorizeList => checks the list action and removes the records the caller may not see
+// NOTE: soft deleted records are removed unless jasi.ListDeleted is set and the caller may list them
+func authorizeList(jasi JSONApiServerInfo, result interface{}) (interface{}, *JsonApiError) {
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Slice {
+		return result, nil
+	}
+
+	t := resourceType(result)
+	if err := Authorize(jasi, ActionList, t); err != nil {
+		return nil, err
+	}
+
+	policy := PolicyFor(t)
+	scope := &Query{}
+	scope.AddFilters(policy.Scope(jasi.Caller, t)...)
+	deleted := jasi.ListDeleted && Authorize(jasi, ActionListDeleted, t) == nil
+
+	visible := reflect.MakeSlice(val.Type(), 0, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		record, ok := val.Index(i).Interface().(jsonapi.MarshalIdentifier)
+		if !ok {
+			visible = reflect.Append(visible, val.Index(i))
+			continue
+		}
+		if authorizeTenant(jasi, record) != nil || policy.AuthorizeRecord(jasi.Caller, ActionRead, record) != Allow {
+			continue
+		}
+		if IsDeleted(record) && !deleted {
+			continue
+		}
+		if len(scope.Filters) > 0 && !scope.Matches(resourceAttributes(record)) {
+			continue
+		}
+		visible = reflect.Append(visible, val.Index(i))
+	}
+
+	return visible.Interface(), nil
+}
+
+func forbiddenError(action Action, resourceType string) *JsonApiError {
+	return NewError(403).Detail("not authorized to " + string(action) + " " + resourceType).Build()
+}
+
+func notFoundError(resourceType string) *JsonApiError {
+	return NewError(404).Detail(resourceType + " not found").Build()
+}
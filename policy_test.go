@@ -0,0 +1,215 @@
+package gsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-martini/martini"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/martini-contrib/render"
+	"github.com/modocache/gory"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// AutomobilePolicy => only admins may list automobiles or see inactive ones,
+// and guests may not know that the Mazda exists
+type AutomobilePolicy struct {
+	AllowAll
+}
+
+func (p AutomobilePolicy) Authorize(caller interface{}, action Action, resourceType string) bool {
+	return caller == "admin" || action != ActionDelete
+}
+
+func (p AutomobilePolicy) AuthorizeRecord(caller interface{}, action Action, record jsonapi.MarshalIdentifier) Decision {
+	if caller == "guest" && record.GetID() == "aaaa-1111-bbbb-2222" {
+		return Conceal
+	}
+	if caller == "viewer" && action == ActionUpdate {
+		return Deny
+	}
+	return Allow
+}
+
+func (p AutomobilePolicy) Scope(caller interface{}, resourceType string) []Filter {
+	if caller == "admin" {
+		return nil
+	}
+	return []Filter{{Attribute: "active", Values: []string{"true"}}}
+}
+
+var _ = Describe("Policy", func() {
+	var (
+		server   *martini.ClassicMartini
+		recorder *httptest.ResponseRecorder
+		caller   string
+	)
+
+	serve := func(path string) {
+		request, _ := http.NewRequest("GET", path, nil)
+		server.ServeHTTP(recorder, request)
+	}
+
+	BeforeEach(func() {
+		RegisterPolicy("automobiles", AutomobilePolicy{})
+
+		server = martini.Classic()
+		server.Use(render.Renderer())
+		recorder = httptest.NewRecorder()
+
+		autoResource1 = *gory.Build("automobileResource1").(*AutomobileResource)
+		autoResource2 = *gory.Build("automobileResource2").(*AutomobileResource)
+		autoResource3 = *gory.Build("automobileResource3").(*AutomobileResource)
+
+		server.Get("/v1/automobiles", func(r render.Render) {
+			jasi := TEST_SERVER_INFO
+			jasi.Caller = caller
+			HandleIndexResponse(jasi, nil, []AutomobileResource{autoResource1, autoResource2, autoResource3}, r)
+		})
+		server.Get("/v1/automobiles/:id", func(r render.Render) {
+			jasi := TEST_SERVER_INFO
+			jasi.Caller = caller
+			HandleGetResponse(jasi, nil, autoResource1, r)
+		})
+	})
+
+	AfterEach(func() {
+		delete(policies, "automobiles")
+	})
+
+	It("should default to allowing everything", func() {
+		Ω(PolicyFor("drivers")).Should(Equal(AllowAll{}))
+		Ω(Authorize(TEST_SERVER_INFO, ActionDelete, "drivers")).Should(BeNil())
+	})
+
+	It("should return a 403 error when the action is not authorized for the resource type", func() {
+		err := Authorize(JSONApiServerInfo{Caller: "guest"}, ActionDelete, "automobiles")
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Status).Should(Equal("403"))
+		Ω(Authorize(JSONApiServerInfo{Caller: "admin"}, ActionDelete, "automobiles")).Should(BeNil())
+	})
+
+	It("should return a 403 error when a record is denied", func() {
+		err := AuthorizeResource(JSONApiServerInfo{Caller: "viewer"}, ActionUpdate, autoResource1)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Status).Should(Equal("403"))
+	})
+
+	It("should scope an index query", func() {
+		q := &Query{}
+		ScopeQuery(JSONApiServerInfo{Caller: "viewer"}, "automobiles", q)
+		Ω(q.Filters).Should(Equal([]Filter{{Attribute: "active", Values: []string{"true"}}}))
+	})
+
+	It("should page the records within the caller's scope", func() {
+		automobiles := NewMemoryRepository(AutomobileResource{})
+		for _, r := range []AutomobileResource{autoResource1, autoResource2, autoResource3} {
+			r := r
+			Ω(automobiles.Create(&r)).Should(BeNil())
+		}
+
+		q := &Query{Page: Page{Size: 2}}
+		records, total, err := FindAllWithScope(JSONApiServerInfo{Caller: "viewer"}, "automobiles", automobiles, q)
+		Ω(err).Should(BeNil())
+		Ω(total).Should(Equal(2))
+		Ω(records).Should(HaveLen(2))
+		Ω(records.([]AutomobileResource)[0].GetID()).Should(Equal("aaaa-1111-bbbb-2222"))
+		Ω(records.([]AutomobileResource)[1].GetID()).Should(Equal("cccc-3333-dddd-4444"))
+
+		RegisterPolicy("automobiles", denyListPolicy{})
+		_, _, err = FindAllWithScope(JSONApiServerInfo{Caller: "viewer"}, "automobiles", automobiles, &Query{})
+		Ω(err.Status).Should(Equal("403"))
+	})
+
+	It("should authorize writes against the stored record", func() {
+		previous, _ := RepositoryFor("automobiles")
+		automobiles := NewMemoryRepository(AutomobileResource{})
+		RegisterRepository("automobiles", automobiles)
+		defer RegisterRepository("automobiles", previous)
+		stored := autoResource1
+		Ω(automobiles.Create(&stored)).Should(BeNil())
+
+		saves := 0
+		save := func() *JsonApiError {
+			saves++
+			return nil
+		}
+		partial := AutomobileResource{}
+		partial.SetID("aaaa-1111-bbbb-2222")
+
+		Ω(UpdateWithHooks(JSONApiServerInfo{Caller: "viewer"}, &partial, nil, save).Status).Should(Equal("403"))
+		Ω(UpdateWithHooks(JSONApiServerInfo{Caller: "guest"}, &partial, nil, save).Status).Should(Equal("404"))
+		Ω(DeleteWithHooks(JSONApiServerInfo{Caller: "viewer"}, &partial, nil, save).Status).Should(Equal("403"))
+		Ω(saves).Should(Equal(0))
+
+		Ω(UpdateWithHooks(JSONApiServerInfo{Caller: "admin"}, &partial, nil, save)).Should(BeNil())
+		Ω(DeleteWithHooks(JSONApiServerInfo{Caller: "admin"}, &partial, nil, save)).Should(BeNil())
+		Ω(saves).Should(Equal(2))
+
+		RegisterPolicy("automobiles", denyCreatePolicy{})
+		Ω(CreateWithHooks(JSONApiServerInfo{Caller: "admin"}, &AutomobileResource{}, nil, save).Status).Should(Equal("403"))
+		Ω(saves).Should(Equal(2))
+	})
+
+	It("should only list the records within the caller's scope", func() {
+		caller = "viewer"
+		serve("/v1/automobiles")
+
+		Ω(recorder.Code).Should(Equal(200))
+		Ω(recorder.Body.String()).Should(ContainSubstring("aaaa-1111-bbbb-2222"))
+		Ω(recorder.Body.String()).Should(ContainSubstring("cccc-3333-dddd-4444"))
+		Ω(recorder.Body.String()).ShouldNot(ContainSubstring("bbbb-2222-eeee-5555"))
+	})
+
+	It("should not list concealed records", func() {
+		caller = "guest"
+		serve("/v1/automobiles")
+
+		Ω(recorder.Code).Should(Equal(200))
+		Ω(recorder.Body.String()).ShouldNot(ContainSubstring("aaaa-1111-bbbb-2222"))
+	})
+
+	It("should return a 404 Status Code for a concealed record", func() {
+		caller = "guest"
+		serve("/v1/automobiles/aaaa-1111-bbbb-2222")
+
+		Ω(recorder.Code).Should(Equal(404))
+		Ω(recorder.Body.String()).Should(MatchJSON(`{"errors":[{"status":"404","title":"Not Found","detail":"automobiles not found"}]}`))
+	})
+
+	It("should return a 403 Status Code when listing is not authorized", func() {
+		RegisterPolicy("automobiles", denyListPolicy{})
+		serve("/v1/automobiles")
+
+		Ω(recorder.Code).Should(Equal(403))
+		Ω(recorder.Body.String()).Should(MatchJSON(`{"errors":[{"status":"403","title":"Forbidden","detail":"not authorized to list automobiles"}]}`))
+	})
+
+	It("should honor the status of an error passed to a response helper", func() {
+		server.Delete("/v1/automobiles/:id", func(r render.Render) {
+			HandleDeleteResponse(Authorize(JSONApiServerInfo{Caller: "guest"}, ActionDelete, "automobiles"), r)
+		})
+		request, _ := http.NewRequest("DELETE", "/v1/automobiles/aaaa-1111-bbbb-2222", nil)
+		server.ServeHTTP(recorder, request)
+
+		Ω(recorder.Code).Should(Equal(403))
+		Ω(recorder.Body.String()).Should(ContainSubstring("not authorized to delete automobiles"))
+	})
+})
+
+type denyListPolicy struct {
+	AllowAll
+}
+
+func (denyListPolicy) Authorize(caller interface{}, action Action, resourceType string) bool {
+	return action != ActionList
+}
+
+type denyCreatePolicy struct {
+	AllowAll
+}
+
+func (denyCreatePolicy) Authorize(caller interface{}, action Action, resourceType string) bool {
+	return action != ActionCreate
+}
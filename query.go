@@ -0,0 +1,173 @@
+package gsonapi
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filter => a single filter[...] query constraint, or a group of constraints any of which matches
+// EX: filter[make]=Mazda,Honda matches records whose make is Mazda OR Honda
+// EX: filter[or][make]=Mazda&filter[or][year]=2010 matches records whose make is Mazda OR whose year is 2010
+// NOTE: the filters of a Query are AND-ed, so that a group nested in Or forms a tree of AND and OR conditions
+type Filter struct {
+	Attribute string
+	Values    []string
+	Or        []Filter
+}
+
+// SortField => a single sort query criterion
+// EX: sort=-year sorts by year in descending order
+type SortField struct {
+	Attribute  string
+	Descending bool
+}
+
+// Page => page[number] and page[size] query values
+type Page struct {
+	Number int
+	Size   int
+}
+
+// Query => parsed representation of an index request's query parameters
+type Query struct {
+	Include []string
+	Fields  map[string][]string
+	Sort    []SortField
+	Filters []Filter // AND-ed, see Filter
+	Page    Page
+	Tenant  string // set by ScopeQuery so that data sources can partition by tenant
+	Deleted bool   // filter[deleted]=true, i.e., soft deleted records are included, see Includes
+}
+
+// ParseQuery => parses the include, fields, sort, filter and page query parameters
+// EX: /v1/automobiles?filter[make]=Mazda&sort=-year&page[number]=2&page[size]=10
+// NOTE: the attribute names of the sort, filter and fields parameters are parsed w/ the KeyFormat
+func ParseQuery(values url.Values) (*Query, *JsonApiError) {
+	q := &Query{Fields: map[string][]string{}}
+	or := Filter{}
+
+	// sort the keys so that filters are always applied in the same order
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := strings.Join(values[k], ",")
+
+		switch {
+		case k == "include":
+			q.Include = splitQueryList(v)
+		case k == "sort":
+			for _, s := range splitQueryList(v) {
+				if strings.HasPrefix(s, "-") {
+					q.Sort = append(q.Sort, SortField{Attribute: parseKey(s[1:]), Descending: true})
+				} else {
+					q.Sort = append(q.Sort, SortField{Attribute: parseKey(s)})
+				}
+			}
+		case strings.HasPrefix(k, "fields[") && strings.HasSuffix(k, "]"):
+			fields := splitQueryList(v)
+			for i, name := range fields {
+				fields[i] = parseKey(name)
+			}
+			q.Fields[k[len("fields["):len(k)-1]] = fields
+		case k == "filter[deleted]":
+			q.Deleted = v == "true"
+		case strings.HasPrefix(k, "filter[or][") && strings.HasSuffix(k, "]"):
+			or.Or = append(or.Or, Filter{Attribute: parseKey(k[len("filter[or][") : len(k)-1]), Values: splitQueryList(v)})
+		case strings.HasPrefix(k, "filter[") && strings.HasSuffix(k, "]"):
+			q.Filters = append(q.Filters, Filter{Attribute: parseKey(k[len("filter[") : len(k)-1]), Values: splitQueryList(v)})
+		case k == "page[number]" || k == "page[size]":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, &JsonApiError{Status: "400", Title: "Invalid Query Parameter",
+					Detail: k + " must be a positive integer", Source: &JsonApiErrorSource{Parameter: k}}
+			}
+			if k == "page[number]" {
+				q.Page.Number = n
+			} else {
+				q.Page.Size = n
+			}
+		}
+	}
+	if len(or.Or) > 0 {
+		q.Filters = append(q.Filters, or)
+	}
+
+	return q, nil
+}
+
+// AddFilters => appends filters to the query, e.g., the row scope of a policy
+func (q *Query) AddFilters(filters ...Filter) {
+	q.Filters = append(q.Filters, filters...)
+}
+
+// Includes => false if the record is soft deleted and the query does not include deleted records
+func (q *Query) Includes(record interface{}) bool {
+	return q.Deleted || !IsDeleted(record)
+}
+
+// Matches => true if the attributes satisfy every filter in the query
+func (q *Query) Matches(attributes map[string]interface{}) bool {
+	for _, f := range q.Filters {
+		if !f.Matches(attributes) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches => true if the filtered attribute equals any of the filter's values, or if any filter of the group matches
+func (f Filter) Matches(attributes map[string]interface{}) bool {
+	if len(f.Or) > 0 {
+		for _, filter := range f.Or {
+			if filter.Matches(attributes) {
+				return true
+			}
+		}
+		return false
+	}
+
+	v, ok := attributes[f.Attribute]
+	if !ok {
+		return false
+	}
+
+	s := queryString(v)
+	for _, value := range f.Values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// queryString => formats a decoded json attribute value the way it would appear in a query string
+func queryString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func splitQueryList(v string) []string {
+	list := []string{}
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,74 @@
+package gsonapi
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query", func() {
+	Context("Parsing", func() {
+		It("should parse the include, fields, sort, filter and page parameters", func() {
+			values, _ := url.ParseQuery("include=drivers&fields[automobiles]=make,year&sort=-year,make" +
+				"&filter[make]=Mazda,Honda&filter[active]=true&page[number]=2&page[size]=10")
+
+			q, err := ParseQuery(values)
+			Ω(err).Should(BeNil())
+			Ω(q.Include).Should(Equal([]string{"drivers"}))
+			Ω(q.Fields).Should(Equal(map[string][]string{"automobiles": {"make", "year"}}))
+			Ω(q.Sort).Should(Equal([]SortField{{Attribute: "year", Descending: true}, {Attribute: "make"}}))
+			Ω(q.Filters).Should(Equal([]Filter{
+				{Attribute: "active", Values: []string{"true"}},
+				{Attribute: "make", Values: []string{"Mazda", "Honda"}},
+			}))
+			Ω(q.Page).Should(Equal(Page{Number: 2, Size: 10}))
+		})
+
+		It("should group the or filters", func() {
+			values, _ := url.ParseQuery("filter[active]=true&filter[or][make]=Mazda&filter[or][year]=2010")
+
+			q, err := ParseQuery(values)
+			Ω(err).Should(BeNil())
+			Ω(q.Filters).Should(Equal([]Filter{
+				{Attribute: "active", Values: []string{"true"}},
+				{Or: []Filter{{Attribute: "make", Values: []string{"Mazda"}}, {Attribute: "year", Values: []string{"2010"}}}},
+			}))
+		})
+
+		It("should return a 400 error for an invalid page parameter", func() {
+			values, _ := url.ParseQuery("page[size]=abc")
+
+			_, err := ParseQuery(values)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Status).Should(Equal("400"))
+			Ω(err.Source.Parameter).Should(Equal("page[size]"))
+		})
+	})
+
+	Context("Matching", func() {
+		It("should match attributes against every filter", func() {
+			q := &Query{}
+			q.AddFilters(Filter{Attribute: "make", Values: []string{"Mazda", "Honda"}},
+				Filter{Attribute: "year", Values: []string{"2010"}})
+
+			Ω(q.Matches(map[string]interface{}{"make": "Mazda", "year": float64(2010)})).Should(BeTrue())
+			Ω(q.Matches(map[string]interface{}{"make": "Mazda", "year": float64(1980)})).Should(BeFalse())
+			Ω(q.Matches(map[string]interface{}{"make": "Austin-Healey", "year": float64(2010)})).Should(BeFalse())
+			Ω(q.Matches(map[string]interface{}{"year": float64(2010)})).Should(BeFalse())
+		})
+
+		It("should match attributes against any filter of a group", func() {
+			q := &Query{}
+			q.AddFilters(Filter{Attribute: "active", Values: []string{"true"}}, Filter{Or: []Filter{
+				{Attribute: "make", Values: []string{"Mazda"}},
+				{Attribute: "year", Values: []string{"2010"}},
+			}})
+
+			Ω(q.Matches(map[string]interface{}{"active": true, "make": "Mazda", "year": float64(1980)})).Should(BeTrue())
+			Ω(q.Matches(map[string]interface{}{"active": true, "make": "Honda", "year": float64(2010)})).Should(BeTrue())
+			Ω(q.Matches(map[string]interface{}{"active": true, "make": "Honda", "year": float64(1980)})).Should(BeFalse())
+			Ω(q.Matches(map[string]interface{}{"active": false, "make": "Mazda", "year": float64(2010)})).Should(BeFalse())
+		})
+	})
+})
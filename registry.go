@@ -0,0 +1,46 @@
+package gsonapi
+
+import (
+	"log"
+	"reflect"
+	"sort"
+)
+
+// registry => registered resource types keyed by jsonapi type
+var registry = map[string]reflect.Type{}
+
+// RegisterResource => registers a resource type (call during app startup)
+// EX: RegisterResource(AutomobileResource{})
+// NOTE: registered resources are described by the OpenAPI document; panics for an invalid validate tag of the
+// resource or its nested attributes, see GetValidators
+func RegisterResource(resource interface{}) {
+	t := reflect.TypeOf(resource)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if err := checkValidators(t, map[reflect.Type]bool{}); err != nil {
+		log.Panicln(err)
+	}
+	registry[resourceType(resource)] = t
+}
+
+// ResourceTypes => the sorted jsonapi types of the registered resources
+func ResourceTypes() []string {
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewResource => a pointer to a new resource of a registered type
+// EX: NewResource("automobiles") => &AutomobileResource{}, true
+func NewResource(resourceType string) (interface{}, bool) {
+	t, ok := registry[resourceType]
+	if !ok {
+		return nil, false
+	}
+	resource := reflect.New(t).Interface()
+	return resource, true
+}
@@ -0,0 +1,31 @@
+package gsonapi
+
+import (
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	AfterEach(func() {
+		registry = map[string]reflect.Type{}
+	})
+
+	It("should register resources by type", func() {
+		RegisterResource(DriverResource{})
+		RegisterResource(&AutomobileResource{})
+		Ω(ResourceTypes()).Should(Equal([]string{"automobiles", "drivers"}))
+	})
+
+	It("should instantiate a registered resource", func() {
+		RegisterResource(AutomobileResource{})
+
+		r, ok := NewResource("automobiles")
+		Ω(ok).Should(BeTrue())
+		Ω(r).Should(BeAssignableToTypeOf(&AutomobileResource{}))
+
+		_, ok = NewResource("trucks")
+		Ω(ok).Should(BeFalse())
+	})
+})
@@ -0,0 +1,596 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// Relation => a relationship declared by a relation struct tag
+// EX: `jsonapi:"relation=drivers,type=drivers"` on a []DriverResource, []*DriverResource or []string (IDs) field
+// declares a to-many relationship and on a DriverResource, *DriverResource or string (ID) field a to-one relationship
+// NOTE: the type defaults to the related resource's type (or the relation's name for ID fields)
+// EX: `jsonapi:"relation=subject,types=automobiles|trailers"` on a jsonapi.MarshalIdentifier, ResourceIdentifier
+// or slice field declares a polymorphic relationship, whose related resources' types are resolved at runtime
+type Relation struct {
+	Name   string   // EX: drivers
+	Type   string   // EX: drivers, empty for polymorphic relationships
+	Types  []string // allowed types of a polymorphic relationship, EX: automobiles and trailers
+	ToMany bool
+	Count  bool // renders the number of related resources in the relationship's meta, EX: `jsonapi:"relation=drivers,count"`
+	field  int
+}
+
+// ResourceIdentifier => a related resource's type and id, EX: the linkage of a polymorphic relationship
+type ResourceIdentifier struct {
+	Type string
+	ID   string
+}
+
+func (r ResourceIdentifier) GetID() string {
+	return r.ID
+}
+
+func (r *ResourceIdentifier) SetID(id string) error {
+	r.ID = id
+	return nil
+}
+
+func (r ResourceIdentifier) GetName() string {
+	return r.Type
+}
+
+var resourceIdentifierType = reflect.TypeOf(ResourceIdentifier{})
+
+// jsonapiTag => the settings of a jsonapi struct tag, which may be separated by commas or semicolons
+// EX: `jsonapi:"relation=drivers,type=drivers"` => {"relation": "drivers", "type": "drivers"}
+func jsonapiTag(field reflect.StructField) map[string]string {
+	settings := map[string]string{}
+	for _, setting := range strings.FieldsFunc(field.Tag.Get("jsonapi"), func(r rune) bool { return r == ',' || r == ';' }) {
+		kv := strings.SplitN(setting, "=", 2)
+		k := strings.TrimSpace(strings.ToLower(kv[0]))
+		if len(kv) == 2 {
+			settings[k] = strings.TrimSpace(kv[1])
+		} else {
+			settings[k] = k
+		}
+	}
+	return settings
+}
+
+// isRelation => true if the field is declared as a relationship by a relation tag
+func isRelation(field reflect.StructField) bool {
+	return jsonapiTag(field)["relation"] != ""
+}
+
+// isAttribute => true if the field is marshalled by api2go as an attribute
+func isAttribute(field reflect.StructField) bool {
+	return field.PkgPath == "" && field.Tag.Get("jsonapi") != "-" && !isRelation(field)
+}
+
+// GetRelations => the relationships declared by the resource's relation tags, in declaration order
+func GetRelations(resource interface{}) []Relation {
+	relations := []Relation{}
+
+	t := reflect.TypeOf(resource)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return relations
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := jsonapiTag(field)
+		if tag["relation"] == "" || field.PkgPath != "" {
+			continue
+		}
+
+		relation := Relation{Name: tag["relation"], Type: tag["type"], ToMany: field.Type.Kind() == reflect.Slice,
+			Count: tag["count"] != "", field: i}
+		if tag["types"] != "" {
+			relation.Types = strings.Split(tag["types"], "|")
+		} else if relation.Type == "" {
+			related := field.Type
+			if relation.ToMany {
+				related = related.Elem()
+			}
+			if related.Kind() == reflect.String {
+				relation.Type = relation.Name
+			} else {
+				relation.Type = resourceType(reflect.Zero(related).Interface())
+			}
+		}
+		relations = append(relations, relation)
+	}
+
+	return relations
+}
+
+// getRelation => the resource's relationship w/ the given name
+func getRelation(resource interface{}, name string) (Relation, bool) {
+	for _, relation := range GetRelations(resource) {
+		if relation.Name == name {
+			return relation, true
+		}
+	}
+	return Relation{}, false
+}
+
+// isToMany => true if the resource's relationship is to-many
+// NOTE: like api2go, a plural name denotes a to-many relationship unless it is declared by a relation tag
+func isToMany(resource interface{}, name string) bool {
+	if relation, ok := getRelation(resource, name); ok {
+		return relation.ToMany
+	}
+	return jsonapi.Pluralize(name) == name
+}
+
+// allowedTypes => the types a relationship's related resources may have, empty if any
+func (r Relation) allowedTypes() []string {
+	if len(r.Types) > 0 || r.Type == "" {
+		return r.Types
+	}
+	return []string{r.Type}
+}
+
+// relatedValues => the related resources (or IDs) held by the relation's field
+func relatedValues(resource interface{}, relation Relation) []reflect.Value {
+	values := []reflect.Value{}
+
+	fv := reflect.Indirect(reflect.ValueOf(resource)).Field(relation.field)
+	elems := []reflect.Value{fv}
+	if relation.ToMany {
+		elems = []reflect.Value{}
+		for i := 0; i < fv.Len(); i++ {
+			elems = append(elems, fv.Index(i))
+		}
+	}
+
+	for _, elem := range elems {
+		if (elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface) && elem.IsNil() {
+			continue
+		}
+		if elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+		values = append(values, elem)
+	}
+	return values
+}
+
+// relatedType => the type of a related resource, which is resolved at runtime for polymorphic relationships
+func relatedType(relation Relation, v reflect.Value) string {
+	if relation.Type != "" || v.Kind() == reflect.String {
+		return relation.Type
+	}
+	return resourceType(v.Interface())
+}
+
+// relatedID => the ID of a related resource (or the ID itself)
+func relatedID(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	if identifier, ok := v.Interface().(jsonapi.MarshalIdentifier); ok {
+		return identifier.GetID()
+	}
+	if v.CanAddr() {
+		if identifier, ok := v.Addr().Interface().(jsonapi.MarshalIdentifier); ok {
+			return identifier.GetID()
+		}
+	}
+	return ""
+}
+
+// GetReferences => the resource's hand-written GetReferences or the references of its relation tags
+func GetReferences(resource interface{}) []jsonapi.Reference {
+	if referencer, ok := resource.(jsonapi.MarshalReferences); ok {
+		return referencer.GetReferences()
+	}
+
+	references := []jsonapi.Reference{}
+	for _, relation := range GetRelations(resource) {
+		references = append(references, jsonapi.Reference{Type: relation.Type, Name: relation.Name})
+	}
+	return references
+}
+
+// GetReferencedIDs => the resource's hand-written GetReferencedIDs or the IDs held by its relation fields
+func GetReferencedIDs(resource interface{}) []jsonapi.ReferenceID {
+	if linker, ok := resource.(jsonapi.MarshalLinkedRelations); ok {
+		return linker.GetReferencedIDs()
+	}
+
+	ids := []jsonapi.ReferenceID{}
+	for _, relation := range GetRelations(resource) {
+		for _, v := range relatedValues(resource, relation) {
+			if id := relatedID(v); id != "" {
+				ids = append(ids, jsonapi.ReferenceID{ID: id, Type: relatedType(relation, v), Name: relation.Name})
+			}
+		}
+	}
+	return ids
+}
+
+// GetReferencedStructs => the resource's hand-written GetReferencedStructs or the related resources
+// held by its relation fields, i.e., the resources to include in the response
+// NOTE: ResourceIdentifiers only link the related resources, so they are not included
+func GetReferencedStructs(resource interface{}) []jsonapi.MarshalIdentifier {
+	if includer, ok := resource.(jsonapi.MarshalIncludedRelations); ok {
+		return includer.GetReferencedStructs()
+	}
+
+	structs := []jsonapi.MarshalIdentifier{}
+	for _, relation := range GetRelations(resource) {
+		for _, v := range relatedValues(resource, relation) {
+			if reflect.Indirect(v).Type() == resourceIdentifierType {
+				continue
+			}
+			if identifier, ok := v.Interface().(jsonapi.MarshalIdentifier); ok && identifier.GetID() != "" {
+				structs = append(structs, identifier)
+			}
+		}
+	}
+	return structs
+}
+
+// SetToManyReferenceIDs => calls the resource's hand-written SetToManyReferenceIDs or sets its relation field
+// to the IDs or to related resources w/ the IDs
+func SetToManyReferenceIDs(resource interface{}, name string, IDs []string) error {
+	if setter, ok := resource.(jsonapi.UnmarshalToManyRelations); ok {
+		return setter.SetToManyReferenceIDs(name, IDs)
+	}
+
+	relation, ok := getRelation(resource, name)
+	if !ok || !relation.ToMany {
+		return fmt.Errorf("there is no to-many relationship with the name %s", name)
+	}
+
+	identifiers := []ResourceIdentifier{}
+	for _, id := range IDs {
+		identifiers = append(identifiers, ResourceIdentifier{Type: relation.Type, ID: id})
+	}
+	return setRelated(resource, relation, identifiers)
+}
+
+// SetToOneReferenceID => calls the resource's hand-written SetToOneReferenceID or sets its relation field
+// to the ID or to a related resource w/ the ID
+// NOTE: an empty ID clears the relationship
+func SetToOneReferenceID(resource interface{}, name string, ID string) error {
+	if setter, ok := resource.(jsonapi.UnmarshalToOneRelations); ok {
+		return setter.SetToOneReferenceID(name, ID)
+	}
+
+	relation, ok := getRelation(resource, name)
+	if !ok || relation.ToMany {
+		return fmt.Errorf("there is no to-one relationship with the name %s", name)
+	}
+
+	if ID == "" {
+		return setRelated(resource, relation, nil)
+	}
+	return setRelated(resource, relation, []ResourceIdentifier{{Type: relation.Type, ID: ID}})
+}
+
+// setRelated => sets a relation field to the related resources (or IDs), where no resources clear a to-one field
+func setRelated(resource interface{}, relation Relation, identifiers []ResourceIdentifier) error {
+	fv := reflect.ValueOf(resource).Elem().Field(relation.field)
+	if !relation.ToMany {
+		if len(identifiers) == 0 {
+			fv.Set(reflect.Zero(fv.Type()))
+			return nil
+		}
+		return setRelatedIdentifier(fv, identifiers[0])
+	}
+
+	slice := reflect.MakeSlice(fv.Type(), len(identifiers), len(identifiers))
+	for i, identifier := range identifiers {
+		if err := setRelatedIdentifier(slice.Index(i), identifier); err != nil {
+			return err
+		}
+	}
+	fv.Set(slice)
+	return nil
+}
+
+// setRelatedIdentifier => sets an ID or ResourceIdentifier field, or allocates a related resource and sets its ID
+// NOTE: the resource of an interface field is allocated by the registry, see RegisterResource
+func setRelatedIdentifier(v reflect.Value, identifier ResourceIdentifier) error {
+	id := identifier.ID
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(id)
+		return nil
+	case v.Type() == resourceIdentifierType:
+		v.Set(reflect.ValueOf(identifier))
+		return nil
+	case v.Type() == reflect.PtrTo(resourceIdentifierType):
+		v.Set(reflect.ValueOf(&identifier))
+		return nil
+	case v.Kind() == reflect.Interface:
+		related, ok := NewResource(identifier.Type)
+		if !ok {
+			if !resourceIdentifierType.AssignableTo(v.Type()) {
+				return fmt.Errorf("%s is not a registered type", identifier.Type)
+			}
+			v.Set(reflect.ValueOf(identifier))
+			return nil
+		}
+		if err := related.(jsonapi.UnmarshalIdentifier).SetID(id); err != nil {
+			return err
+		}
+		if rv := reflect.ValueOf(related); rv.Type().AssignableTo(v.Type()) {
+			v.Set(rv)
+		} else if rv.Elem().Type().AssignableTo(v.Type()) {
+			v.Set(rv.Elem())
+		} else {
+			return fmt.Errorf("%s cannot be assigned to %s", rv.Type(), v.Type())
+		}
+		return nil
+	}
+
+	ptr := reflect.New(v.Type())
+	if v.Kind() == reflect.Ptr {
+		ptr.Elem().Set(reflect.New(v.Type().Elem()))
+		ptr = ptr.Elem()
+	}
+	setter, ok := ptr.Interface().(jsonapi.UnmarshalIdentifier)
+	if !ok {
+		return fmt.Errorf("%s must implement SetID", v.Type())
+	}
+	if err := setter.SetID(id); err != nil {
+		return err
+	}
+
+	if v.Kind() == reflect.Ptr {
+		v.Set(ptr)
+	} else {
+		v.Set(ptr.Elem())
+	}
+	return nil
+}
+
+// splitRelationships => removes the relationships that api2go cannot decode, i.e., those declared by
+// relation tags of a resource w/o the corresponding hand-written setter, and returns them
+func splitRelationships(resource interface{}, relationships map[string]interface{}) map[string]interface{} {
+	tagged := map[string]interface{}{}
+
+	_, toOne := resource.(jsonapi.UnmarshalToOneRelations)
+	_, toMany := resource.(jsonapi.UnmarshalToManyRelations)
+	for _, relation := range GetRelations(resource) {
+		if value, ok := relationships[relation.Name]; ok && !(relation.ToMany && toMany) && !(!relation.ToMany && toOne) {
+			tagged[relation.Name] = value
+			delete(relationships, relation.Name)
+		}
+	}
+
+	return tagged
+}
+
+// decodeRelationships => decodes the relationships declared by relation tags into the resource
+// NOTE: returns a 400 error for each malformed relationship and a 409 error for each resource identifier
+// of the wrong type, EX: /data/relationships/drivers/data/1/type
+func decodeRelationships(resource interface{}, relationships map[string]interface{}) []JsonApiError {
+	errors := []JsonApiError{}
+
+	for _, relation := range GetRelations(resource) {
+		value, ok := relationships[relation.Name]
+		if !ok {
+			continue
+		}
+
+		pointer := "/data/relationships/" + pointerToken(formatKey(relation.Name))
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			errors = append(errors, relationshipError(pointer, "400", "expected object, not "+jsonType(value)))
+			continue
+		}
+		data, ok := object["data"]
+		if !ok {
+			errors = append(errors, relationshipError(pointer, "400", "expected data"))
+			continue
+		}
+
+		pointer += "/data"
+		if relation.ToMany {
+			identifiers, ok := data.([]interface{})
+			if !ok {
+				errors = append(errors, relationshipError(pointer, "400", "expected array, not "+jsonType(data)))
+				continue
+			}
+
+			related := []ResourceIdentifier{}
+			failed := false
+			for i, value := range identifiers {
+				identifier, errs := resourceIdentifier(relation, value, pointer+"/"+strconv.Itoa(i))
+				errors = append(errors, errs...)
+				failed = failed || len(errs) > 0
+				related = append(related, identifier)
+			}
+			if !failed {
+				if err := setRelated(resource, relation, related); err != nil {
+					errors = append(errors, relationshipError(pointer, "400", err.Error()))
+				}
+			}
+		} else {
+			related, errs := []ResourceIdentifier{}, []JsonApiError{}
+			if data != nil {
+				identifier, e := resourceIdentifier(relation, data, pointer)
+				related, errs = append(related, identifier), e
+			}
+			errors = append(errors, errs...)
+			if len(errs) == 0 {
+				if err := setRelated(resource, relation, related); err != nil {
+					errors = append(errors, relationshipError(pointer, "400", err.Error()))
+				}
+			}
+		}
+	}
+
+	return errors
+}
+
+// resourceIdentifier => decodes a resource identifier object, EX: {"type": "drivers", "id": "1"}
+// NOTE: the type must be one of the relationship's allowed types
+func resourceIdentifier(relation Relation, value interface{}, pointer string) (ResourceIdentifier, []JsonApiError) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return ResourceIdentifier{}, []JsonApiError{relationshipError(pointer, "400", "expected object, not "+jsonType(value))}
+	}
+
+	identifier := ResourceIdentifier{}
+	if identifier.ID, ok = object["id"].(string); !ok {
+		return identifier, []JsonApiError{relationshipError(pointer+"/id", "400", "expected id to be a string")}
+	}
+	if identifier.Type, ok = object["type"].(string); !ok {
+		return identifier, []JsonApiError{relationshipError(pointer+"/type", "400", "expected type to be a string")}
+	}
+	if allowed := relation.allowedTypes(); len(allowed) > 0 && !contains(allowed, identifier.Type) {
+		return identifier, []JsonApiError{relationshipError(pointer+"/type", "409",
+			"expected type "+strings.Join(allowed, " or ")+", not "+identifier.Type)}
+	}
+	return identifier, nil
+}
+
+func relationshipError(pointer string, status string, detail string) JsonApiError {
+	title := "Invalid Relationship"
+	if status == "409" {
+		title = "Conflict"
+	}
+	return JsonApiError{Status: status, Title: title, Detail: detail, Source: &JsonApiErrorSource{Pointer: pointer}}
+}
+
+// renderRelationships => adds the relationships and included resources declared by relation tags
+// to a marshalled response and removes the relation fields api2go marshalled as attributes
+// NOTE: also adds the resources' and relationships' links and meta, see ResourceLinker and RelationshipMetaer
+// NOTE: resources w/ hand-written GetReferencedIDs or GetReferencedStructs are left to api2go
+func renderRelationships(jasi JSONApiServerInfo, data interface{}, response interface{}) error {
+	doc, ok := response.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	// the marshalled resources keyed by type and id
+	values := map[string]interface{}{}
+	key := func(t string, id string) string { return t + "/" + id }
+	for _, v := range marshalledValues(data) {
+		if identifier, ok := v.(jsonapi.MarshalIdentifier); ok {
+			values[key(resourceType(v), identifier.GetID())] = v
+		}
+	}
+
+	included, _ := doc["included"].([]interface{})
+	seen := map[string]bool{}
+	for _, entry := range responseEntries(response) {
+		t, _ := entry["type"].(string)
+		id, _ := entry["id"].(string)
+		seen[key(t, id)] = true
+	}
+
+	entries := responseEntries(response)
+	primary := primaryValues(data)
+	for i, entry := range entries {
+		var v interface{}
+		if i < len(primary) {
+			v = primary[i]
+		} else {
+			t, _ := entry["type"].(string)
+			id, _ := entry["id"].(string)
+			v = values[key(t, id)]
+		}
+		if v == nil {
+			continue
+		}
+		decorateEntry(jasi, v, entry)
+		renderLinksAndMeta(jasi, v, entry)
+
+		if _, ok := v.(jsonapi.MarshalIncludedRelations); ok || i >= len(primary) {
+			continue
+		}
+		for _, s := range GetReferencedStructs(v) {
+			k := key(resourceType(s), s.GetID())
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+
+			j, err := jsonapi.MarshalToJSONWithURLs(s, jasi)
+			if err != nil {
+				return err
+			}
+			var marshalled map[string]interface{}
+			if err := json.Unmarshal(j, &marshalled); err != nil {
+				return err
+			}
+			if entry, ok := marshalled["data"].(map[string]interface{}); ok {
+				decorateEntry(jasi, s, entry)
+				renderLinksAndMeta(jasi, s, entry)
+				included = append(included, entry)
+			}
+		}
+	}
+
+	if len(included) > 0 {
+		doc["included"] = included
+	}
+	return nil
+}
+
+// decorateEntry => removes a resource's relation fields from its attributes and adds its tag declared relationships
+func decorateEntry(jasi JSONApiServerInfo, resource interface{}, entry map[string]interface{}) {
+	relations := GetRelations(resource)
+	if len(relations) == 0 {
+		return
+	}
+
+	t := reflect.Indirect(reflect.ValueOf(resource)).Type()
+	if attributes, ok := entry["attributes"].(map[string]interface{}); ok {
+		for _, relation := range relations {
+			delete(attributes, attributeName(t.Field(relation.field)))
+		}
+	}
+
+	if _, ok := resource.(jsonapi.MarshalLinkedRelations); ok {
+		return
+	}
+
+	identifier, _ := resource.(jsonapi.MarshalIdentifier)
+	if identifier == nil {
+		identifier = ResourceIdentifier{Type: resourceType(resource)}
+	}
+	self := strings.TrimSuffix(ResourceURL(jasi, identifier), "/")
+
+	relationships, _ := entry["relationships"].(map[string]interface{})
+	if relationships == nil {
+		relationships = map[string]interface{}{}
+	}
+	for _, relation := range relations {
+		var linkage interface{}
+		identifiers := []interface{}{}
+		for _, v := range relatedValues(resource, relation) {
+			if id := relatedID(v); id != "" {
+				identifiers = append(identifiers, map[string]interface{}{"type": relatedType(relation, v), "id": id})
+			}
+		}
+		if relation.ToMany {
+			linkage = identifiers
+		} else if len(identifiers) > 0 {
+			linkage = identifiers[0]
+		}
+
+		relationships[relation.Name] = map[string]interface{}{
+			"data": linkage,
+			"links": map[string]interface{}{
+				"self":    self + "/relationships/" + relation.Name,
+				"related": self + "/" + relation.Name,
+			},
+		}
+	}
+	entry["relationships"] = relationships
+}
@@ -0,0 +1,335 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/manyminds/api2go/jsonapi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Dealer Resource (w/ relationships declared by relation tags)
+type DealerResource struct {
+	Resource    `jsonapi:"-"`
+	Name        string               `json:"name,omitempty" jsonapi:"name=name"`
+	Owner       *DriverResource      `json:"owner,omitempty" jsonapi:"relation=owner"`
+	Cars        []AutomobileResource `json:"cars,omitempty" jsonapi:"relation=cars;type=automobiles"`
+	MechanicIDs []string             `json:"-" jsonapi:"relation=mechanics,type=drivers"`
+	ManagerID   string               `json:"-" jsonapi:"relation=manager,type=drivers"`
+}
+
+func (r DealerResource) GetName() string {
+	return "dealers"
+}
+
+func (r *DealerResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *DealerResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+// Shed Resource (w/ a relation tag and hand-written relationship methods)
+type ShedResource struct {
+	Resource   `jsonapi:"-"`
+	Drivers    []DriverResource `json:"-" jsonapi:"relation=drivers"`
+	DriversIDs []string         `json:"-" jsonapi:"-"`
+}
+
+func (r ShedResource) GetName() string {
+	return "sheds"
+}
+
+func (r *ShedResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *ShedResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+func (r ShedResource) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{{Type: "drivers", Name: "drivers"}}
+}
+
+func (r ShedResource) GetReferencedIDs() []jsonapi.ReferenceID {
+	return []jsonapi.ReferenceID{{ID: "hand-written", Type: "drivers", Name: "drivers"}}
+}
+
+func (r *ShedResource) SetToManyReferenceIDs(name string, IDs []string) error {
+	if name != "drivers" {
+		return errors.New("There is no to-many relationship with the name " + name)
+	}
+	r.DriversIDs = IDs
+	return nil
+}
+
+// Report Resource (w/ polymorphic relationships)
+type ReportResource struct {
+	Resource `jsonapi:"-"`
+	Subject  jsonapi.MarshalIdentifier `json:"-" jsonapi:"relation=subject,types=automobiles|trailers"`
+	Subjects []ResourceIdentifier      `json:"-" jsonapi:"relation=subjects;types=automobiles|trailers"`
+}
+
+func (r ReportResource) GetName() string {
+	return "reports"
+}
+
+func (r *ReportResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *ReportResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+// Citation Resource (w/ polymorphic relationships to resource identifier pointers)
+type CitationResource struct {
+	Resource `jsonapi:"-"`
+	Subject  *ResourceIdentifier   `json:"-" jsonapi:"relation=subject,types=automobiles|trailers"`
+	Subjects []*ResourceIdentifier `json:"-" jsonapi:"relation=subjects;types=automobiles|trailers"`
+}
+
+func (r CitationResource) GetName() string {
+	return "citations"
+}
+
+type TrailerResource struct {
+	Resource `jsonapi:"-"`
+	Axles    int `json:"axles,omitempty" jsonapi:"name=axles"`
+}
+
+func (r TrailerResource) GetName() string {
+	return "trailers"
+}
+
+var _ = Describe("Relations", func() {
+	render := func(data interface{}) map[string]interface{} {
+		var response map[string]interface{}
+		j, err := jsonapi.MarshalToJSONWithURLs(data, TEST_SERVER_INFO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(json.Unmarshal(j, &response)).Should(Succeed())
+		Ω(renderRelationships(TEST_SERVER_INFO, data, response)).Should(Succeed())
+		return response
+	}
+
+	It("should parse relation tags separated by commas or semicolons", func() {
+		Ω(GetRelations(DealerResource{})).Should(Equal([]Relation{
+			{Name: "owner", Type: "drivers", field: 2},
+			{Name: "cars", Type: "automobiles", ToMany: true, field: 3},
+			{Name: "mechanics", Type: "drivers", ToMany: true, field: 4},
+			{Name: "manager", Type: "drivers", field: 5},
+		}))
+		Ω(attributeNames(DealerResource{})).Should(Equal([]string{"name"}))
+	})
+
+	Context("marshalling", func() {
+		dealer := func() DealerResource {
+			owner := DriverResource{Name: "Bob"}
+			owner.SetID("d1")
+			car := AutomobileResource{}
+			car.SetID("a1")
+			r := DealerResource{Name: "Main", Owner: &owner, Cars: []AutomobileResource{car},
+				MechanicIDs: []string{"d2", "d3"}}
+			r.SetID("g1")
+			return r
+		}
+
+		It("should provide the relationship interfaces", func() {
+			r := dealer()
+			Ω(GetReferences(r)).Should(HaveLen(4))
+			Ω(GetReferencedIDs(r)).Should(Equal([]jsonapi.ReferenceID{
+				{ID: "d1", Type: "drivers", Name: "owner"},
+				{ID: "a1", Type: "automobiles", Name: "cars"},
+				{ID: "d2", Type: "drivers", Name: "mechanics"},
+				{ID: "d3", Type: "drivers", Name: "mechanics"},
+			}))
+			Ω(GetReferencedStructs(r)).Should(HaveLen(2))
+		})
+
+		It("should render relationships and included resources instead of attributes", func() {
+			response := render(dealer())
+			data := response["data"].(map[string]interface{})
+			Ω(data["attributes"]).Should(Equal(map[string]interface{}{"name": "Main"}))
+
+			relationships := data["relationships"].(map[string]interface{})
+			Ω(relationships["owner"]).Should(Equal(map[string]interface{}{
+				"data": map[string]interface{}{"type": "drivers", "id": "d1"},
+				"links": map[string]interface{}{
+					"self":    "http://my.domain/v1/dealers/g1/relationships/owner",
+					"related": "http://my.domain/v1/dealers/g1/owner",
+				},
+			}))
+			Ω(relationships["mechanics"].(map[string]interface{})["data"]).Should(HaveLen(2))
+			Ω(relationships["manager"].(map[string]interface{})["data"]).Should(BeNil())
+
+			included := response["included"].([]interface{})
+			Ω(included).Should(HaveLen(2))
+			Ω(included[0].(map[string]interface{})["id"]).Should(Equal("d1"))
+			Ω(included[1].(map[string]interface{})["type"]).Should(Equal("automobiles"))
+		})
+
+		It("should render empty relationships", func() {
+			r := DealerResource{}
+			r.SetID("g2")
+			relationships := render([]DealerResource{r})["data"].([]interface{})[0].(map[string]interface{})["relationships"]
+			Ω(relationships.(map[string]interface{})["cars"].(map[string]interface{})["data"]).Should(Equal([]interface{}{}))
+			Ω(relationships.(map[string]interface{})["owner"].(map[string]interface{})["data"]).Should(BeNil())
+		})
+
+		It("should prefer hand-written methods", func() {
+			driver := DriverResource{}
+			driver.SetID("d1")
+			r := ShedResource{Drivers: []DriverResource{driver}}
+			r.SetID("s1")
+
+			Ω(GetReferencedIDs(r)[0].ID).Should(Equal("hand-written"))
+			data := render(r)["data"].(map[string]interface{})
+			Ω(data["attributes"]).ShouldNot(HaveKey("drivers"))
+			drivers := data["relationships"].(map[string]interface{})["drivers"].(map[string]interface{})
+			Ω(drivers["data"]).Should(Equal([]interface{}{map[string]interface{}{"type": "drivers", "id": "hand-written"}}))
+		})
+	})
+
+	Context("UnmarshalRequest", func() {
+		It("should set related resources and IDs", func() {
+			r := DealerResource{}
+			body := []byte(`{"data":{"type":"dealers","attributes":{"name":"Main"},"relationships":{` +
+				`"owner":{"data":{"type":"drivers","id":"d1"}},` +
+				`"cars":{"data":[{"type":"automobiles","id":"a1"},{"type":"automobiles","id":"a2"}]},` +
+				`"mechanics":{"data":[{"type":"drivers","id":"d2"}]},` +
+				`"manager":{"data":{"type":"drivers","id":"d3"}}}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)).Should(BeEmpty())
+			Ω(r.Name).Should(Equal("Main"))
+			Ω(r.Owner.GetID()).Should(Equal("d1"))
+			Ω(r.Cars).Should(HaveLen(2))
+			Ω(r.Cars[1].GetID()).Should(Equal("a2"))
+			Ω(r.MechanicIDs).Should(Equal([]string{"d2"}))
+			Ω(r.ManagerID).Should(Equal("d3"))
+			Ω(r.Has("cars")).Should(BeTrue())
+		})
+
+		It("should clear a to-one relationship set to null", func() {
+			r := DealerResource{}
+			body := []byte(`{"data":{"type":"dealers","id":"g1","relationships":{"owner":{"data":null}}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(r.Owner).Should(BeNil())
+			Ω(r.IsExplicitNull("owner")).Should(BeTrue())
+		})
+
+		It("should return a 409 for a related resource of the wrong type", func() {
+			r := DealerResource{}
+			body := []byte(`{"data":{"type":"dealers","relationships":{"cars":{"data":[{"type":"automobiles","id":"a1"},` +
+				`{"type":"drivers","id":"d1"}]}}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("409"))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/relationships/cars/data/1/type"))
+		})
+
+		It("should return a 400 for a malformed relationship", func() {
+			r := DealerResource{}
+			body := []byte(`{"data":{"type":"dealers","relationships":{"cars":{"data":{"type":"automobiles","id":"a1"}}}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("400"))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/relationships/cars/data"))
+		})
+
+		It("should prefer a hand-written setter", func() {
+			r := ShedResource{}
+			body := []byte(`{"data":{"type":"sheds","relationships":{"drivers":{"data":[{"type":"drivers","id":"d1"}]}}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)).Should(BeEmpty())
+			Ω(r.DriversIDs).Should(Equal([]string{"d1"}))
+			Ω(r.Drivers).Should(BeEmpty())
+		})
+	})
+
+	Context("polymorphic", func() {
+		BeforeEach(func() {
+			RegisterResource(AutomobileResource{})
+			RegisterResource(TrailerResource{})
+		})
+
+		AfterEach(func() {
+			registry = map[string]reflect.Type{}
+		})
+
+		It("should parse the allowed types", func() {
+			Ω(GetRelations(ReportResource{})).Should(Equal([]Relation{
+				{Name: "subject", Types: []string{"automobiles", "trailers"}, field: 1},
+				{Name: "subjects", Types: []string{"automobiles", "trailers"}, ToMany: true, field: 2},
+			}))
+		})
+
+		It("should resolve the type of each related resource at runtime", func() {
+			trailer := TrailerResource{Axles: 2}
+			trailer.SetID("t1")
+			r := ReportResource{Subject: trailer,
+				Subjects: []ResourceIdentifier{{Type: "automobiles", ID: "a1"}, {Type: "trailers", ID: "t2"}}}
+			r.SetID("r1")
+
+			Ω(GetReferencedIDs(r)).Should(Equal([]jsonapi.ReferenceID{
+				{ID: "t1", Type: "trailers", Name: "subject"},
+				{ID: "a1", Type: "automobiles", Name: "subjects"},
+				{ID: "t2", Type: "trailers", Name: "subjects"},
+			}))
+
+			response := render(r)
+			relationships := response["data"].(map[string]interface{})["relationships"].(map[string]interface{})
+			Ω(relationships["subject"].(map[string]interface{})["data"]).Should(Equal(
+				map[string]interface{}{"type": "trailers", "id": "t1"}))
+			Ω(relationships["subjects"].(map[string]interface{})["data"]).Should(Equal([]interface{}{
+				map[string]interface{}{"type": "automobiles", "id": "a1"},
+				map[string]interface{}{"type": "trailers", "id": "t2"},
+			}))
+
+			// NOTE: resource identifiers are not included
+			included := response["included"].([]interface{})
+			Ω(included).Should(HaveLen(1))
+			Ω(included[0].(map[string]interface{})["type"]).Should(Equal("trailers"))
+		})
+
+		It("should resolve the type of resource identifier pointers", func() {
+			r := CitationResource{Subject: &ResourceIdentifier{Type: "trailers", ID: "t1"},
+				Subjects: []*ResourceIdentifier{{Type: "automobiles", ID: "a1"}, nil}}
+
+			Ω(GetReferencedIDs(r)).Should(Equal([]jsonapi.ReferenceID{
+				{ID: "t1", Type: "trailers", Name: "subject"},
+				{ID: "a1", Type: "automobiles", Name: "subjects"},
+			}))
+		})
+
+		It("should allocate registered resources of the given types", func() {
+			r := ReportResource{}
+			body := []byte(`{"data":{"type":"reports","relationships":{"subject":{"data":{"type":"trailers","id":"t1"}},` +
+				`"subjects":{"data":[{"type":"trailers","id":"t2"},{"type":"automobiles","id":"a1"}]}}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)).Should(BeEmpty())
+			Ω(r.Subject).Should(BeAssignableToTypeOf(&TrailerResource{}))
+			Ω(r.Subject.GetID()).Should(Equal("t1"))
+			Ω(r.Subjects).Should(Equal([]ResourceIdentifier{{Type: "trailers", ID: "t2"}, {Type: "automobiles", ID: "a1"}}))
+		})
+
+		It("should return a 409 for a disallowed type", func() {
+			r := ReportResource{}
+			body := []byte(`{"data":{"type":"reports","relationships":{"subjects":{"data":[{"type":"drivers","id":"d1"}]}}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("409"))
+			Ω(errors[0].Detail).Should(Equal("expected type automobiles or trailers, not drivers"))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/relationships/subjects/data/0/type"))
+		})
+
+		It("should describe the allowed types", func() {
+			schema := resourceSchema("reports", ReportResource{}, false)
+			relationships := schema["properties"].(map[string]interface{})["relationships"].(map[string]interface{})
+			subjects := relationships["properties"].(map[string]interface{})["subjects"].(map[string]interface{})
+			items := subjects["properties"].(map[string]interface{})["data"].(map[string]interface{})["items"].(map[string]interface{})
+			Ω(items["properties"].(map[string]interface{})["type"]).Should(Equal(
+				map[string]interface{}{"enum": []string{"automobiles", "trailers"}}))
+		})
+	})
+})
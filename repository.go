@@ -0,0 +1,330 @@
+package gsonapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// Repository => stores the records (resources) of a resource type
+// NOTE: FindAll, FindMany and FindOne return copies of the stored records, i.e., a slice of the resource type
+// and a pointer to the resource type, so that they can be passed to HandleIndexResponse and HandleGetResponse
+type Repository interface {
+	// FindAll => the records that match the query's filters in its sort order and page,
+	// and the total number of matching records before paging
+	FindAll(q *Query) (interface{}, int, *JsonApiError)
+	// FindOne => the record w/ the given id, or a 404 error
+	FindOne(id string) (interface{}, *JsonApiError)
+	// FindMany => the records w/ the given ids in the order of the ids, skipping the ones that do not exist
+	FindMany(ids []string) (interface{}, *JsonApiError)
+	// Create => stores a new record, assigning it an id if it has none, or returns a 409 error if the id is taken
+	Create(record Resourcer) *JsonApiError
+	// Update => replaces a stored record, or returns a 404 error
+	Update(record Resourcer) *JsonApiError
+	// Delete => removes a stored record, or returns a 404 error
+	// NOTE: soft deletable resources are usually marked deleted and updated instead, see SoftDelete
+	Delete(id string) *JsonApiError
+}
+
+// repositories => registered repositories keyed by resource type
+var repositories = map[string]Repository{}
+
+// RegisterRepository => sets the repository of a resource type (call during app startup)
+// EX: RegisterRepository("automobiles", NewMemoryRepository(AutomobileResource{}))
+func RegisterRepository(resourceType string, repository Repository) {
+	repositories[resourceType] = repository
+}
+
+// RepositoryFor => returns the repository registered for a resource type
+func RepositoryFor(resourceType string) (Repository, bool) {
+	repository, ok := repositories[resourceType]
+	return repository, ok
+}
+
+// MemoryRepository => a thread-safe, in-memory Repository, e.g., for tests and prototypes
+// NOTE: records are ordered by creation unless the query sorts them, and ties keep that order; records are deep copied
+// when they are stored and found, see deepCopy
+type MemoryRepository struct {
+	mutex   sync.RWMutex
+	t       reflect.Type             // the resource type, EX: AutomobileResource
+	records map[string]reflect.Value // copies of the records keyed by id
+	ids     []string                 // ids in creation order
+	nextID  int
+}
+
+// NewMemoryRepository => an empty repository for the prototype's resource type
+// EX: NewMemoryRepository(AutomobileResource{})
+func NewMemoryRepository(prototype interface{}) *MemoryRepository {
+	t := reflect.TypeOf(prototype)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return &MemoryRepository{t: t, records: map[string]reflect.Value{}}
+}
+
+// FindAll => see Repository
+// NOTE: skips the soft deleted records the query does not include and the records of other tenants, see TenantOwner
+func (m *MemoryRepository) FindAll(q *Query) (interface{}, int, *JsonApiError) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if q == nil {
+		q = &Query{}
+	}
+
+	type match struct {
+		record     reflect.Value
+		attributes map[string]interface{}
+	}
+	matches := []match{}
+	for _, id := range m.ids {
+		record := m.records[id]
+		ptr := record.Addr().Interface()
+		if !q.Includes(ptr) {
+			continue
+		}
+		if owner, ok := ptr.(TenantOwner); ok && q.Tenant != "" && owner.GetTenant() != q.Tenant {
+			continue
+		}
+		attributes := resourceAttributes(ptr.(jsonapi.MarshalIdentifier))
+		if !q.Matches(attributes) {
+			continue
+		}
+		matches = append(matches, match{record, attributes})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, s := range q.Sort {
+			c := compareValues(matches[i].attributes[s.Attribute], matches[j].attributes[s.Attribute])
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != s.Descending
+		}
+		return false
+	})
+
+	total := len(matches)
+	if q.Page.Size > 0 {
+		number := q.Page.Number
+		if number < 1 {
+			number = 1
+		}
+		start := (number - 1) * q.Page.Size
+		if start > total {
+			start = total
+		}
+		end := start + q.Page.Size
+		if end > total {
+			end = total
+		}
+		matches = matches[start:end]
+	}
+
+	records := reflect.MakeSlice(reflect.SliceOf(m.t), 0, len(matches))
+	for _, match := range matches {
+		records = reflect.Append(records, deepCopy(match.record))
+	}
+	return records.Interface(), total, nil
+}
+
+// FindOne => see Repository
+func (m *MemoryRepository) FindOne(id string) (interface{}, *JsonApiError) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	record, ok := m.records[id]
+	if !ok {
+		return nil, m.notFound()
+	}
+	return deepCopy(record).Addr().Interface(), nil
+}
+
+// FindMany => see Repository
+func (m *MemoryRepository) FindMany(ids []string) (interface{}, *JsonApiError) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	records := reflect.MakeSlice(reflect.SliceOf(m.t), 0, len(ids))
+	for _, id := range ids {
+		if record, ok := m.records[id]; ok {
+			records = reflect.Append(records, deepCopy(record))
+		}
+	}
+	return records.Interface(), nil
+}
+
+// Create => see Repository
+// NOTE: assigns sequential ids, EX: 1, 2, 3
+func (m *MemoryRepository) Create(record Resourcer) *JsonApiError {
+	value, err := m.value(record)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := record.GetID()
+	if id == "" {
+		for taken := true; taken; _, taken = m.records[id] {
+			m.nextID++
+			id = strconv.Itoa(m.nextID)
+		}
+		if e := record.SetID(id); e != nil {
+			return NewError(500).Detail(e.Error()).Build()
+		}
+		value, _ = m.value(record)
+	} else if _, ok := m.records[id]; ok {
+		return NewError(409).Detail(resourceType(record) + " " + id + " already exists").Build()
+	}
+
+	m.records[id] = value
+	m.ids = append(m.ids, id)
+	return nil
+}
+
+// Update => see Repository
+func (m *MemoryRepository) Update(record Resourcer) *JsonApiError {
+	value, err := m.value(record)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := record.GetID()
+	if _, ok := m.records[id]; !ok {
+		return m.notFound()
+	}
+	m.records[id] = value
+	return nil
+}
+
+// Delete => see Repository
+func (m *MemoryRepository) Delete(id string) *JsonApiError {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.records[id]; !ok {
+		return m.notFound()
+	}
+	delete(m.records, id)
+	for i, v := range m.ids {
+		if v == id {
+			m.ids = append(m.ids[:i], m.ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// value => an addressable deep copy of the record w/o the state of the request it was unmarshalled from (see
+// resetRequestState), or a 500 error if it is not of the repository's resource type
+func (m *MemoryRepository) value(record interface{}) (reflect.Value, *JsonApiError) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if !v.IsValid() || v.Type() != m.t {
+		return reflect.Value{}, NewError(500).Detail("expected a " + m.t.String()).Build()
+	}
+	copied := deepCopy(v)
+	if resetter, ok := copied.Addr().Interface().(requestStateResetter); ok {
+		resetter.resetRequestState()
+	}
+	return copied, nil
+}
+
+// deepCopy => an addressable copy of the value that shares no slices, maps or pointers w/ it
+// NOTE: unexported struct fields are copied shallowly, EX: the errors of an embedded Resource
+func deepCopy(v reflect.Value) reflect.Value {
+	copied := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			copied.Set(deepCopy(v.Elem()).Addr())
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			copied.Set(deepCopy(v.Elem()))
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			copied.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				copied.Index(i).Set(deepCopy(v.Index(i)))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopy(v.Index(i)))
+		}
+	case reflect.Map:
+		if !v.IsNil() {
+			copied.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+			for _, k := range v.MapKeys() {
+				copied.SetMapIndex(k, deepCopy(v.MapIndex(k)))
+			}
+		}
+	case reflect.Struct:
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+	default:
+		copied.Set(v)
+	}
+	return copied
+}
+
+func (m *MemoryRepository) notFound() *JsonApiError {
+	return notFoundError(resourceType(reflect.Zero(m.t).Interface()))
+}
+
+// compareValues => compares decoded json attribute values, where null < false < true < numbers < strings
+func compareValues(a interface{}, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		case string:
+			return 3
+		default:
+			return 4
+		}
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch t := a.(type) {
+	case bool:
+		if t == b.(bool) {
+			return 0
+		} else if !t {
+			return -1
+		}
+		return 1
+	case float64:
+		if t < b.(float64) {
+			return -1
+		} else if t > b.(float64) {
+			return 1
+		}
+	case string:
+		if t < b.(string) {
+			return -1
+		} else if t > b.(string) {
+			return 1
+		}
+	}
+	return 0
+}
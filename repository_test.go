@@ -0,0 +1,187 @@
+package gsonapi
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Repository", func() {
+	var repository *MemoryRepository
+
+	driver := func(id string, name string, age int, active bool) *DriverResource {
+		r := &DriverResource{Name: name, Age: age, Active: active}
+		r.SetID(id)
+		return r
+	}
+	names := func(records interface{}) []string {
+		names := []string{}
+		for _, r := range records.([]DriverResource) {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+	query := func(values url.Values) *Query {
+		q, err := ParseQuery(values)
+		Ω(err).Should(BeNil())
+		return q
+	}
+
+	BeforeEach(func() {
+		repository = NewMemoryRepository(DriverResource{})
+		Ω(repository.Create(driver("d1", "paul", 40, true))).Should(BeNil())
+		Ω(repository.Create(driver("d2", "steve", 45, false))).Should(BeNil())
+		Ω(repository.Create(driver("d3", "ayrton", 34, true))).Should(BeNil())
+		Ω(repository.Create(driver("d4", "niki", 40, true))).Should(BeNil())
+	})
+
+	It("should register a repository by resource type", func() {
+		RegisterRepository("lots", repository)
+		defer delete(repositories, "lots")
+
+		r, ok := RepositoryFor("lots")
+		Ω(ok).Should(BeTrue())
+		Ω(r).Should(Equal(repository))
+		_, ok = RepositoryFor("tents")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should find all records in creation order", func() {
+		records, total, err := repository.FindAll(nil)
+		Ω(err).Should(BeNil())
+		Ω(total).Should(Equal(4))
+		Ω(names(records)).Should(Equal([]string{"paul", "steve", "ayrton", "niki"}))
+	})
+
+	It("should filter, sort and page the records", func() {
+		records, total, _ := repository.FindAll(query(url.Values{"filter[active]": {"true"}, "sort": {"-age,name"}}))
+		Ω(total).Should(Equal(3))
+		Ω(names(records)).Should(Equal([]string{"niki", "paul", "ayrton"}))
+
+		records, total, _ = repository.FindAll(query(url.Values{"sort": {"age"}, "page[number]": {"2"}, "page[size]": {"2"}}))
+		Ω(total).Should(Equal(4))
+		Ω(names(records)).Should(Equal([]string{"niki", "steve"}))
+
+		records, _, _ = repository.FindAll(query(url.Values{"page[number]": {"3"}, "page[size]": {"2"}}))
+		Ω(records).Should(BeEmpty())
+	})
+
+	It("should keep the creation order of records that sort equally", func() {
+		records, _, _ := repository.FindAll(query(url.Values{"sort": {"age"}}))
+		Ω(names(records)).Should(Equal([]string{"ayrton", "paul", "niki", "steve"}))
+	})
+
+	It("should find one record", func() {
+		record, err := repository.FindOne("d2")
+		Ω(err).Should(BeNil())
+		Ω(record.(*DriverResource).Name).Should(Equal("steve"))
+
+		_, err = repository.FindOne("d9")
+		Ω(err.Status).Should(Equal("404"))
+		Ω(err.Detail).Should(Equal("drivers not found"))
+	})
+
+	It("should find many records in the order of their ids", func() {
+		records, err := repository.FindMany([]string{"d3", "d9", "d1"})
+		Ω(err).Should(BeNil())
+		Ω(names(records)).Should(Equal([]string{"ayrton", "paul"}))
+	})
+
+	It("should return copies of the stored records", func() {
+		record, _ := repository.FindOne("d1")
+		record.(*DriverResource).Name = "changed"
+
+		record, _ = repository.FindOne("d1")
+		Ω(record.(*DriverResource).Name).Should(Equal("paul"))
+	})
+
+	It("should deep copy the stored records", func() {
+		dealers := NewMemoryRepository(DealerResource{})
+		r := &DealerResource{Name: "Main", Owner: driver("d1", "paul", 40, true), MechanicIDs: []string{"d2"}}
+		r.SetID("g1")
+		Ω(dealers.Create(r)).Should(BeNil())
+		r.Owner.Name = "changed"
+		r.MechanicIDs[0] = "changed"
+
+		record, _ := dealers.FindOne("g1")
+		Ω(record.(*DealerResource).Owner.Name).Should(Equal("paul"))
+		Ω(record.(*DealerResource).MechanicIDs).Should(Equal([]string{"d2"}))
+		record.(*DealerResource).MechanicIDs[0] = "changed"
+
+		records, _ := dealers.FindMany([]string{"g1"})
+		Ω(records.([]DealerResource)[0].MechanicIDs).Should(Equal([]string{"d2"}))
+	})
+
+	It("should not store the state of the request a record was unmarshalled from", func() {
+		r := DriverResource{}
+		r.SetID("d1")
+		body := []byte(`{"data":{"type":"drivers","id":"d1","attributes":{"name":"paul","age":41}}}`)
+		Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+		r.AddError(AttributeError{Key: "age", Message: "too old"})
+		Ω(repository.Update(&r)).Should(BeNil())
+
+		record, _ := repository.FindOne("d1")
+		Ω(record.(*DriverResource).Age).Should(Equal(41))
+		Ω(record.(*DriverResource).PresentKeys()).Should(BeEmpty())
+		Ω(record.(*DriverResource).Errors()).Should(BeEmpty())
+		Ω(record.(*DriverResource).Loader()).Should(BeNil())
+	})
+
+	It("should assign ids to new records and reject taken ids", func() {
+		r := &DriverResource{Name: "jackie"}
+		Ω(repository.Create(r)).Should(BeNil())
+		Ω(r.GetID()).Should(Equal("1"))
+
+		err := repository.Create(driver("d1", "graham", 50, true))
+		Ω(err.Status).Should(Equal("409"))
+		Ω(err.Detail).Should(Equal("drivers d1 already exists"))
+
+		Ω(repository.Create(&AutomobileResource{}).Status).Should(Equal("500"))
+	})
+
+	It("should update and delete records", func() {
+		Ω(repository.Update(driver("d1", "paul walker", 41, true))).Should(BeNil())
+		record, _ := repository.FindOne("d1")
+		Ω(record.(*DriverResource).Age).Should(Equal(41))
+		Ω(repository.Update(driver("d9", "nobody", 0, false)).Status).Should(Equal("404"))
+
+		Ω(repository.Delete("d2")).Should(BeNil())
+		Ω(repository.Delete("d2").Status).Should(Equal("404"))
+		records, _, _ := repository.FindAll(nil)
+		Ω(names(records)).Should(Equal([]string{"paul walker", "ayrton", "niki"}))
+	})
+
+	It("should exclude soft deleted records unless the query includes them", func() {
+		cabins := NewMemoryRepository(CabinResource{})
+		deleted := &CabinResource{Name: "Pine"}
+		SoftDelete(deleted)
+		cabins.Create(deleted)
+		cabins.Create(&CabinResource{Name: "Oak"})
+
+		records, total, _ := cabins.FindAll(query(url.Values{}))
+		Ω(total).Should(Equal(1))
+		Ω(records.([]CabinResource)[0].Name).Should(Equal("Oak"))
+
+		_, total, _ = cabins.FindAll(query(url.Values{"filter[deleted]": {"true"}}))
+		Ω(total).Should(Equal(2))
+	})
+
+	It("should be safe for concurrent use", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				repository.Create(&DriverResource{Name: "driver " + strconv.Itoa(i)})
+				repository.FindAll(nil)
+			}(i)
+		}
+		wg.Wait()
+
+		_, total, _ := repository.FindAll(nil)
+		Ω(total).Should(Equal(54))
+	})
+})
@@ -0,0 +1,114 @@
+package gsonapi
+
+import (
+	"encoding/json"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// UnmarshalRequest => unmarshals a POST (ActionCreate) or PATCH (ActionUpdate) request body into the resource
+// NOTE: returns 400 errors for malformed documents, 403 errors for attributes that cannot be written,
+// 409 errors for related resources of the wrong type (see Relation)
+// and 400/422 errors for attributes that do not match the resource's schema (see ValidateAttributes)
+// or fail the validators of their validate tags (see ValidateResource);
+// records the attributes and relationships present in the document, see Resource.Has and Resource.IsExplicitNull
+// NOTE: the document's attribute and relationship keys may be in the KeyFormat's casing
+func UnmarshalRequest(jasi JSONApiServerInfo, action Action, body []byte, resource Resourcer) []JsonApiError {
+	var doc map[string]interface{}
+
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return []JsonApiError{{Status: "400", Title: "Invalid Document", Detail: err.Error()}}
+	}
+
+	data, ok := doc["data"].(map[string]interface{})
+	if !ok {
+		return []JsonApiError{{Status: "400", Title: "Invalid Document",
+			Detail: "expected data to be an object", Source: &JsonApiErrorSource{Pointer: "/data"}}}
+	}
+
+	// NOTE: a version's transformers rename the parsed attribute keys of the document (see parseKeys) before they are
+	// matched to the resource's names, i.e., the reverse of a response, which is transformed and then formatted
+	attributes, _ := data["attributes"].(map[string]interface{})
+	parseKeys(attributes)
+	if err := jasi.Version.transformIncoming(resourceType(resource), attributes); err != nil {
+		return []JsonApiError{*err}
+	}
+	canonicalKeys(attributeNames(resource), attributes)
+	if relationships, ok := data["relationships"].(map[string]interface{}); ok {
+		names := []string{}
+		for _, reference := range GetReferences(resource) {
+			names = append(names, reference.Name)
+		}
+		canonicalKeys(names, relationships)
+	}
+
+	errors := CheckWritePermissions(action, resource, attributes)
+	if _, ok := data["id"]; ok && action == ActionCreate && GetFieldPermissions(resource)["id"].ReadOnly {
+		errors = append(errors, JsonApiError{Status: "403", Title: "Forbidden",
+			Detail: "id cannot be written", Source: &JsonApiErrorSource{Pointer: "/data/id"}})
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	if errors := ValidateAttributes(action, resource, attributes); len(errors) > 0 {
+		return errors
+	}
+
+	// NOTE: api2go cannot decode structs, so nested attributes are decoded separately
+	var nested map[string]interface{}
+	if attributes != nil {
+		decoded := map[string]interface{}{}
+		for k, v := range attributes {
+			decoded[k] = v
+		}
+		nested = splitNestedAttributes(resource, decoded)
+		data["attributes"] = decoded
+	}
+
+	// NOTE: api2go cannot decode the relationships declared by relation tags either
+	relationships, _ := data["relationships"].(map[string]interface{})
+	var related map[string]interface{}
+	if relationships != nil {
+		decoded := map[string]interface{}{}
+		for k, v := range relationships {
+			decoded[k] = v
+		}
+		related = splitRelationships(resource, decoded)
+		data["relationships"] = decoded
+	}
+
+	if err := jsonapi.Unmarshal(doc, resource); err != nil {
+		return []JsonApiError{{Status: "400", Title: "Invalid Document", Detail: err.Error()}}
+	}
+	if errors := decodeNestedAttributes(resource, nested); len(errors) > 0 {
+		return errors
+	}
+	if errors := decodeRelationships(resource, related); len(errors) > 0 {
+		return errors
+	}
+
+	if tracker, ok := resource.(presenceTracker); ok {
+		tracker.setPresentKeys(presentKeys(attributes, relationships))
+	}
+	if tracker, ok := resource.(loaderTracker); ok {
+		tracker.setLoader(jasi.Loader)
+	}
+
+	return ValidateResource(action, resource)
+}
+
+// presentKeys => attribute and relationship keys => true if explicitly null
+// NOTE: a relationship is explicitly null when its data is null, i.e., an empty to-one relationship
+func presentKeys(attributes map[string]interface{}, relationships map[string]interface{}) map[string]bool {
+	present := map[string]bool{}
+	for k, v := range attributes {
+		present[k] = v == nil
+	}
+	for k, v := range relationships {
+		relationship, _ := v.(map[string]interface{})
+		data, ok := relationship["data"]
+		present[k] = ok && data == nil
+	}
+	return present
+}
@@ -1,6 +1,13 @@
 package gsonapi
 
 import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/manyminds/api2go/jsonapi"
 	gas "github.com/obieq/gas"
 	validations "github.com/obieq/goar-validations"
 )
@@ -26,7 +33,21 @@ type Resourcer interface {
 
 type Resource struct {
 	ID     string `json:"id,omitempty" jsonapi:"-"`
-	errors map[string]*validations.ValidationError
+	errors []AttributeError
+
+	// keys of the attributes and relationships present in the request document => true if explicitly null
+	// NOTE: nil unless the resource was unmarshalled by UnmarshalRequest
+	present map[string]bool
+	loader  *Loader // the request's loader, see Loader
+}
+
+// AttributeError => a validation error for a single attribute
+type AttributeError struct {
+	Key     string                 // attribute or model field name, EX: year, BodyStyle or inspections/1/location
+	Message string                 // rendered as the error's detail
+	Code    string                 // name of the failed validator, EX: required, max or email
+	Meta    map[string]interface{} // parameters of the failed validator, EX: {"max": 2016}
+	Status  string                 // defaults to 422
 }
 
 // type Link struct {
@@ -34,50 +55,289 @@ type Resource struct {
 // 	Related string `json:"related,omitempty"`
 // }
 
-type JsonApiErrorLink struct {
-	About string `json:"about,omitempty"`
+func (r Resource) GetID() string {
+	return r.ID
 }
 
-type JsonApiErrorSource struct {
-	Pointer   string `json:"pointer,omitempty"`
-	Parameter string `json:"parameter,omitempty"`
+func (r *Resource) SetID(id string) error {
+	r.ID = id
+	return nil
 }
 
-type JsonApiError struct {
-	ID     string              `json:"id,omitempty"`
-	Status string              `json:"status,omitempty"`
-	Code   string              `json:"code,omitempty"`
-	Title  string              `json:"title,omitempty"`
-	Detail string              `json:"detail,omitempty"`
-	Links  *JsonApiErrorLink   `json:"linkz,omitempty"`
-	Source *JsonApiErrorSource `json:"source,omitempty"`
+// Has => true if the request document had the attribute or relationship, EX: r.Has("body-style")
+// NOTE: always false unless the resource was unmarshalled by UnmarshalRequest
+func (r Resource) Has(key string) bool {
+	_, ok := r.present[key]
+	return ok
 }
 
-func (r Resource) GetID() string {
-	return r.ID
+// IsExplicitNull => true if the request document set the attribute (or to-one relationship) to null,
+// i.e., the client asked to clear it, as opposed to omitting it
+func (r Resource) IsExplicitNull(key string) bool {
+	return r.present[key]
 }
 
-func (r *Resource) SetID(id string) error {
-	r.ID = id
-	return nil
+// PresentKeys => the sorted keys of the attributes and relationships present in the request document
+func (r Resource) PresentKeys() []string {
+	keys := make([]string, 0, len(r.present))
+	for k := range r.present {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
+// Errors => the resource's validation errors in the order in which they were added
+// NOTE: responses order them by attribute declaration order, see ResourceErrors
 func (r *Resource) Errors() []JsonApiError {
 	errors := []JsonApiError{}
-	var err JsonApiError
-
-	for k, v := range r.errors {
-		key := gas.String(k).Dasherize()
-		err = JsonApiError{Detail: v.Message, Status: "422"}
-		err.Source = &JsonApiErrorSource{Pointer: "data/attributes/" + key}
+	for _, e := range r.errors {
+		err := JsonApiError{Status: e.Status, Code: e.Code, Detail: e.Message, Meta: e.Meta}
+		if err.Status == "" {
+			err.Status = "422"
+		}
+		err.Source = &JsonApiErrorSource{Pointer: errorPointer(e.Key)}
 		errors = append(errors, err)
 	}
 
 	return errors
 }
 
+// SetErrors => replaces the resource's validation errors w/ the (one per attribute) errors of a model's ErrorMap
+// NOTE: the errors are added in the alphabetical order of their keys
 func (r *Resource) SetErrors(errors map[string]*validations.ValidationError) {
-	r.errors = errors
+	keys := make([]string, 0, len(errors))
+	for k := range errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r.errors = []AttributeError{}
+	for _, k := range keys {
+		r.errors = append(r.errors, AttributeError{Key: k, Message: errors[k].Message})
+	}
+}
+
+// AddError => adds a validation error, e.g., a second message for an attribute that already has one
+func (r *Resource) AddError(e AttributeError) {
+	r.errors = append(r.errors, e)
+}
+
+// AddValidationErrors => adds all of a model's validation errors, EX: r.AddValidationErrors(m.Errors)
+func (r *Resource) AddValidationErrors(errors []*validations.ValidationError) {
+	for _, e := range errors {
+		r.AddError(AttributeError{Key: e.Key, Message: e.Message})
+	}
+}
+
+// AddValidatorError => adds a validation error for a failed goar validator
+// NOTE: the validator's name becomes the error's code and its parameters the error's meta;
+// a blank message defaults to the validator's default message
+func (r *Resource) AddValidatorError(key string, v validations.Validator, message string) {
+	if message == "" {
+		message = strings.TrimSpace(v.DefaultMessage())
+	}
+	code, meta := describeValidator(v)
+	r.AddError(AttributeError{Key: key, Message: message, Code: code, Meta: meta})
+}
+
+// ResourceErrors => a resource's errors (see Resourcer.Errors) ordered by the declaration order of the attributes
+// of the resource's type; errors for the same attribute, or for none, keep their order
+// NOTE: the attributes of the errors' pointers are matched against the attribute names in any casing (see declaredName),
+// EX: the pointer of a model error keyed by BodyStyle becomes /data/attributes/body-style
+func ResourceErrors(resource Resourcer) []JsonApiError {
+	names := attributeNames(resource)
+	sorted := byAttributeOrder{errors: resource.Errors(), positions: []int{}}
+	for i, e := range sorted.errors {
+		position := len(names)
+		if e.Source != nil && strings.HasPrefix(e.Source.Pointer, "/data/attributes/") {
+			segments := strings.SplitN(strings.TrimPrefix(e.Source.Pointer, "/data/attributes/"), "/", 2)
+			name := declaredName(names, parseKey(unescapePointerToken(segments[0])))
+			for j, n := range names {
+				if n == name {
+					position = j
+					break
+				}
+			}
+			if position < len(names) {
+				segments[0] = pointerToken(formatKey(name))
+				source := *e.Source
+				source.Pointer = "/data/attributes/" + strings.Join(segments, "/")
+				sorted.errors[i].Source = &source
+			}
+		}
+		sorted.positions = append(sorted.positions, position)
+	}
+	sort.Stable(sorted)
+	return sorted.errors
+}
+
+// byAttributeOrder => sorts errors by the position of their attribute
+type byAttributeOrder struct {
+	errors    []JsonApiError
+	positions []int
+}
+
+func (b byAttributeOrder) Len() int { return len(b.errors) }
+func (b byAttributeOrder) Swap(i, j int) {
+	b.errors[i], b.errors[j] = b.errors[j], b.errors[i]
+	b.positions[i], b.positions[j] = b.positions[j], b.positions[i]
+}
+func (b byAttributeOrder) Less(i, j int) bool { return b.positions[i] < b.positions[j] }
+
+// setPresentKeys => promoted to resources that embed Resource so that UnmarshalRequest can record presence
+func (r *Resource) setPresentKeys(present map[string]bool) {
+	r.present = present
+}
+
+// presentKeys => nil if presence was not recorded
+func (r *Resource) presentKeys() map[string]bool {
+	return r.present
+}
+
+// Loader => the loader of the request the resource was unmarshalled from (JSONApiServerInfo.Loader),
+// e.g., to look up related records in MapToModel; nil unless UnmarshalRequest was given one
+func (r Resource) Loader() *Loader {
+	return r.loader
+}
+
+// setLoader => promoted to resources that embed Resource so that UnmarshalRequest can record the request's loader
+func (r *Resource) setLoader(loader *Loader) {
+	r.loader = loader
+}
+
+// resetRequestState => promoted to resources that embed Resource so that repositories can store records w/o the
+// errors, presence and loader of the request they were unmarshalled from
+func (r *Resource) resetRequestState() {
+	r.errors, r.present, r.loader = nil, nil, nil
+}
+
+// requestStateResetter => implemented by resources that embed Resource
+type requestStateResetter interface {
+	resetRequestState()
+}
+
+// loaderTracker => implemented by resources that embed Resource
+type loaderTracker interface {
+	setLoader(loader *Loader)
+}
+
+// presenceTracker => implemented by resources that embed Resource
+type presenceTracker interface {
+	setPresentKeys(present map[string]bool)
+	presentKeys() map[string]bool
+}
+
+// describeValidator => the code and meta of a goar validator
+func describeValidator(v validations.Validator) (string, map[string]interface{}) {
+	switch t := v.(type) {
+	case validations.Required:
+		return "required", nil
+	case validations.Min:
+		return "min", map[string]interface{}{"min": t.Min}
+	case validations.Max:
+		return "max", map[string]interface{}{"max": t.Max}
+	case validations.Range:
+		return "range", map[string]interface{}{"min": t.Min.Min, "max": t.Max.Max}
+	case validations.MinSize:
+		return "min-size", map[string]interface{}{"min": t.Min}
+	case validations.MaxSize:
+		return "max-size", map[string]interface{}{"max": t.Max}
+	case validations.Length:
+		return "length", map[string]interface{}{"length": t.N}
+	case validations.Match:
+		return "match", map[string]interface{}{"pattern": t.Regexp.String()}
+	case validations.Email:
+		return "email", nil
+	default:
+		rt := reflect.TypeOf(v)
+		if rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		return gas.String(rt.Name()).Dasherize(), nil
+	}
+}
+
+// attributeNames => a resource's attribute names in declaration order
+func attributeNames(resource interface{}) []string {
+	names := []string{}
+
+	t := reflect.TypeOf(resource)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); isAttribute(field) {
+			names = append(names, attributeName(field))
+		}
+	}
+	return names
+}
+
+// attributePointer => json pointer to an attribute of the request document, EX: /data/attributes/year
+// NOTE: the attribute's name is formatted w/ the KeyFormat
+func attributePointer(name string) string {
+	return "/data/attributes/" + pointerToken(formatKey(name))
+}
+
+// errorPointer => json pointer to the attribute of an error's key, where nested attributes are separated by slashes
+// EX: year => /data/attributes/year and inspections/1/location => /data/attributes/inspections/1/location
+// NOTE: the attribute and nested attribute names are formatted w/ the KeyFormat; ResourceErrors matches the
+// attribute against the resource's attribute names, EX: BodyStyle => body-style
+func errorPointer(key string) string {
+	segments := strings.Split(key, "/")
+	pointer := attributePointer(segments[0])
+	for _, segment := range segments[1:] {
+		if _, err := strconv.Atoi(segment); err != nil {
+			segment = formatKey(segment)
+		}
+		pointer += "/" + segment
+	}
+	return pointer
+}
+
+// resourceType => the jsonapi type of a resource, or of a slice's elements
+// EX: AutomobileResource{} => "automobiles"
+func resourceType(v interface{}) string {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return ""
+	}
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+		v = reflect.Zero(t).Interface()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		if reflect.ValueOf(v).IsNil() {
+			v = reflect.New(t).Interface()
+		}
+	}
+
+	if namer, ok := v.(jsonapi.EntityNamer); ok {
+		return namer.GetName()
+	}
+	return jsonapi.Pluralize(jsonapi.Jsonify(t.Name()))
+}
+
+// resourceAttributes => a resource's attributes as they would be rendered in a response
+func resourceAttributes(v jsonapi.MarshalIdentifier) map[string]interface{} {
+	attributes := map[string]interface{}{}
+
+	doc, err := jsonapi.Marshal(v)
+	if err != nil {
+		return attributes
+	}
+	data, _ := doc["data"].(map[string]interface{})
+	if j, err := json.Marshal(data["attributes"]); err == nil {
+		json.Unmarshal(j, &attributes)
+	}
+
+	return attributes
 }
 
 // func UnmarshalJsonApiData(source interface{}, destination interface{}) error {
@@ -1,38 +1,157 @@
 package gsonapi
 
-import . "github.com/onsi/ginkgo"
+import (
+	"regexp"
 
-// . "github.com/onsi/gomega"
+	validations "github.com/obieq/goar-validations"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
 
 var _ = Describe("Resource", func() {
 	var (
-	// errors *map[string]*validations.ValidationError
+		errors *map[string]*validations.ValidationError
 	)
 
 	BeforeEach(func() {
-		// tmp := BuildErrors()
-		// errors = &tmp
+		tmp := BuildErrors()
+		errors = &tmp
 	})
 
 	Context("Errors", func() {
 		It("should set errors", func() {
-			// r := AutomobileResource{}
-			// r.SetErrors(*errors)
+			r := AutomobileResource{}
+			r.SetErrors(*errors)
+			Ω(r.Errors()).Should(HaveLen(2))
 		})
 
 		It("should get errors", func() {
-			// r := AutomobileResource{}
-			// Ω(r.Errors()).Should(HaveLen(0))
-			//
-			// r.SetErrors(*errors)
-			//
-			// // verify
-			// Ω(r.Errors()).Should(HaveLen(2))
-			//
-			// for _, e := range r.Errors() {
-			// 	Ω(e.Status).Should(Equal("422"))
-			// 	Ω([]string{"data/attributes/year", "data/attributes/make"}).Should(ContainElement(e.Source.Pointer))
-			// }
+			r := AutomobileResource{}
+			Ω(ResourceErrors(&r)).Should(HaveLen(0))
+
+			r.SetErrors(*errors)
+
+			// verify
+			Ω(ResourceErrors(&r)).Should(Equal([]JsonApiError{
+				{Status: "422", Detail: "cannot be greater than 2016", Source: &JsonApiErrorSource{Pointer: "/data/attributes/year"}},
+				{Status: "422", Detail: "cannot be blank", Source: &JsonApiErrorSource{Pointer: "/data/attributes/make"}},
+			}))
+		})
+
+		It("should order errors by attribute declaration order", func() {
+			r := AutomobileResource{}
+			r.AddError(AttributeError{Key: "active", Message: "must be set"})
+			r.AddError(AttributeError{Key: "unknown", Message: "is not an attribute"})
+			r.AddError(AttributeError{Key: "BodyStyle", Message: "is too long"})
+			r.AddError(AttributeError{Key: "year", Message: "is too old"})
+			r.AddError(AttributeError{Key: "BodyStyle", Message: "is not a body style"})
+
+			pointers := []string{}
+			details := []string{}
+			for _, e := range ResourceErrors(&r) {
+				pointers = append(pointers, e.Source.Pointer)
+				details = append(details, e.Detail)
+			}
+
+			Ω(pointers).Should(Equal([]string{"/data/attributes/year", "/data/attributes/body-style",
+				"/data/attributes/body-style", "/data/attributes/active", "/data/attributes/unknown"}))
+			Ω(details).Should(Equal([]string{"is too old", "is too long", "is not a body style", "must be set", "is not an attribute"}))
+		})
+
+		It("should return the errors in the order in which they were added", func() {
+			r := AutomobileResource{}
+			r.AddError(AttributeError{Key: "make", Message: "cannot be blank"})
+			r.AddError(AttributeError{Key: "year", Message: "is too old"})
+
+			Ω(r.Errors()[0].Source.Pointer).Should(Equal("/data/attributes/make"))
+			Ω(r.Errors()[1].Source.Pointer).Should(Equal("/data/attributes/year"))
+
+			copied := r
+			Ω(ResourceErrors(&copied)[0].Source.Pointer).Should(Equal("/data/attributes/year"))
+			Ω(ResourceErrors(&copied)[1].Source.Pointer).Should(Equal("/data/attributes/make"))
+		})
+
+		It("should add all of a model's validation errors", func() {
+			m := AutomobileModel{}
+			m.Required("make", m.Make)
+			m.Max("year", 2020, 2016)
+			m.Min("year", 2020, 2030)
+
+			r := AutomobileResource{}
+			r.AddValidationErrors(m.Errors)
+			Ω(ResourceErrors(&r)).Should(HaveLen(3))
+			Ω(ResourceErrors(&r)[2].Source.Pointer).Should(Equal("/data/attributes/make"))
+		})
+
+		It("should describe the failed validator in the error's code and meta", func() {
+			r := AutomobileResource{}
+			r.AddValidatorError("year", validations.ValidMax(2016), "")
+			r.AddValidatorError("year", validations.ValidRange(1900, 2016), "must be between 1900 and 2016")
+			r.AddValidatorError("make", validations.ValidRequired(), "")
+			r.AddValidatorError("make", validations.ValidMatch(regexp.MustCompile("^[A-Z]")), "")
+			r.AddValidatorError("body-style", validations.ValidMaxSize(20), "")
+
+			Ω(ResourceErrors(&r)).Should(Equal([]JsonApiError{
+				{Status: "422", Code: "max", Detail: "Maximum is 2016", Meta: map[string]interface{}{"max": 2016},
+					Source: &JsonApiErrorSource{Pointer: "/data/attributes/year"}},
+				{Status: "422", Code: "range", Detail: "must be between 1900 and 2016", Meta: map[string]interface{}{"min": 1900, "max": 2016},
+					Source: &JsonApiErrorSource{Pointer: "/data/attributes/year"}},
+				{Status: "422", Code: "required", Detail: "Required",
+					Source: &JsonApiErrorSource{Pointer: "/data/attributes/make"}},
+				{Status: "422", Code: "match", Detail: "Must match ^[A-Z]", Meta: map[string]interface{}{"pattern": "^[A-Z]"},
+					Source: &JsonApiErrorSource{Pointer: "/data/attributes/make"}},
+				{Status: "422", Code: "max-size", Detail: "Maximum size is 20", Meta: map[string]interface{}{"max": 20},
+					Source: &JsonApiErrorSource{Pointer: "/data/attributes/body-style"}},
+			}))
+		})
+
+		It("should use the error's status when set", func() {
+			r := AutomobileResource{}
+			r.AddError(AttributeError{Key: "make", Message: "is taken", Status: "409"})
+			Ω(ResourceErrors(&r)[0].Status).Should(Equal("409"))
+		})
+	})
+
+	Context("Presence", func() {
+		It("should record the attributes and relationships present in the request", func() {
+			r := AutomobileResource{}
+			body := []byte(`{"data":{"type":"automobiles","id":"1","attributes":{"make":"Mazda","body-style":null},
+				"relationships":{"drivers":{"data":[{"type":"drivers","id":"1"}]}}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+
+			Ω(r.Has("make")).Should(BeTrue())
+			Ω(r.Has("body-style")).Should(BeTrue())
+			Ω(r.Has("drivers")).Should(BeTrue())
+			Ω(r.Has("year")).Should(BeFalse())
+			Ω(r.IsExplicitNull("body-style")).Should(BeTrue())
+			Ω(r.IsExplicitNull("make")).Should(BeFalse())
+			Ω(r.IsExplicitNull("drivers")).Should(BeFalse())
+			Ω(r.IsExplicitNull("year")).Should(BeFalse())
+			Ω(r.PresentKeys()).Should(Equal([]string{"body-style", "drivers", "make"}))
+		})
+
+		It("should treat a null to-one relationship as explicitly null", func() {
+			present := presentKeys(nil, map[string]interface{}{"owner": map[string]interface{}{"data": nil}})
+			Ω(present).Should(Equal(map[string]bool{"owner": true}))
+		})
+
+		It("should not record presence for resources that were not unmarshalled", func() {
+			r := AutomobileResource{Make: null.StringFrom("Mazda")}
+			Ω(r.Has("make")).Should(BeFalse())
+			Ω(r.PresentKeys()).Should(BeEmpty())
+		})
+
+		It("should clear a model field that is explicitly null", func() {
+			style := "coupe"
+			m := AutomobileModel{Make: "Honda", BodyStyle: &style}
+
+			r := AutomobileResource{}
+			body := []byte(`{"data":{"type":"automobiles","id":"1","attributes":{"make":"Mazda","body-style":null}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(r.MapToModel(&m)).Should(Succeed())
+			Ω(m.Make).Should(Equal("Mazda"))
+			Ω(m.BodyStyle).Should(BeNil())
 		})
 	})
 })
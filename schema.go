@@ -0,0 +1,264 @@
+package gsonapi
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats => string formats that can be validated, keyed by name
+var formats = map[string]func(s string) bool{
+	"date-time": func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil },
+	"date":      func(s string) bool { _, err := time.Parse("2006-01-02", s); return err == nil },
+	"email":     regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`).MatchString,
+	"uuid":      regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`).MatchString,
+	"uri":       func(s string) bool { u, err := url.Parse(s); return err == nil && u.Scheme != "" },
+}
+
+// SchemaRules => an attribute's validation rules, parsed from its schema struct tag
+// EX: `schema:"required,enum=sedan|coupe|hatchback"` or `schema:"format=email"`
+type SchemaRules struct {
+	Required bool     // must be present and not null when the resource is created
+	Enum     []string // allowed values
+	Format   string   // EX: date-time, date, email, uuid or uri
+}
+
+// GetSchemaRules => parses an attribute's schema struct tag
+func GetSchemaRules(field reflect.StructField) SchemaRules {
+	rules := SchemaRules{}
+	for _, rule := range strings.Split(field.Tag.Get("schema"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+		switch {
+		case kv[0] == "required":
+			rules.Required = true
+		case kv[0] == "enum" && len(kv) == 2:
+			rules.Enum = strings.Split(kv[1], "|")
+		case kv[0] == "format" && len(kv) == 2:
+			rules.Format = kv[1]
+		}
+	}
+	return rules
+}
+
+// AttributesSchema => the JSON Schema of a resource's attributes, derived from their go types and schema tags
+// NOTE: required attributes are only required when creating the resource
+func AttributesSchema(resource interface{}, creating bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	permissions := GetFieldPermissions(resource)
+
+	t := reflect.TypeOf(resource)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isAttribute(field) {
+			continue
+		}
+
+		name := attributeName(field)
+		rules := GetSchemaRules(field)
+		schema := jsonSchema(field.Type)
+
+		if len(rules.Enum) > 0 {
+			enum := []interface{}{}
+			for _, v := range rules.Enum {
+				enum = append(enum, v)
+			}
+			if allowsType(schema, "null") {
+				enum = append(enum, nil)
+			}
+			schema["enum"] = enum
+		}
+		if rules.Format != "" {
+			schema["format"] = rules.Format
+		}
+		if permissions[name].ReadOnly {
+			schema["readOnly"] = true
+		}
+		if permissions[name].WriteOnly {
+			schema["writeOnly"] = true
+		}
+		if rules.Required && creating {
+			required = append(required, name)
+		}
+
+		properties[name] = schema
+	}
+
+	return object(properties, required...)
+}
+
+// ValidateAttributes => validates a request's attributes against the resource's AttributesSchema
+// NOTE: returns a 400 error for each value of the wrong type and a 422 error for each
+// missing required attribute or value that is not in the enum or not of the format,
+// in attribute declaration order
+func ValidateAttributes(action Action, resource interface{}, attributes map[string]interface{}) []JsonApiError {
+	errors := []JsonApiError{}
+	schema := AttributesSchema(resource, action == ActionCreate)
+	properties, _ := schema["properties"].(map[string]interface{})
+	required, _ := schema["required"].([]string)
+
+	for _, name := range attributeNames(resource) {
+		value, ok := attributes[name]
+		if contains(required, name) && (!ok || value == nil) {
+			errors = append(errors, JsonApiError{Status: "422", Code: "required", Title: "Invalid Attribute",
+				Detail: name + " is required", Source: &JsonApiErrorSource{Pointer: attributePointer(name)}})
+			continue
+		}
+		if ok {
+			property, _ := properties[name].(map[string]interface{})
+			errors = append(errors, validateSchema(property, value, attributePointer(name))...)
+		}
+	}
+
+	return errors
+}
+
+// validateSchema => validates a decoded json value against the subset of JSON Schema produced by jsonSchema
+func validateSchema(schema map[string]interface{}, value interface{}, pointer string) []JsonApiError {
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		for _, s := range oneOf {
+			if errors := validateSchema(s.(map[string]interface{}), value, pointer); len(errors) == 0 {
+				return nil
+			}
+		}
+		return validateSchema(oneOf[0].(map[string]interface{}), value, pointer)
+	}
+
+	if _, ok := schema["type"]; ok && !allowsType(schema, jsonType(value)) {
+		return []JsonApiError{{Status: "400", Code: "type", Title: "Invalid Attribute",
+			Detail: "expected " + strings.Join(schemaTypes(schema), " or ") + ", not " + jsonType(value),
+			Source: &JsonApiErrorSource{Pointer: pointer}}}
+	}
+	if value == nil {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		values := []string{}
+		for _, v := range enum {
+			if v != nil {
+				values = append(values, queryString(v))
+			}
+		}
+		return []JsonApiError{{Status: "422", Code: "enum", Title: "Invalid Attribute",
+			Detail: "must be one of " + strings.Join(values, ", "), Meta: map[string]interface{}{"enum": values},
+			Source: &JsonApiErrorSource{Pointer: pointer}}}
+	}
+	if format, ok := schema["format"].(string); ok {
+		if s, isString := value.(string); isString && formats[format] != nil && !formats[format](s) {
+			return []JsonApiError{{Status: "422", Code: "format", Title: "Invalid Attribute",
+				Detail: "must be a valid " + format, Meta: map[string]interface{}{"format": format},
+				Source: &JsonApiErrorSource{Pointer: pointer}}}
+		}
+	}
+
+	errors := []JsonApiError{}
+	switch t := value.(type) {
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, v := range t {
+				errors = append(errors, validateSchema(items, v, pointer+"/"+strconv.Itoa(i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if property, ok := properties[k].(map[string]interface{}); ok {
+				errors = append(errors, validateSchema(property, t[k], pointer+"/"+pointerToken(k))...)
+			} else if additional != nil {
+				errors = append(errors, validateSchema(additional, t[k], pointer+"/"+pointerToken(k))...)
+			}
+		}
+	}
+	return errors
+}
+
+// jsonType => the JSON Schema type of a decoded json value
+func jsonType(value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if t == math.Trunc(t) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// schemaTypes => the types a schema allows
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return []string{}
+}
+
+// allowsType => true if the schema allows the JSON Schema type, where number includes integer
+func allowsType(schema map[string]interface{}, t string) bool {
+	if _, ok := schema["type"]; !ok {
+		return true
+	}
+	for _, allowed := range schemaTypes(schema) {
+		if allowed == t || (allowed == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// pointerToken => a json pointer reference token, EX: a/b => a~1b
+func pointerToken(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
+
+// unescapePointerToken => the reference token of a json pointer segment, see pointerToken
+func unescapePointerToken(s string) string {
+	return strings.Replace(strings.Replace(s, "~1", "/", -1), "~0", "~", -1)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
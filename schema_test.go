@@ -0,0 +1,130 @@
+package gsonapi
+
+import (
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Truck Resource (w/ schema rules)
+type TruckResource struct {
+	Resource  `jsonapi:"-"`
+	Year      null.Int      `json:"year,omitempty" jsonapi:"name=year" schema:"required"`
+	Make      null.String   `json:"make,omitempty" jsonapi:"name=make" schema:"required"`
+	BodyStyle null.String   `json:"body-style,omitempty" jsonapi:"name=body-style" schema:"enum=pickup|flatbed"`
+	Email     string        `json:"email,omitempty" jsonapi:"name=email" schema:"format=email"`
+	SoldAt    *string       `json:"sold-at,omitempty" jsonapi:"name=sold-at" schema:"format=date-time"`
+	Ages      []int         `json:"ages,omitempty" jsonapi:"name=ages"`
+	Specs     TruckSpecs    `json:"specs,omitempty" jsonapi:"name=specs"`
+	Notes     []interface{} `json:"notes,omitempty" jsonapi:"name=notes"`
+}
+
+type TruckSpecs struct {
+	Axles   int     `json:"axles"`
+	Payload float64 `json:"payload"`
+}
+
+func (r TruckResource) GetName() string {
+	return "trucks"
+}
+
+func (r *TruckResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *TruckResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+var _ = Describe("Schema", func() {
+	pointers := func(errors []JsonApiError) []string {
+		p := []string{}
+		for _, e := range errors {
+			p = append(p, e.Source.Pointer)
+		}
+		return p
+	}
+
+	Context("Rules", func() {
+		It("should parse the schema tag", func() {
+			field, _ := reflect.TypeOf(TruckResource{}).FieldByName("BodyStyle")
+			Ω(GetSchemaRules(field)).Should(Equal(SchemaRules{Enum: []string{"pickup", "flatbed"}}))
+
+			field, _ = reflect.TypeOf(TruckResource{}).FieldByName("Year")
+			Ω(GetSchemaRules(field)).Should(Equal(SchemaRules{Required: true}))
+		})
+	})
+
+	Context("AttributesSchema", func() {
+		It("should derive the schema from the attributes' types and rules", func() {
+			schema := AttributesSchema(TruckResource{}, true)
+			properties := schema["properties"].(map[string]interface{})
+
+			Ω(schema["required"]).Should(Equal([]string{"year", "make"}))
+			Ω(properties["year"]).Should(Equal(map[string]interface{}{"type": []string{"integer", "null"}}))
+			Ω(properties["body-style"]).Should(Equal(map[string]interface{}{"type": []string{"string", "null"},
+				"enum": []interface{}{"pickup", "flatbed", nil}}))
+			Ω(properties["email"]).Should(Equal(map[string]interface{}{"type": "string", "format": "email"}))
+			Ω(properties["ages"]).Should(Equal(map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}}))
+		})
+
+		It("should only require attributes when creating", func() {
+			Ω(AttributesSchema(TruckResource{}, false)).ShouldNot(HaveKey("required"))
+		})
+	})
+
+	Context("ValidateAttributes", func() {
+		It("should accept valid attributes", func() {
+			errors := ValidateAttributes(ActionCreate, TruckResource{}, map[string]interface{}{
+				"year": 2015.0, "make": "Ford", "body-style": "pickup", "email": "a@b.com", "sold-at": "2015-01-02T15:04:05Z",
+				"ages": []interface{}{18.0}, "specs": map[string]interface{}{"axles": 2.0, "payload": 1.5}, "notes": []interface{}{"a", 1.0}})
+			Ω(errors).Should(BeEmpty())
+		})
+
+		It("should return 400 errors for values of the wrong type", func() {
+			errors := ValidateAttributes(ActionUpdate, TruckResource{}, map[string]interface{}{
+				"year": "2015", "ages": []interface{}{18.0, "nineteen"}, "specs": map[string]interface{}{"axles": 2.5}})
+
+			Ω(pointers(errors)).Should(Equal([]string{"/data/attributes/year", "/data/attributes/ages/1", "/data/attributes/specs/axles"}))
+			Ω(errors[0]).Should(Equal(JsonApiError{Status: "400", Code: "type", Title: "Invalid Attribute",
+				Detail: "expected integer or null, not string", Source: &JsonApiErrorSource{Pointer: "/data/attributes/year"}}))
+		})
+
+		It("should return 422 errors for missing required attributes on create", func() {
+			errors := ValidateAttributes(ActionCreate, TruckResource{}, map[string]interface{}{"make": nil})
+			Ω(pointers(errors)).Should(Equal([]string{"/data/attributes/year", "/data/attributes/make"}))
+			Ω(errors[0].Status).Should(Equal("422"))
+			Ω(errors[0].Code).Should(Equal("required"))
+
+			Ω(ValidateAttributes(ActionUpdate, TruckResource{}, map[string]interface{}{})).Should(BeEmpty())
+		})
+
+		It("should return 422 errors for values that are not in the enum or of the format", func() {
+			errors := ValidateAttributes(ActionUpdate, TruckResource{}, map[string]interface{}{
+				"body-style": "sedan", "email": "bob", "sold-at": "yesterday"})
+
+			Ω(pointers(errors)).Should(Equal([]string{"/data/attributes/body-style", "/data/attributes/email", "/data/attributes/sold-at"}))
+			Ω(errors[0].Code).Should(Equal("enum"))
+			Ω(errors[0].Detail).Should(Equal("must be one of pickup, flatbed"))
+			Ω(errors[1].Code).Should(Equal("format"))
+			Ω(errors[1].Status).Should(Equal("422"))
+		})
+
+		It("should allow null for nullable attributes", func() {
+			errors := ValidateAttributes(ActionUpdate, TruckResource{}, map[string]interface{}{"body-style": nil, "sold-at": nil})
+			Ω(errors).Should(BeEmpty())
+		})
+	})
+
+	Context("UnmarshalRequest", func() {
+		It("should validate the attributes before unmarshalling them", func() {
+			body := []byte(`{"data":{"type":"trucks","attributes":{"year":"2015","make":"Ford"}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &TruckResource{})
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("400"))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/attributes/year"))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package gsonapi
+
+import (
+	"time"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+const (
+	// ActionRestore => brings back a soft deleted record, see RestoreResource
+	ActionRestore Action = "restore"
+	// ActionListDeleted => lists soft deleted records, i.e., filter[deleted]=true, see ScopeQuery
+	ActionListDeleted Action = "list-deleted"
+)
+
+// SoftDeleter => optional interface of resources (and models) whose records are marked deleted instead of removed
+// EX: DeletedAt *time.Time `json:"deleted-at,omitempty" jsonapi:"name=deleted-at;readonly"`
+type SoftDeleter interface {
+	GetDeletedAt() *time.Time
+	SetDeletedAt(deletedAt *time.Time)
+}
+
+// GoneReporter => optional interface of soft deletable resources whose deleted records are 410 Gone instead of 404
+type GoneReporter interface {
+	ReportGone() bool
+}
+
+// IsDeleted => true if the record is soft deleted
+// NOTE: only needs GetDeletedAt, so that records (rather than pointers to them) of a SoftDeleter are checked too
+func IsDeleted(record interface{}) bool {
+	deleter, ok := record.(interface {
+		GetDeletedAt() *time.Time
+	})
+	return ok && deleter.GetDeletedAt() != nil
+}
+
+// SoftDelete => marks the resource deleted and returns true, or returns false if the resource cannot be soft deleted
+// NOTE: the app still saves the resource (or its model), EX:
+// DeleteWithHooks(jasi, &r, &m, func() *JsonApiError { SoftDelete(&r); return save(r) }) => HandleDeleteResponse
+func SoftDelete(resource interface{}) bool {
+	deleter, ok := resource.(SoftDeleter)
+	if !ok {
+		return false
+	}
+	if deleter.GetDeletedAt() == nil {
+		now := time.Now().UTC()
+		deleter.SetDeletedAt(&now)
+	}
+	return true
+}
+
+// RestoreResource => returns a 403 or 404 error if the caller may not restore the record, otherwise
+// clears its deleted-at so that the app can save it, see AuthorizeResource
+func RestoreResource(jasi JSONApiServerInfo, record jsonapi.MarshalIdentifier) *JsonApiError {
+	deleter, ok := record.(SoftDeleter)
+	if !ok {
+		return NewError(400).Detail(resourceType(record) + " cannot be restored").Build()
+	}
+	if err := AuthorizeResource(jasi, ActionRestore, record); err != nil {
+		return err
+	}
+	deleter.SetDeletedAt(nil)
+	return nil
+}
+
+// authorizeDeleted => returns a 404 error, or 410 if the resource opts into it, if the record is soft deleted
+func authorizeDeleted(action Action, record interface{}) *JsonApiError {
+	if action == ActionRestore || !IsDeleted(record) {
+		return nil
+	}
+	if reporter, ok := record.(GoneReporter); ok && reporter.ReportGone() {
+		return NewError(410).Detail(resourceType(record) + " was deleted").Build()
+	}
+	return notFoundError(resourceType(record))
+}
@@ -0,0 +1,205 @@
+package gsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Cabin Resource (soft deletable)
+type CabinResource struct {
+	Resource  `jsonapi:"-"`
+	Name      string     `json:"name,omitempty" jsonapi:"name=name"`
+	DeletedAt *time.Time `json:"deleted-at,omitempty" jsonapi:"name=deleted-at;readonly"`
+}
+
+func (r CabinResource) GetName() string {
+	return "cabins"
+}
+
+func (r *CabinResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *CabinResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+func (r CabinResource) GetDeletedAt() *time.Time {
+	return r.DeletedAt
+}
+
+func (r *CabinResource) SetDeletedAt(deletedAt *time.Time) {
+	r.DeletedAt = deletedAt
+}
+
+// Tent Resource (soft deletable and reported as gone once deleted)
+type TentResource struct {
+	CabinResource
+}
+
+func (r TentResource) GetName() string {
+	return "tents"
+}
+
+func (r TentResource) ReportGone() bool {
+	return true
+}
+
+type denyDeletedPolicy struct {
+	AllowAll
+}
+
+func (denyDeletedPolicy) Authorize(caller interface{}, action Action, resourceType string) bool {
+	return action != ActionListDeleted && action != ActionRestore
+}
+
+var _ = Describe("SoftDelete", func() {
+	AfterEach(func() {
+		delete(policies, "cabins")
+	})
+
+	It("should mark a soft deletable resource deleted", func() {
+		r := CabinResource{Name: "Pine"}
+		Ω(IsDeleted(&r)).Should(BeFalse())
+		Ω(SoftDelete(&r)).Should(BeTrue())
+		Ω(r.DeletedAt).ShouldNot(BeNil())
+		Ω(IsDeleted(&r)).Should(BeTrue())
+
+		Ω(SoftDelete(&DriverResource{})).Should(BeFalse())
+	})
+
+	It("should keep the original deleted-at of a resource that is deleted twice", func() {
+		deletedAt := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+		r := CabinResource{DeletedAt: &deletedAt}
+		Ω(SoftDelete(&r)).Should(BeTrue())
+		Ω(*r.DeletedAt).Should(Equal(deletedAt))
+	})
+
+	It("should return 404 for a deleted record, or 410 if the resource opts into it", func() {
+		cabin := CabinResource{}
+		cabin.SetID("c1")
+		Ω(AuthorizeResource(TEST_SERVER_INFO, ActionRead, &cabin)).Should(BeNil())
+
+		SoftDelete(&cabin)
+		err := AuthorizeResource(TEST_SERVER_INFO, ActionUpdate, &cabin)
+		Ω(err.Status).Should(Equal("404"))
+		Ω(err.Detail).Should(Equal("cabins not found"))
+
+		tent := TentResource{}
+		tent.SetID("t1")
+		SoftDelete(&tent)
+		err = AuthorizeResource(TEST_SERVER_INFO, ActionRead, &tent)
+		Ω(err.Status).Should(Equal("410"))
+		Ω(err.Title).Should(Equal("Gone"))
+	})
+
+	It("should render a 410 for a deleted record", func() {
+		tent := TentResource{}
+		tent.SetID("t1")
+		SoftDelete(&tent)
+
+		server := martini.Classic()
+		server.Use(render.Renderer())
+		server.Get("/v1/tents/:id", func(r render.Render) {
+			HandleGetResponse(TEST_SERVER_INFO, nil, &tent, r)
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/v1/tents/t1", nil)
+		server.ServeHTTP(recorder, request)
+
+		Ω(recorder.Code).Should(Equal(410))
+		Ω(recorder.Body.String()).Should(MatchJSON(`{"errors":[{"status":"410","title":"Gone","detail":"tents was deleted"}]}`))
+	})
+
+	It("should restore a deleted record", func() {
+		cabin := CabinResource{}
+		cabin.SetID("c1")
+		SoftDelete(&cabin)
+
+		Ω(RestoreResource(TEST_SERVER_INFO, &cabin)).Should(BeNil())
+		Ω(cabin.DeletedAt).Should(BeNil())
+		Ω(AuthorizeResource(TEST_SERVER_INFO, ActionRead, &cabin)).Should(BeNil())
+
+		Ω(RestoreResource(TEST_SERVER_INFO, &DriverResource{}).Status).Should(Equal("400"))
+	})
+
+	It("should not restore a record the caller may not restore", func() {
+		RegisterPolicy("cabins", denyDeletedPolicy{})
+		cabin := CabinResource{}
+		SoftDelete(&cabin)
+
+		Ω(RestoreResource(TEST_SERVER_INFO, &cabin).Status).Should(Equal("403"))
+		Ω(cabin.DeletedAt).ShouldNot(BeNil())
+	})
+
+	It("should exclude deleted records from a query unless filter[deleted]=true", func() {
+		deleted := CabinResource{}
+		SoftDelete(&deleted)
+
+		q, _ := ParseQuery(url.Values{})
+		Ω(q.Includes(&deleted)).Should(BeFalse())
+		Ω(q.Includes(&CabinResource{})).Should(BeTrue())
+
+		q, _ = ParseQuery(url.Values{"filter[deleted]": {"true"}})
+		Ω(q.Deleted).Should(BeTrue())
+		Ω(q.Filters).Should(BeEmpty())
+		Ω(q.Includes(&deleted)).Should(BeTrue())
+		Ω(ScopeQuery(TEST_SERVER_INFO, "cabins", q)).Should(BeNil())
+	})
+
+	It("should not render deleted records in an index unless they are listed and the policy allows it", func() {
+		server := martini.Classic()
+		server.Use(render.Renderer())
+		var jasi JSONApiServerInfo
+		server.Get("/v1/cabins", func(r render.Render) {
+			deleted := CabinResource{Name: "Pine"}
+			deleted.SetID("2")
+			SoftDelete(&deleted)
+			cabin := CabinResource{Name: "Oak"}
+			cabin.SetID("1")
+			HandleIndexResponse(jasi, nil, []CabinResource{cabin, deleted}, r)
+		})
+		ids := func() []string {
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest("GET", "/v1/cabins", nil)
+			server.ServeHTTP(recorder, request)
+			Ω(recorder.Code).Should(Equal(200))
+			var doc interface{}
+			Ω(json.Unmarshal(recorder.Body.Bytes(), &doc)).Should(Succeed())
+			ids := []string{}
+			for _, e := range responseEntries(doc) {
+				ids = append(ids, e["id"].(string))
+			}
+			return ids
+		}
+
+		jasi = TEST_SERVER_INFO
+		Ω(ids()).Should(Equal([]string{"1"}))
+
+		jasi.ListDeleted = true
+		Ω(ids()).Should(Equal([]string{"1", "2"}))
+
+		RegisterPolicy("cabins", denyDeletedPolicy{})
+		Ω(ids()).Should(Equal([]string{"1"}))
+	})
+
+	It("should not list deleted records unless the policy allows it", func() {
+		RegisterPolicy("cabins", denyDeletedPolicy{})
+
+		q, _ := ParseQuery(url.Values{"filter[deleted]": {"true"}})
+		err := ScopeQuery(TEST_SERVER_INFO, "cabins", q)
+		Ω(err.Status).Should(Equal("403"))
+		Ω(err.Detail).Should(Equal("not authorized to list-deleted cabins"))
+
+		q, _ = ParseQuery(url.Values{})
+		Ω(ScopeQuery(TEST_SERVER_INFO, "cabins", q)).Should(BeNil())
+	})
+})
@@ -0,0 +1,713 @@
+package gsonapi
+
+import (
+	"database/sql"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+// Dialect => the SQL flavor of a SQLRepository's database
+type Dialect interface {
+	// Placeholder => the placeholder of the position-th (1-based) argument of a statement
+	Placeholder(position int) string
+	// Quote => a quoted table or column name
+	Quote(identifier string) string
+}
+
+// Postgres => EX: SELECT "id" FROM "automobiles" WHERE "make" IN ($1, $2)
+var Postgres Dialect = postgresDialect{}
+
+// SQLite => EX: SELECT "id" FROM "automobiles" WHERE "make" IN (?, ?)
+var SQLite Dialect = sqliteDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(position int) string { return "$" + strconv.Itoa(position) }
+func (postgresDialect) Quote(identifier string) string  { return quoteIdentifier(identifier) }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(position int) string { return "?" }
+func (sqliteDialect) Quote(identifier string) string  { return quoteIdentifier(identifier) }
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+// JoinTable => the table that links a SQLRepository's records to the related records of a relationship
+// EX: JoinTable{Table: "automobiles_drivers", Column: "automobile_id", RelatedColumn: "driver_id"}
+type JoinTable struct {
+	Table         string
+	Column        string // references the id of the repository's records
+	RelatedColumn string // references the id of the related records
+}
+
+// SQLRepository => a Repository backed by a database/sql table
+// NOTE: records are mapped to and from models (see MapToModel and MapFromModel) whose fields are the table's columns,
+// EX: Make string `db:"make"`, where untagged fields are snake_cased and `db:"-"` fields are skipped;
+// the column of the model's ID field, EX: ID string `db:"dealer_id"`, is the primary key and a deleted_at column soft
+// deletes the records of a SoftDeleter
+type SQLRepository struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	Table        string
+	TenantColumn string               // optional, restricts FindAll to the query's tenant
+	JoinTables   map[string]JoinTable // keyed by relationship name, EX: drivers
+
+	resource reflect.Type
+	model    reflect.Type
+	columns  []sqlColumn
+	key      sqlColumn
+}
+
+// sqlColumn => a column and the model field it is scanned into
+type sqlColumn struct {
+	name  string
+	index []int
+	t     reflect.Type
+}
+
+// NewSQLRepository => a repository of the resource's records, stored in the table as the model's fields
+// EX: NewSQLRepository(db, SQLite, "automobiles", AutomobileResource{}, AutomobileModel{})
+func NewSQLRepository(db *sql.DB, dialect Dialect, table string, resource interface{}, model interface{}) *SQLRepository {
+	m := &SQLRepository{DB: db, Dialect: dialect, Table: table, JoinTables: map[string]JoinTable{},
+		resource: reflect.Indirect(reflect.ValueOf(resource)).Type(),
+		model:    reflect.Indirect(reflect.ValueOf(model)).Type()}
+
+	for i := 0; i < m.model.NumField(); i++ {
+		field := m.model.Field(i)
+		tag := field.Tag.Get("db")
+		if tag == "-" || field.PkgPath != "" || (tag == "" && (field.Anonymous || !isColumnType(field.Type))) {
+			continue
+		}
+		if tag == "" {
+			tag = underscore(field.Name)
+		}
+		column := sqlColumn{name: tag, index: field.Index, t: field.Type}
+		m.columns = append(m.columns, column)
+
+		if field.Name == "ID" {
+			m.key = column
+		}
+	}
+	if m.key.name == "" {
+		m.key.name = "id"
+	}
+
+	return m
+}
+
+// isColumnType => true if values of the type can be stored in a column, EX: strings, numbers, times and []byte
+func isColumnType(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(scannerType) {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map, reflect.Array, reflect.Interface, reflect.Func, reflect.Chan:
+		return false
+	}
+	return true
+}
+
+// FindAll => see Repository
+// NOTE: returns a 400 error for a filter or sort attribute that is not a column
+func (m *SQLRepository) FindAll(q *Query) (interface{}, int, *JsonApiError) {
+	if q == nil {
+		q = &Query{}
+	}
+	query, args, count, countArgs, err := m.findAllSQL(q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := 0
+	if e := m.DB.QueryRow(count, countArgs...).Scan(&total); e != nil {
+		return nil, 0, sqlError(e)
+	}
+	records, err := m.query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return records.Interface(), total, nil
+}
+
+// FindOne => see Repository
+func (m *SQLRepository) FindOne(id string) (interface{}, *JsonApiError) {
+	records, err := m.query(m.selectSQL()+" WHERE "+m.Dialect.Quote(m.key.name)+" = "+m.Dialect.Placeholder(1), id)
+	if err != nil {
+		return nil, err
+	}
+	if records.Len() == 0 {
+		return nil, m.notFound()
+	}
+	return records.Index(0).Addr().Interface(), nil
+}
+
+// FindMany => see Repository
+func (m *SQLRepository) FindMany(ids []string) (interface{}, *JsonApiError) {
+	found := reflect.MakeSlice(reflect.SliceOf(m.resource), 0, len(ids))
+	if len(ids) == 0 {
+		return found.Interface(), nil
+	}
+
+	b := &sqlBuilder{dialect: m.Dialect}
+	records, err := m.query(m.selectSQL()+" WHERE "+m.Dialect.Quote(m.key.name)+" IN ("+b.list(stringsToInterfaces(ids))+")", b.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]reflect.Value{}
+	for i := 0; i < records.Len(); i++ {
+		byID[records.Index(i).Addr().Interface().(jsonapi.MarshalIdentifier).GetID()] = records.Index(i)
+	}
+	for _, id := range ids {
+		if record, ok := byID[id]; ok {
+			found = reflect.Append(found, record)
+		}
+	}
+	return found.Interface(), nil
+}
+
+// Create => see Repository
+// NOTE: a record w/o an id is assigned the one generated by the database, see INSERT ... RETURNING
+func (m *SQLRepository) Create(record Resourcer) *JsonApiError {
+	model, err := m.toModel(record)
+	if err != nil {
+		return err
+	}
+
+	return m.transaction(func(tx *sql.Tx) *JsonApiError {
+		id := record.GetID()
+		if id != "" {
+			if exists, err := m.exists(tx, id); err != nil {
+				return err
+			} else if exists {
+				return NewError(409).Detail(resourceType(record) + " " + id + " already exists").Build()
+			}
+		}
+
+		statement, args := m.insertSQL(model, id == "")
+		if id == "" {
+			if e := tx.QueryRow(statement, args...).Scan(&id); e != nil {
+				return sqlError(e)
+			}
+			if e := record.SetID(id); e != nil {
+				return NewError(500).Detail(e.Error()).Build()
+			}
+		} else if _, e := tx.Exec(statement, args...); e != nil {
+			return sqlError(e)
+		}
+
+		return m.saveRelationships(tx, record)
+	})
+}
+
+// Update => see Repository
+func (m *SQLRepository) Update(record Resourcer) *JsonApiError {
+	model, err := m.toModel(record)
+	if err != nil {
+		return err
+	}
+
+	return m.transaction(func(tx *sql.Tx) *JsonApiError {
+		statement, args := m.updateSQL(model, record.GetID(), presentKeysOf(record))
+		if statement == "" {
+			if exists, err := m.exists(tx, record.GetID()); err != nil {
+				return err
+			} else if !exists {
+				return m.notFound()
+			}
+			return m.saveRelationships(tx, record)
+		}
+
+		result, e := tx.Exec(statement, args...)
+		if e != nil {
+			return sqlError(e)
+		}
+		if n, e := result.RowsAffected(); e == nil && n == 0 {
+			return m.notFound()
+		}
+		return m.saveRelationships(tx, record)
+	})
+}
+
+// Delete => see Repository
+// NOTE: also deletes the rows of the join tables that reference the record
+func (m *SQLRepository) Delete(id string) *JsonApiError {
+	return m.transaction(func(tx *sql.Tx) *JsonApiError {
+		for _, name := range m.joinTableNames() {
+			if _, e := tx.Exec(m.deleteJoinSQL(m.JoinTables[name]), id); e != nil {
+				return sqlError(e)
+			}
+		}
+
+		statement := "DELETE FROM " + m.Dialect.Quote(m.Table) + " WHERE " + m.Dialect.Quote(m.key.name) + " = " + m.Dialect.Placeholder(1)
+		result, e := tx.Exec(statement, id)
+		if e != nil {
+			return sqlError(e)
+		}
+		if n, e := result.RowsAffected(); e == nil && n == 0 {
+			return m.notFound()
+		}
+		return nil
+	})
+}
+
+// findAllSQL => the SELECT and COUNT statements of a query and their arguments
+// EX: SELECT ... FROM "drivers" WHERE "active" IN ($1) ORDER BY "age" DESC, "id" LIMIT $2 OFFSET $3
+func (m *SQLRepository) findAllSQL(q *Query) (string, []interface{}, string, []interface{}, *JsonApiError) {
+	b := &sqlBuilder{dialect: m.Dialect}
+	attributes := m.attributeColumns()
+	conditions := []string{}
+
+	for _, f := range q.Filters {
+		condition, err := b.filter(attributes, f)
+		if err != nil {
+			return "", nil, "", nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if column, ok := m.column("deleted_at"); ok && !q.Deleted && reflect.PtrTo(m.resource).Implements(softDeleterType) {
+		conditions = append(conditions, m.Dialect.Quote(column.name)+" IS NULL")
+	}
+	if m.TenantColumn != "" && q.Tenant != "" {
+		conditions = append(conditions, m.Dialect.Quote(m.TenantColumn)+" = "+b.arg(q.Tenant))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	count := "SELECT COUNT(*) FROM " + m.Dialect.Quote(m.Table) + where
+	countArgs := append([]interface{}{}, b.args...)
+
+	// NOTE: the id is the last sort column so that the order is deterministic
+	order := []string{}
+	for _, s := range q.Sort {
+		column, ok := attributes[s.Attribute]
+		if !ok {
+			return "", nil, "", nil, NewError(400).Title("Invalid Query Parameter").
+				Detail("cannot sort by " + s.Attribute).Parameter("sort").Build()
+		}
+		if s.Descending {
+			order = append(order, m.Dialect.Quote(column.name)+" DESC")
+		} else {
+			order = append(order, m.Dialect.Quote(column.name))
+		}
+	}
+	order = append(order, m.Dialect.Quote(m.key.name))
+
+	query := m.selectSQL() + where + " ORDER BY " + strings.Join(order, ", ")
+	if q.Page.Size > 0 {
+		number := q.Page.Number
+		if number < 1 {
+			number = 1
+		}
+		query += " LIMIT " + b.arg(q.Page.Size) + " OFFSET " + b.arg((number-1)*q.Page.Size)
+	}
+
+	return query, b.args, count, countArgs, nil
+}
+
+// selectSQL => EX: SELECT "id", "name" FROM "drivers"
+func (m *SQLRepository) selectSQL() string {
+	columns := make([]string, len(m.columns))
+	for i, c := range m.columns {
+		columns[i] = m.Dialect.Quote(c.name)
+	}
+	return "SELECT " + strings.Join(columns, ", ") + " FROM " + m.Dialect.Quote(m.Table)
+}
+
+// insertSQL => EX: INSERT INTO "drivers" ("name") VALUES ($1) RETURNING "id"
+func (m *SQLRepository) insertSQL(model reflect.Value, returning bool) (string, []interface{}) {
+	b := &sqlBuilder{dialect: m.Dialect}
+	columns, values := []string{}, []string{}
+	for _, c := range m.columns {
+		if c.name == m.key.name && returning {
+			continue
+		}
+		columns = append(columns, m.Dialect.Quote(c.name))
+		values = append(values, b.arg(model.FieldByIndex(c.index).Interface()))
+	}
+
+	statement := "INSERT INTO " + m.Dialect.Quote(m.Table) + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(values, ", ") + ")"
+	if returning {
+		statement += " RETURNING " + m.Dialect.Quote(m.key.name)
+	}
+	return statement, b.args
+}
+
+// updateSQL => EX: UPDATE "drivers" SET "name" = $1 WHERE "id" = $2
+// NOTE: only the columns of the attributes present in the request document are set if presence was recorded (see
+// saveRelationships), and "" if no column is set
+func (m *SQLRepository) updateSQL(model reflect.Value, id string, present map[string]bool) (string, []interface{}) {
+	set := map[string]bool{}
+	for name, c := range m.attributeColumns() {
+		if _, ok := present[name]; ok || present == nil {
+			set[c.name] = true
+		}
+	}
+
+	b := &sqlBuilder{dialect: m.Dialect}
+	assignments := []string{}
+	for _, c := range m.columns {
+		if c.name != m.key.name && (present == nil || set[c.name]) {
+			assignments = append(assignments, m.Dialect.Quote(c.name)+" = "+b.arg(model.FieldByIndex(c.index).Interface()))
+		}
+	}
+	if len(assignments) == 0 {
+		return "", nil
+	}
+	return "UPDATE " + m.Dialect.Quote(m.Table) + " SET " + strings.Join(assignments, ", ") +
+		" WHERE " + m.Dialect.Quote(m.key.name) + " = " + b.arg(id), b.args
+}
+
+// deleteJoinSQL => EX: DELETE FROM "automobiles_drivers" WHERE "automobile_id" = $1
+func (m *SQLRepository) deleteJoinSQL(join JoinTable) string {
+	return "DELETE FROM " + m.Dialect.Quote(join.Table) + " WHERE " + m.Dialect.Quote(join.Column) + " = " + m.Dialect.Placeholder(1)
+}
+
+// query => the records of the rows a SELECT statement returns, w/ their relationships loaded from the join tables
+func (m *SQLRepository) query(statement string, args ...interface{}) (reflect.Value, *JsonApiError) {
+	rows, e := m.DB.Query(statement, args...)
+	if e != nil {
+		return reflect.Value{}, sqlError(e)
+	}
+	defer rows.Close()
+
+	records := reflect.MakeSlice(reflect.SliceOf(m.resource), 0, 0)
+	for rows.Next() {
+		model := reflect.New(m.model)
+		destinations := make([]interface{}, len(m.columns))
+		for i, c := range m.columns {
+			destinations[i] = model.Elem().FieldByIndex(c.index).Addr().Interface()
+		}
+		if e := rows.Scan(destinations...); e != nil {
+			return reflect.Value{}, sqlError(e)
+		}
+
+		record := reflect.New(m.resource)
+		if e := record.Interface().(Resourcer).MapFromModel(model.Interface()); e != nil {
+			return reflect.Value{}, sqlError(e)
+		}
+		records = reflect.Append(records, record.Elem())
+	}
+	if e := rows.Err(); e != nil {
+		return reflect.Value{}, sqlError(e)
+	}
+
+	if err := m.loadRelationships(records); err != nil {
+		return reflect.Value{}, err
+	}
+	return records, nil
+}
+
+// loadRelationships => sets the related ids of the records' relationships that have a join table
+func (m *SQLRepository) loadRelationships(records reflect.Value) *JsonApiError {
+	if records.Len() == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, records.Len())
+	for i := 0; i < records.Len(); i++ {
+		ids[i] = records.Index(i).Addr().Interface().(jsonapi.MarshalIdentifier).GetID()
+	}
+
+	for _, name := range m.joinTableNames() {
+		join := m.JoinTables[name]
+		b := &sqlBuilder{dialect: m.Dialect}
+		statement := "SELECT " + m.Dialect.Quote(join.Column) + ", " + m.Dialect.Quote(join.RelatedColumn) +
+			" FROM " + m.Dialect.Quote(join.Table) + " WHERE " + m.Dialect.Quote(join.Column) + " IN (" + b.list(ids) + ")" +
+			" ORDER BY " + m.Dialect.Quote(join.Column) + ", " + m.Dialect.Quote(join.RelatedColumn)
+
+		rows, e := m.DB.Query(statement, b.args...)
+		if e != nil {
+			return sqlError(e)
+		}
+		related := map[string][]string{}
+		for rows.Next() {
+			var id, relatedID string
+			if e := rows.Scan(&id, &relatedID); e != nil {
+				rows.Close()
+				return sqlError(e)
+			}
+			related[id] = append(related[id], relatedID)
+		}
+		rows.Close()
+
+		for i := 0; i < records.Len(); i++ {
+			record := records.Index(i).Addr().Interface()
+			relatedIDs := related[ids[i].(string)]
+			var e error
+			if isToMany(record, name) {
+				e = SetToManyReferenceIDs(record, name, relatedIDs)
+			} else if len(relatedIDs) > 0 {
+				e = SetToOneReferenceID(record, name, relatedIDs[0])
+			}
+			if e != nil {
+				return sqlError(e)
+			}
+		}
+	}
+	return nil
+}
+
+// saveRelationships => replaces the rows of the join tables w/ the record's related ids
+// NOTE: relationships absent from the request document of an unmarshalled record are left unchanged
+func (m *SQLRepository) saveRelationships(tx *sql.Tx, record Resourcer) *JsonApiError {
+	present := presentKeysOf(record)
+	references := GetReferencedIDs(record)
+
+	for _, name := range m.joinTableNames() {
+		if _, ok := present[name]; present != nil && !ok {
+			continue
+		}
+
+		join := m.JoinTables[name]
+		if _, e := tx.Exec(m.deleteJoinSQL(join), record.GetID()); e != nil {
+			return sqlError(e)
+		}
+		for _, reference := range references {
+			if reference.Name != name {
+				continue
+			}
+			statement := "INSERT INTO " + m.Dialect.Quote(join.Table) + " (" + m.Dialect.Quote(join.Column) + ", " +
+				m.Dialect.Quote(join.RelatedColumn) + ") VALUES (" + m.Dialect.Placeholder(1) + ", " + m.Dialect.Placeholder(2) + ")"
+			if _, e := tx.Exec(statement, record.GetID(), reference.ID); e != nil {
+				return sqlError(e)
+			}
+		}
+	}
+	return nil
+}
+
+// exists => true if the table has a row w/ the id
+func (m *SQLRepository) exists(tx *sql.Tx, id string) (bool, *JsonApiError) {
+	count := 0
+	statement := "SELECT COUNT(*) FROM " + m.Dialect.Quote(m.Table) + " WHERE " + m.Dialect.Quote(m.key.name) + " = " + m.Dialect.Placeholder(1)
+	if e := tx.QueryRow(statement, id).Scan(&count); e != nil {
+		return false, sqlError(e)
+	}
+	return count > 0, nil
+}
+
+// transaction => runs fn in a transaction, which is rolled back if fn returns an error
+func (m *SQLRepository) transaction(fn func(tx *sql.Tx) *JsonApiError) *JsonApiError {
+	tx, e := m.DB.Begin()
+	if e != nil {
+		return sqlError(e)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if e := tx.Commit(); e != nil {
+		return sqlError(e)
+	}
+	return nil
+}
+
+// toModel => a new model mapped from the record, see MapToModel
+func (m *SQLRepository) toModel(record Resourcer) (reflect.Value, *JsonApiError) {
+	if reflect.Indirect(reflect.ValueOf(record)).Type() != m.resource {
+		return reflect.Value{}, NewError(500).Detail("expected a " + m.resource.String()).Build()
+	}
+	model := reflect.New(m.model)
+	if e := record.MapToModel(model.Interface()); e != nil {
+		return reflect.Value{}, NewError(400).Detail(e.Error()).Build()
+	}
+	return model.Elem(), nil
+}
+
+// attributeColumns => the columns of the resource's attributes keyed by attribute name, see modelFieldName
+func (m *SQLRepository) attributeColumns() map[string]sqlColumn {
+	columns := map[string]sqlColumn{}
+	for i := 0; i < m.resource.NumField(); i++ {
+		field := m.resource.Field(i)
+		name, ok := modelFieldName(field)
+		if !isAttribute(field) || !ok {
+			continue
+		}
+		for _, c := range m.columns {
+			if m.model.FieldByIndex(c.index).Name == name {
+				columns[attributeName(field)] = c
+			}
+		}
+	}
+	if m.key.index != nil {
+		columns["id"] = m.key
+	}
+	return columns
+}
+
+func (m *SQLRepository) column(name string) (sqlColumn, bool) {
+	for _, c := range m.columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return sqlColumn{}, false
+}
+
+func (m *SQLRepository) joinTableNames() []string {
+	names := make([]string, 0, len(m.JoinTables))
+	for name := range m.JoinTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *SQLRepository) notFound() *JsonApiError {
+	return notFoundError(resourceType(reflect.Zero(m.resource).Interface()))
+}
+
+// presentKeysOf => the keys present in the request document the record was unmarshalled from, nil if not recorded
+func presentKeysOf(record interface{}) map[string]bool {
+	if tracker, ok := record.(presenceTracker); ok {
+		return tracker.presentKeys()
+	}
+	return nil
+}
+
+var softDeleterType = reflect.TypeOf((*SoftDeleter)(nil)).Elem()
+
+// sqlBuilder => collects the arguments of a statement and returns their placeholders
+type sqlBuilder struct {
+	dialect Dialect
+	args    []interface{}
+}
+
+func (b *sqlBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return b.dialect.Placeholder(len(b.args))
+}
+
+func (b *sqlBuilder) list(values []interface{}) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = b.arg(v)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// filter => the condition of a filter, or the OR-ed conditions of a group of filters
+// EX: ("make" IN ($1) OR "year" IN ($2))
+func (b *sqlBuilder) filter(attributes map[string]sqlColumn, f Filter) (string, *JsonApiError) {
+	if len(f.Or) > 0 {
+		conditions := make([]string, len(f.Or))
+		for i, filter := range f.Or {
+			condition, err := b.filter(attributes, filter)
+			if err != nil {
+				return "", err
+			}
+			conditions[i] = condition
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", nil
+	}
+
+	column, ok := attributes[f.Attribute]
+	if !ok {
+		return "", NewError(400).Title("Invalid Query Parameter").
+			Detail("cannot filter by " + f.Attribute).Parameter("filter[" + formatKey(f.Attribute) + "]").Build()
+	}
+	return b.in(column, f)
+}
+
+// in => the condition of a filter, whose values are converted to the column's type
+// EX: "make" IN ($1, $2) or ("year" IS NULL OR "year" IN ($1))
+func (b *sqlBuilder) in(column sqlColumn, f Filter) (string, *JsonApiError) {
+	quoted := b.dialect.Quote(column.name)
+	values := []interface{}{}
+	null := false
+	for _, s := range f.Values {
+		if s == "null" {
+			null = true
+			continue
+		}
+		v, e := columnValue(column.t, s)
+		if e != nil {
+			return "", NewError(400).Title("Invalid Query Parameter").Detail(s + " is not a valid " + f.Attribute).
+				Parameter("filter[" + formatKey(f.Attribute) + "]").Build()
+		}
+		values = append(values, v)
+	}
+
+	switch {
+	case len(values) == 0:
+		return quoted + " IS NULL", nil
+	case null:
+		return "(" + quoted + " IS NULL OR " + quoted + " IN (" + b.list(values) + "))", nil
+	}
+	return quoted + " IN (" + b.list(values) + ")", nil
+}
+
+// columnValue => a filter value converted to the kind of a column's model field, EX: "2010" => 2010
+// NOTE: the value of a Scanner, EX: null.Bool or sql.NullInt64, is converted to the kind of the value it wraps
+func columnValue(t reflect.Type, s string) (interface{}, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if wrapped, ok := scannedType(t); ok {
+		return columnValue(wrapped, s)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return s, nil
+}
+
+// scannedType => the type of the value a Scanner struct wraps, i.e., its first field other than Valid,
+// EX: null.Bool (which embeds sql.NullBool) => bool
+func scannedType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || !reflect.PtrTo(t).Implements(scannerType) {
+		return nil, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			if wrapped, ok := scannedType(field.Type); ok {
+				return wrapped, true
+			}
+			continue
+		}
+		if field.PkgPath == "" && field.Name != "Valid" {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	interfaces := make([]interface{}, len(values))
+	for i, v := range values {
+		interfaces[i] = v
+	}
+	return interfaces
+}
+
+// sqlError => a 500 error for a database error
+// NOTE: the error is logged rather than returned to the client, since it may contain SQL, table and column names
+func sqlError(e error) *JsonApiError {
+	log.Println("gsonapi: database error:", e)
+	return NewError(500).Detail("database error").Build()
+}
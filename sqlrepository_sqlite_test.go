@@ -0,0 +1,112 @@
+// NOTE: runs against the cgo SQLite driver vendored in Godeps (needs CGO_ENABLED=1 and a C compiler); the fake driver
+// tests in sqlrepository_test.go cover the generated SQL
+
+package gsonapi
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQLRepository w/ SQLite", func() {
+	var (
+		dir     string
+		db      *sql.DB
+		drivers *SQLRepository
+		dealers *SQLRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gsonapi")
+		Ω(err).ShouldNot(HaveOccurred())
+		db, err = sql.Open("sqlite3", filepath.Join(dir, "test.db"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for _, statement := range []string{
+			`CREATE TABLE drivers (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, active BOOLEAN)`,
+			`CREATE TABLE dealers (id TEXT PRIMARY KEY, dealer_name TEXT)`,
+			`CREATE TABLE dealers_mechanics (dealer_id TEXT, mechanic_id TEXT)`,
+		} {
+			_, err = db.Exec(statement)
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+
+		drivers = NewSQLRepository(db, SQLite, "drivers", DriverResource{}, DriverModel{})
+		dealers = NewSQLRepository(db, SQLite, "dealers", DealerResource{}, DealerModel{})
+		dealers.JoinTables["mechanics"] = JoinTable{Table: "dealers_mechanics", Column: "dealer_id", RelatedColumn: "mechanic_id"}
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should create, find, update and delete records", func() {
+		for _, name := range []string{"paul", "steve", "ayrton"} {
+			Ω(drivers.Create(&DriverResource{Name: name, Age: 40, Active: name != "steve"})).Should(BeNil())
+		}
+
+		q, _ := ParseQuery(url.Values{"filter[active]": {"true"}, "sort": {"-name"}, "page[size]": {"1"}})
+		records, total, err := drivers.FindAll(q)
+		Ω(err).Should(BeNil())
+		Ω(total).Should(Equal(2))
+		Ω(records.([]DriverResource)[0].Name).Should(Equal("paul"))
+
+		record, err := drivers.FindOne("2")
+		Ω(err).Should(BeNil())
+		steve := record.(*DriverResource)
+		steve.Age = 45
+		Ω(drivers.Update(steve)).Should(BeNil())
+
+		record, _ = drivers.FindOne("2")
+		Ω(record.(*DriverResource).Age).Should(Equal(45))
+
+		Ω(drivers.Delete("2")).Should(BeNil())
+		_, err = drivers.FindOne("2")
+		Ω(err.Status).Should(Equal("404"))
+	})
+
+	It("should only update the attributes present in a partial update", func() {
+		Ω(drivers.Create(&DriverResource{Name: "paul", Age: 40, Active: true})).Should(BeNil())
+
+		r := DriverResource{}
+		r.SetID("1")
+		body := []byte(`{"data":{"type":"drivers","id":"1","attributes":{"age":46}}}`)
+		Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+		Ω(drivers.Update(&r)).Should(BeNil())
+
+		record, _ := drivers.FindOne("1")
+		Ω(record.(*DriverResource).Name).Should(Equal("paul"))
+		Ω(record.(*DriverResource).Age).Should(Equal(46))
+		Ω(record.(*DriverResource).Active).Should(BeTrue())
+
+		q, _ := ParseQuery(url.Values{"filter[or][name]": {"steve"}, "filter[or][age]": {"46"}})
+		_, total, err := drivers.FindAll(q)
+		Ω(err).Should(BeNil())
+		Ω(total).Should(Equal(1))
+	})
+
+	It("should save and load relationships through join tables", func() {
+		r := &DealerResource{Name: "Main", MechanicIDs: []string{"m1", "m2"}}
+		r.SetID("g1")
+		Ω(dealers.Create(r)).Should(BeNil())
+		Ω(dealers.Create(r).Status).Should(Equal("409"))
+
+		records, err := dealers.FindMany([]string{"g1"})
+		Ω(err).Should(BeNil())
+		Ω(records.([]DealerResource)[0].MechanicIDs).Should(Equal([]string{"m1", "m2"}))
+
+		Ω(dealers.Delete("g1")).Should(BeNil())
+		count := 0
+		Ω(db.QueryRow(`SELECT COUNT(*) FROM dealers_mechanics`).Scan(&count)).Should(Succeed())
+		Ω(count).Should(Equal(0))
+	})
+})
@@ -0,0 +1,419 @@
+package gsonapi
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// fakeSQL => a database/sql driver that records the statements it executes and returns canned rows
+type fakeSQL struct {
+	mutex      sync.Mutex
+	statements []string
+	args       [][]driver.Value
+	rows       func(query string, args []driver.Value) ([]string, [][]driver.Value)
+	affected   int64
+}
+
+var fakeDB = &fakeSQL{}
+
+func init() {
+	sql.Register("gsonapi-fake", fakeDB)
+}
+
+func (f *fakeSQL) reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.statements, f.args, f.affected = nil, nil, 1
+	f.rows = func(string, []driver.Value) ([]string, [][]driver.Value) { return nil, nil }
+}
+
+func (f *fakeSQL) record(query string, args []driver.Value) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.statements = append(f.statements, query)
+	f.args = append(f.args, args)
+}
+
+func (f *fakeSQL) Open(name string) (driver.Conn, error) { return fakeConn{f}, nil }
+
+type fakeConn struct{ f *fakeSQL }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.f, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { c.f.record("BEGIN", nil); return fakeTx{c.f}, nil }
+
+type fakeTx struct{ f *fakeSQL }
+
+func (t fakeTx) Commit() error   { t.f.record("COMMIT", nil); return nil }
+func (t fakeTx) Rollback() error { t.f.record("ROLLBACK", nil); return nil }
+
+type fakeStmt struct {
+	f     *fakeSQL
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.f.record(s.query, args)
+	if strings.Contains(s.query, "fail") {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(s.f.affected), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.f.record(s.query, args)
+	columns, rows := s.f.rows(s.query, args)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// Dealer Model (w/ the mechanics of a DealerResource stored in a join table)
+type DealerModel struct {
+	ID   string
+	Name string `db:"dealer_name"`
+	Note string `db:"-"`
+}
+
+var _ = Describe("SQLRepository", func() {
+	query := func(values url.Values) *Query {
+		q, err := ParseQuery(values)
+		Ω(err).Should(BeNil())
+		return q
+	}
+
+	Context("translating queries", func() {
+		It("should map columns from the model's fields and db tags", func() {
+			drivers := NewSQLRepository(nil, Postgres, "drivers", DriverResource{}, DriverModel{})
+			Ω(drivers.selectSQL()).Should(Equal(`SELECT "id", "name", "age", "active" FROM "drivers"`))
+
+			dealers := NewSQLRepository(nil, Postgres, "dealers", DealerResource{}, DealerModel{})
+			Ω(dealers.selectSQL()).Should(Equal(`SELECT "id", "dealer_name" FROM "dealers"`))
+		})
+
+		It("should translate filters, sort and page into parameterized Postgres", func() {
+			drivers := NewSQLRepository(nil, Postgres, "drivers", DriverResource{}, DriverModel{})
+			q := query(url.Values{"filter[active]": {"true"}, "filter[age]": {"40,45"}, "sort": {"-age,name"},
+				"page[number]": {"2"}, "page[size]": {"10"}})
+
+			statement, args, count, countArgs, err := drivers.findAllSQL(q)
+			Ω(err).Should(BeNil())
+			Ω(statement).Should(Equal(`SELECT "id", "name", "age", "active" FROM "drivers" WHERE "active" IN ($1) AND "age" IN ($2, $3)` +
+				` ORDER BY "age" DESC, "name", "id" LIMIT $4 OFFSET $5`))
+			Ω(args).Should(Equal([]interface{}{true, int64(40), int64(45), 10, 10}))
+			Ω(count).Should(Equal(`SELECT COUNT(*) FROM "drivers" WHERE "active" IN ($1) AND "age" IN ($2, $3)`))
+			Ω(countArgs).Should(Equal([]interface{}{true, int64(40), int64(45)}))
+		})
+
+		It("should translate queries into SQLite", func() {
+			drivers := NewSQLRepository(nil, SQLite, "drivers", DriverResource{}, DriverModel{})
+			statement, args, _, _, err := drivers.findAllSQL(query(url.Values{"filter[name]": {"null,paul"}}))
+			Ω(err).Should(BeNil())
+			Ω(statement).Should(Equal(`SELECT "id", "name", "age", "active" FROM "drivers" WHERE ("name" IS NULL OR "name" IN (?)) ORDER BY "id"`))
+			Ω(args).Should(Equal([]interface{}{"paul"}))
+		})
+
+		It("should translate the filter tree into AND and OR conditions", func() {
+			drivers := NewSQLRepository(nil, Postgres, "drivers", DriverResource{}, DriverModel{})
+			q := query(url.Values{"filter[active]": {"true"}, "filter[or][name]": {"paul"}, "filter[or][age]": {"40"}})
+			q.AddFilters(Filter{Or: []Filter{{Attribute: "name", Values: []string{"steve"}}, {Or: []Filter{
+				{Attribute: "age", Values: []string{"45"}},
+				{Attribute: "active", Values: []string{"false"}},
+			}}}})
+
+			_, args, count, _, err := drivers.findAllSQL(q)
+			Ω(err).Should(BeNil())
+			Ω(count).Should(Equal(`SELECT COUNT(*) FROM "drivers" WHERE "active" IN ($1) AND ("age" IN ($2) OR "name" IN ($3))` +
+				` AND ("name" IN ($4) OR ("age" IN ($5) OR "active" IN ($6)))`))
+			Ω(args).Should(Equal([]interface{}{true, int64(40), "paul", "steve", int64(45), false}))
+
+			_, _, _, _, err = drivers.findAllSQL(query(url.Values{"filter[or][salary]": {"1"}}))
+			Ω(err.Detail).Should(Equal("cannot filter by salary"))
+		})
+
+		It("should take the key column from the model's ID field", func() {
+			type KeyedDealerModel struct {
+				ID   string `db:"dealer_id"`
+				Name string `db:"dealer_name"`
+			}
+			dealers := NewSQLRepository(nil, SQLite, "dealers", DealerResource{}, KeyedDealerModel{})
+			statement, _, _, _, err := dealers.findAllSQL(query(url.Values{"filter[id]": {"g1"}}))
+			Ω(err).Should(BeNil())
+			Ω(statement).Should(Equal(`SELECT "dealer_id", "dealer_name" FROM "dealers" WHERE "dealer_id" IN (?) ORDER BY "dealer_id"`))
+
+			r := &DealerResource{Name: "Main"}
+			r.SetID("g1")
+			model, _ := dealers.toModel(r)
+			statement, _ = dealers.updateSQL(model, "g1", nil)
+			Ω(statement).Should(Equal(`UPDATE "dealers" SET "dealer_name" = ? WHERE "dealer_id" = ?`))
+			statement, _ = dealers.insertSQL(model, true)
+			Ω(statement).Should(Equal(`INSERT INTO "dealers" ("dealer_name") VALUES (?) RETURNING "dealer_id"`))
+		})
+
+		It("should restrict queries to the tenant and exclude soft deleted records", func() {
+			type CabinModel struct {
+				ID        string
+				Name      string
+				DeletedAt *string
+				TenantID  string
+			}
+			cabins := NewSQLRepository(nil, SQLite, "cabins", CabinResource{}, CabinModel{})
+			cabins.TenantColumn = "tenant_id"
+
+			_, args, count, _, _ := cabins.findAllSQL(&Query{Tenant: "acme"})
+			Ω(count).Should(Equal(`SELECT COUNT(*) FROM "cabins" WHERE "deleted_at" IS NULL AND "tenant_id" = ?`))
+			Ω(args).Should(Equal([]interface{}{"acme"}))
+
+			_, _, count, _, _ = cabins.findAllSQL(&Query{Deleted: true})
+			Ω(count).Should(Equal(`SELECT COUNT(*) FROM "cabins"`))
+		})
+
+		It("should convert filter values to the value a null type wraps", func() {
+			v, err := columnValue(reflect.TypeOf(null.Bool{}), "true")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(v).Should(Equal(true))
+
+			v, _ = columnValue(reflect.TypeOf(sql.NullInt64{}), "40")
+			Ω(v).Should(Equal(int64(40)))
+
+			v, _ = columnValue(reflect.TypeOf(&null.String{}), "paul")
+			Ω(v).Should(Equal("paul"))
+
+			_, err = columnValue(reflect.TypeOf(null.Int{}), "old")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should reject filters and sorts that are not columns or values of the wrong type", func() {
+			drivers := NewSQLRepository(nil, Postgres, "drivers", DriverResource{}, DriverModel{})
+
+			_, _, _, _, err := drivers.findAllSQL(query(url.Values{"filter[salary]": {"1"}}))
+			Ω(err.Status).Should(Equal("400"))
+			Ω(err.Source.Parameter).Should(Equal("filter[salary]"))
+
+			_, _, _, _, err = drivers.findAllSQL(query(url.Values{"sort": {"salary"}}))
+			Ω(err.Detail).Should(Equal("cannot sort by salary"))
+
+			_, _, _, _, err = drivers.findAllSQL(query(url.Values{"filter[age]": {"old"}}))
+			Ω(err.Detail).Should(Equal("old is not a valid age"))
+		})
+	})
+
+	Context("w/ a database", func() {
+		var db *sql.DB
+
+		BeforeEach(func() {
+			fakeDB.reset()
+			var err error
+			db, err = sql.Open("gsonapi-fake", "")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should find records and count them", func() {
+			fakeDB.rows = func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				if strings.HasPrefix(query, "SELECT COUNT(*)") {
+					return []string{"count"}, [][]driver.Value{{int64(7)}}
+				}
+				return []string{"id", "name", "age", "active"}, [][]driver.Value{
+					{"d1", "paul", int64(40), true},
+					{"d2", "steve", int64(45), false},
+				}
+			}
+			drivers := NewSQLRepository(db, Postgres, "drivers", DriverResource{}, DriverModel{})
+
+			records, total, err := drivers.FindAll(query(url.Values{"page[size]": {"2"}}))
+			Ω(err).Should(BeNil())
+			Ω(total).Should(Equal(7))
+			Ω(records).Should(HaveLen(2))
+			Ω(records.([]DriverResource)[1].GetID()).Should(Equal("d2"))
+			Ω(records.([]DriverResource)[1].Age).Should(Equal(45))
+
+			record, err := drivers.FindOne("d1")
+			Ω(err).Should(BeNil())
+			Ω(record.(*DriverResource).Name).Should(Equal("paul"))
+		})
+
+		It("should find many records in the order of their ids", func() {
+			fakeDB.rows = func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				return []string{"id", "name", "age", "active"}, [][]driver.Value{
+					{"d1", "paul", int64(40), true},
+					{"d2", "steve", int64(45), false},
+				}
+			}
+			drivers := NewSQLRepository(db, SQLite, "drivers", DriverResource{}, DriverModel{})
+
+			records, err := drivers.FindMany([]string{"d2", "d9", "d1"})
+			Ω(err).Should(BeNil())
+			Ω(records.([]DriverResource)[0].Name).Should(Equal("steve"))
+			Ω(records.([]DriverResource)[1].Name).Should(Equal("paul"))
+			Ω(fakeDB.statements[0]).Should(HaveSuffix(`WHERE "id" IN (?, ?, ?)`))
+		})
+
+		It("should not return database errors to the client", func() {
+			err := sqlError(errors.New(`UNIQUE constraint failed: drivers.name in INSERT INTO "drivers"`))
+			Ω(err.Status).Should(Equal("500"))
+			Ω(err.Detail).Should(Equal("database error"))
+		})
+
+		It("should return a 404 error for a missing record", func() {
+			drivers := NewSQLRepository(db, SQLite, "drivers", DriverResource{}, DriverModel{})
+			_, err := drivers.FindOne("d9")
+			Ω(err.Status).Should(Equal("404"))
+
+			fakeDB.affected = 0
+			Ω(drivers.Update(&DriverResource{}).Status).Should(Equal("404"))
+			Ω(drivers.Delete("d9").Status).Should(Equal("404"))
+			Ω(fakeDB.statements).Should(ContainElement("ROLLBACK"))
+		})
+
+		It("should create a record w/ a generated id", func() {
+			fakeDB.rows = func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				return []string{"id"}, [][]driver.Value{{int64(12)}}
+			}
+			drivers := NewSQLRepository(db, Postgres, "drivers", DriverResource{}, DriverModel{})
+
+			r := &DriverResource{Name: "jackie", Age: 30}
+			Ω(drivers.Create(r)).Should(BeNil())
+			Ω(r.GetID()).Should(Equal("12"))
+			Ω(fakeDB.statements).Should(Equal([]string{
+				"BEGIN",
+				`INSERT INTO "drivers" ("name", "age", "active") VALUES ($1, $2, $3) RETURNING "id"`,
+				"COMMIT",
+			}))
+			Ω(fakeDB.args[1]).Should(Equal([]driver.Value{"jackie", int64(30), false}))
+		})
+
+		It("should reject a record whose id is taken", func() {
+			fakeDB.rows = func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				return []string{"count"}, [][]driver.Value{{int64(1)}}
+			}
+			drivers := NewSQLRepository(db, Postgres, "drivers", DriverResource{}, DriverModel{})
+
+			r := &DriverResource{Name: "jackie"}
+			r.SetID("d1")
+			err := drivers.Create(r)
+			Ω(err.Status).Should(Equal("409"))
+			Ω(fakeDB.statements[len(fakeDB.statements)-1]).Should(Equal("ROLLBACK"))
+		})
+
+		It("should save and load relationships through join tables", func() {
+			fakeDB.rows = func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				switch {
+				case strings.HasPrefix(query, "SELECT COUNT(*)"):
+					return []string{"count"}, [][]driver.Value{{int64(0)}}
+				case strings.Contains(query, "dealers_mechanics"):
+					return []string{"dealer_id", "mechanic_id"}, [][]driver.Value{{"g1", "m1"}, {"g1", "m2"}}
+				}
+				return []string{"id", "dealer_name"}, [][]driver.Value{{"g1", "Main"}}
+			}
+			dealers := NewSQLRepository(db, SQLite, "dealers", DealerResource{}, DealerModel{})
+			dealers.JoinTables["mechanics"] = JoinTable{Table: "dealers_mechanics", Column: "dealer_id", RelatedColumn: "mechanic_id"}
+
+			r := &DealerResource{Name: "Main", MechanicIDs: []string{"m1", "m2"}}
+			r.SetID("g1")
+			Ω(dealers.Create(r)).Should(BeNil())
+			Ω(fakeDB.statements).Should(Equal([]string{
+				"BEGIN",
+				`SELECT COUNT(*) FROM "dealers" WHERE "id" = ?`,
+				`INSERT INTO "dealers" ("id", "dealer_name") VALUES (?, ?)`,
+				`DELETE FROM "dealers_mechanics" WHERE "dealer_id" = ?`,
+				`INSERT INTO "dealers_mechanics" ("dealer_id", "mechanic_id") VALUES (?, ?)`,
+				`INSERT INTO "dealers_mechanics" ("dealer_id", "mechanic_id") VALUES (?, ?)`,
+				"COMMIT",
+			}))
+			Ω(fakeDB.args[5]).Should(Equal([]driver.Value{"g1", "m2"}))
+
+			record, err := dealers.FindOne("g1")
+			Ω(err).Should(BeNil())
+			Ω(record.(*DealerResource).Name).Should(Equal("Main"))
+			Ω(record.(*DealerResource).MechanicIDs).Should(Equal([]string{"m1", "m2"}))
+		})
+
+		It("should leave the relationships absent from a request document unchanged", func() {
+			dealers := NewSQLRepository(db, SQLite, "dealers", DealerResource{}, DealerModel{})
+			dealers.JoinTables["mechanics"] = JoinTable{Table: "dealers_mechanics", Column: "dealer_id", RelatedColumn: "mechanic_id"}
+
+			r := DealerResource{}
+			r.SetID("g1")
+			body := []byte(`{"data":{"type":"dealers","id":"g1","attributes":{"name":"Main"}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(dealers.Update(&r)).Should(BeNil())
+			Ω(fakeDB.statements).Should(Equal([]string{
+				"BEGIN",
+				`UPDATE "dealers" SET "dealer_name" = ? WHERE "id" = ?`,
+				"COMMIT",
+			}))
+		})
+
+		It("should only update the columns present in a request document", func() {
+			drivers := NewSQLRepository(db, Postgres, "drivers", DriverResource{}, DriverModel{})
+
+			r := DriverResource{}
+			r.SetID("d1")
+			body := []byte(`{"data":{"type":"drivers","id":"d1","attributes":{"age":46}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(drivers.Update(&r)).Should(BeNil())
+			Ω(fakeDB.statements).Should(Equal([]string{"BEGIN", `UPDATE "drivers" SET "age" = $1 WHERE "id" = $2`, "COMMIT"}))
+			Ω(fakeDB.args[1]).Should(Equal([]driver.Value{int64(46), "d1"}))
+		})
+
+		It("should not update the columns of a request document w/o attributes", func() {
+			fakeDB.rows = func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				return []string{"count"}, [][]driver.Value{{int64(1)}}
+			}
+			dealers := NewSQLRepository(db, SQLite, "dealers", DealerResource{}, DealerModel{})
+			dealers.JoinTables["mechanics"] = JoinTable{Table: "dealers_mechanics", Column: "dealer_id", RelatedColumn: "mechanic_id"}
+
+			r := DealerResource{}
+			r.SetID("g1")
+			body := []byte(`{"data":{"type":"dealers","id":"g1","relationships":{"mechanics":{"data":[]}}}}`)
+			Ω(UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)).Should(BeEmpty())
+			Ω(dealers.Update(&r)).Should(BeNil())
+			Ω(fakeDB.statements).Should(Equal([]string{
+				"BEGIN",
+				`SELECT COUNT(*) FROM "dealers" WHERE "id" = ?`,
+				`DELETE FROM "dealers_mechanics" WHERE "dealer_id" = ?`,
+				"COMMIT",
+			}))
+		})
+
+		It("should delete a record and its join table rows", func() {
+			dealers := NewSQLRepository(db, Postgres, "dealers", DealerResource{}, DealerModel{})
+			dealers.JoinTables["mechanics"] = JoinTable{Table: "dealers_mechanics", Column: "dealer_id", RelatedColumn: "mechanic_id"}
+
+			Ω(dealers.Delete("g1")).Should(BeNil())
+			Ω(fakeDB.statements).Should(Equal([]string{
+				"BEGIN",
+				`DELETE FROM "dealers_mechanics" WHERE "dealer_id" = $1`,
+				`DELETE FROM "dealers" WHERE "id" = $1`,
+				"COMMIT",
+			}))
+		})
+	})
+})
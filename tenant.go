@@ -0,0 +1,128 @@
+package gsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+)
+
+// TENANT_PLACEHOLDER => replaced w/ the request's tenant in JSONApiServerInfo's BaseURL and Prefix
+// EX: https://{tenant}.myapi.com/v1
+const TENANT_PLACEHOLDER = "{tenant}"
+
+// Tenant => the tenant a request was resolved to; mapped into the martini context by TenantHandler
+type Tenant string
+
+// TenantResolver => determines which tenant a request belongs to
+type TenantResolver interface {
+	ResolveTenant(req *http.Request) (string, error)
+}
+
+// TenantOwner => optional interface for resources that belong to a tenant
+// NOTE: records owned by another tenant, or by any tenant when read by a request w/o one, are reported as not found (404)
+type TenantOwner interface {
+	GetTenant() string
+}
+
+// SubdomainTenantResolver => resolves the tenant from the host's subdomain
+// EX: Domain "myapi.com" resolves "acme.myapi.com" to "acme"
+type SubdomainTenantResolver struct {
+	Domain string
+}
+
+func (s SubdomainTenantResolver) ResolveTenant(req *http.Request) (string, error) {
+	host := strings.Split(req.Host, ":")[0]
+	suffix := "." + strings.Trim(s.Domain, ".")
+
+	if !strings.HasSuffix(host, suffix) || len(host) == len(suffix) {
+		return "", fmt.Errorf("host %s is not a subdomain of %s", host, s.Domain)
+	}
+	return host[:len(host)-len(suffix)], nil
+}
+
+// HeaderTenantResolver => resolves the tenant from a request header, e.g., X-Tenant-ID
+type HeaderTenantResolver struct {
+	Header string
+}
+
+func (h HeaderTenantResolver) ResolveTenant(req *http.Request) (string, error) {
+	if tenant := req.Header.Get(h.Header); tenant != "" {
+		return tenant, nil
+	}
+	return "", errors.New(h.Header + " header is missing")
+}
+
+// ClaimTenantResolver => resolves the tenant from a JWT claim
+// NOTE: Claims must return the claims of a token that the app's authentication layer already verified
+type ClaimTenantResolver struct {
+	Claim  string
+	Claims func(req *http.Request) (map[string]interface{}, error)
+}
+
+func (c ClaimTenantResolver) ResolveTenant(req *http.Request) (string, error) {
+	claims, err := c.Claims(req)
+	if err != nil {
+		return "", err
+	}
+	if tenant, ok := claims[c.Claim].(string); ok && tenant != "" {
+		return tenant, nil
+	}
+	return "", errors.New(c.Claim + " claim is missing")
+}
+
+// TenantHandler => martini middleware that resolves the request's tenant and maps it as a Tenant,
+// as well as the JSONApiServerInfo mapped before it w/ its Tenant set (or a new one if none was mapped)
+// NOTE: renders a 400 error if the tenant cannot be resolved
+func TenantHandler(resolver TenantResolver) martini.Handler {
+	return func(c martini.Context, req *http.Request, r render.Render) {
+		tenant, err := resolver.ResolveTenant(req)
+		if err != nil {
+			renderError(400, &JsonApiError{Status: "400", Title: "Unknown Tenant", Detail: err.Error()}, r)
+			return
+		}
+
+		jasi := JSONApiServerInfo{}
+		if v := c.Get(reflect.TypeOf(jasi)); v.IsValid() {
+			jasi = v.Interface().(JSONApiServerInfo)
+		}
+		jasi.Tenant = tenant
+
+		c.Map(Tenant(tenant))
+		c.Map(jasi)
+	}
+}
+
+// ApplyPageSizeLimit => defaults the query's page size to the tenant's limit and
+// returns a 400 error if the requested size exceeds it
+func ApplyPageSizeLimit(jasi JSONApiServerInfo, q *Query) *JsonApiError {
+	limit := Config.PageSizeLimit(jasi.Tenant)
+
+	if q.Page.Size == 0 {
+		q.Page.Size = limit
+	} else if q.Page.Size > limit {
+		return &JsonApiError{Status: "400", Title: "Invalid Query Parameter",
+			Detail: fmt.Sprintf("page[size] cannot be greater than %d", limit),
+			Source: &JsonApiErrorSource{Parameter: "page[size]"}}
+	}
+	return nil
+}
+
+// authorizeTenant => returns a 404 error if the record belongs to another tenant
+// NOTE: fails closed, i.e., records owned by a tenant are not found when the request has no tenant,
+// while records w/o a tenant are only found by requests w/o one
+func authorizeTenant(jasi JSONApiServerInfo, record interface{}) *JsonApiError {
+	if owner, ok := record.(TenantOwner); ok && owner.GetTenant() != jasi.Tenant {
+		return notFoundError(resourceType(record))
+	}
+	return nil
+}
+
+// withTenant => replaces the tenant placeholder
+func withTenant(s string, tenant string) string {
+	return strings.Replace(s, TENANT_PLACEHOLDER, tenant, -1)
+}
@@ -0,0 +1,181 @@
+package gsonapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Garage Resource (owned by a tenant)
+type GarageResource struct {
+	Resource `jsonapi:"-"`
+	Tenant   string `json:"-" jsonapi:"-"`
+	Name     string `json:"name,omitempty" jsonapi:"name=name"`
+}
+
+func (r GarageResource) GetName() string {
+	return "garages"
+}
+
+func (r GarageResource) GetTenant() string {
+	return r.Tenant
+}
+
+func BuildGarage(id string, tenant string) GarageResource {
+	g := GarageResource{Tenant: tenant, Name: "garage " + id}
+	g.SetID(id)
+	return g
+}
+
+var _ = Describe("Tenant", func() {
+	var (
+		server   *martini.ClassicMartini
+		recorder *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		server = martini.Classic()
+		server.Use(render.Renderer())
+		recorder = httptest.NewRecorder()
+	})
+
+	Context("Resolvers", func() {
+		It("should resolve the tenant from the subdomain", func() {
+			request, _ := http.NewRequest("GET", "http://acme.carz.com:8080/v1/automobiles", nil)
+
+			tenant, err := SubdomainTenantResolver{Domain: "carz.com"}.ResolveTenant(request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tenant).Should(Equal("acme"))
+
+			request, _ = http.NewRequest("GET", "http://carz.com/v1/automobiles", nil)
+			_, err = SubdomainTenantResolver{Domain: "carz.com"}.ResolveTenant(request)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should resolve the tenant from a header", func() {
+			request, _ := http.NewRequest("GET", "/v1/automobiles", nil)
+			request.Header.Set("X-Tenant-ID", "acme")
+
+			tenant, err := HeaderTenantResolver{Header: "X-Tenant-ID"}.ResolveTenant(request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tenant).Should(Equal("acme"))
+		})
+
+		It("should resolve the tenant from a jwt claim", func() {
+			resolver := ClaimTenantResolver{Claim: "tenant", Claims: func(req *http.Request) (map[string]interface{}, error) {
+				if req.Header.Get("Authorization") == "" {
+					return nil, errors.New("unauthenticated")
+				}
+				return map[string]interface{}{"sub": "user-1", "tenant": "acme"}, nil
+			}}
+			request, _ := http.NewRequest("GET", "/v1/automobiles", nil)
+
+			_, err := resolver.ResolveTenant(request)
+			Ω(err).Should(HaveOccurred())
+
+			request.Header.Set("Authorization", "Bearer token")
+			tenant, err := resolver.ResolveTenant(request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tenant).Should(Equal("acme"))
+		})
+	})
+
+	Context("Middleware", func() {
+		BeforeEach(func() {
+			server.Use(func(c martini.Context) {
+				c.Map(JSONApiServerInfo{BaseURL: "http://{tenant}.my.domain", Prefix: "v1"})
+			})
+			server.Use(TenantHandler(HeaderTenantResolver{Header: "X-Tenant-ID"}))
+			server.Get("/v1/garages/:id", func(params martini.Params, tenant Tenant, jasi JSONApiServerInfo, r render.Render) {
+				Ω(jasi.Tenant).Should(Equal(string(tenant)))
+				Ω(jasi.GetBaseURL()).Should(Equal("http://" + string(tenant) + ".my.domain"))
+				HandleGetResponse(jasi, nil, BuildGarage(params["id"], "acme"), r)
+			})
+		})
+
+		It("should return a 400 Status Code when the tenant cannot be resolved", func() {
+			request, _ := http.NewRequest("GET", "/v1/garages/1", nil)
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(400))
+		})
+
+		It("should return a 200 Status Code for a record owned by the tenant", func() {
+			request, _ := http.NewRequest("GET", "/v1/garages/1", nil)
+			request.Header.Set("X-Tenant-ID", "acme")
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(200))
+		})
+
+		It("should return a 404 Status Code for a record owned by another tenant", func() {
+			request, _ := http.NewRequest("GET", "/v1/garages/1", nil)
+			request.Header.Set("X-Tenant-ID", "globex")
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(404))
+		})
+	})
+
+	Context("Authorization", func() {
+		It("should not find tenant owned records when the request has no tenant", func() {
+			err := authorizeTenant(JSONApiServerInfo{}, BuildGarage("1", "acme"))
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Status).Should(Equal("404"))
+			Ω(authorizeTenant(JSONApiServerInfo{}, LotResource{})).Should(BeNil())
+			Ω(authorizeTenant(JSONApiServerInfo{}, BuildGarage("1", ""))).Should(BeNil())
+			Ω(authorizeTenant(JSONApiServerInfo{Tenant: "acme"}, BuildGarage("1", ""))).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Links", func() {
+		It("should bake the tenant into the generated links", func() {
+			jasi := JSONApiServerInfo{BaseURL: "https://{tenant}.my.domain", Prefix: "{tenant}/v1", Tenant: "acme"}
+
+			Ω(jasi.GetBaseURL()).Should(Equal("https://acme.my.domain"))
+			Ω(jasi.GetPrefix()).Should(Equal("acme/v1"))
+			Ω(TEST_SERVER_INFO.GetBaseURL()).Should(Equal("http://my.domain"))
+		})
+	})
+
+	Context("Queries", func() {
+		It("should propagate the tenant to the query", func() {
+			q := &Query{}
+			ScopeQuery(JSONApiServerInfo{Tenant: "acme"}, "garages", q)
+			Ω(q.Tenant).Should(Equal("acme"))
+		})
+
+		It("should only list the tenant's records", func() {
+			server.Get("/v1/garages", func(r render.Render) {
+				garages := []GarageResource{BuildGarage("1", "acme"), BuildGarage("2", "globex")}
+				HandleIndexResponse(JSONApiServerInfo{Tenant: "acme"}, nil, garages, r)
+			})
+			request, _ := http.NewRequest("GET", "/v1/garages", nil)
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(200))
+			Ω(recorder.Body.String()).Should(MatchJSON(`{"data":[{"type":"garages","id":"1","attributes":{"name":"garage 1"}}]}`))
+		})
+
+		It("should apply the tenant's page size limit", func() {
+			values, _ := url.ParseQuery("page[size]=50")
+			q, _ := ParseQuery(values)
+
+			Ω(ApplyPageSizeLimit(JSONApiServerInfo{Tenant: "globex"}, q)).Should(BeNil())
+
+			err := ApplyPageSizeLimit(JSONApiServerInfo{Tenant: "acme"}, q)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Source.Parameter).Should(Equal("page[size]"))
+
+			q = &Query{}
+			Ω(ApplyPageSizeLimit(JSONApiServerInfo{Tenant: "acme"}, q)).Should(BeNil())
+			Ω(q.Page.Size).Should(Equal(25))
+		})
+	})
+})
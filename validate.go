@@ -0,0 +1,315 @@
+package gsonapi
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	validations "github.com/obieq/goar-validations"
+)
+
+// GetValidators => parses an attribute's validate struct tag into goar validators
+// EX: `validate:"required,range=1900|2016"`, `validate:"min-size=2,max-size=20"`, `validate:"email"`
+// and `validate:"length=17,match=^[A-Z0-9]+$"`
+// NOTE: match must be the last rule b/c its regular expression may contain commas;
+// returns an error for an invalid rule or regular expression, which RegisterResource reports at startup
+func GetValidators(field reflect.StructField) ([]validations.Validator, error) {
+	validators := []validations.Validator{}
+
+	tag := field.Tag.Get("validate")
+	if tag == "" {
+		return validators, nil
+	}
+
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		kv := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		n, err := strconv.Atoi(value)
+
+		switch kv[0] {
+		case "required":
+			validators = append(validators, validations.ValidRequired())
+			continue
+		case "email":
+			validators = append(validators, validations.ValidEmail())
+			continue
+		case "match":
+			value = strings.Join(append([]string{value}, rules[i+1:]...), ",")
+			expression, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("gson api validate tag error: %s %s: %v", field.Name, rule, err)
+			}
+			validators = append(validators, validations.ValidMatch(expression))
+			return validators, nil
+		case "range":
+			bounds := strings.Split(value, "|")
+			if len(bounds) == 2 {
+				min, minErr := strconv.Atoi(bounds[0])
+				max, maxErr := strconv.Atoi(bounds[1])
+				if minErr == nil && maxErr == nil {
+					validators = append(validators, validations.ValidRange(min, max))
+					continue
+				}
+			}
+			err = strconv.ErrSyntax
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("gson api validate tag error: %s %s", field.Name, rule)
+		}
+		switch kv[0] {
+		case "min":
+			validators = append(validators, validations.ValidMin(n))
+		case "max":
+			validators = append(validators, validations.ValidMax(n))
+		case "min-size":
+			validators = append(validators, validations.ValidMinSize(n))
+		case "max-size":
+			validators = append(validators, validations.ValidMaxSize(n))
+		case "length":
+			validators = append(validators, validations.ValidLength(n))
+		default:
+			return nil, fmt.Errorf("gson api validate tag error: %s %s", field.Name, rule)
+		}
+	}
+
+	return validators, nil
+}
+
+// validatorCache => the validators of struct types' fields by field index, parsed once per type
+var validatorCache = struct {
+	sync.RWMutex
+	types map[reflect.Type]typeValidators
+}{types: map[reflect.Type]typeValidators{}}
+
+type typeValidators struct {
+	fields [][]validations.Validator
+	err    error
+}
+
+// validatorsOf => the cached validators of a struct type's fields by field index, see GetValidators
+func validatorsOf(t reflect.Type) ([][]validations.Validator, error) {
+	validatorCache.RLock()
+	v, ok := validatorCache.types[t]
+	validatorCache.RUnlock()
+	if ok {
+		return v.fields, v.err
+	}
+
+	v.fields = make([][]validations.Validator, t.NumField())
+	for i := range v.fields {
+		if v.fields[i], v.err = GetValidators(t.Field(i)); v.err != nil {
+			break
+		}
+	}
+
+	validatorCache.Lock()
+	validatorCache.types[t] = v
+	validatorCache.Unlock()
+	return v.fields, v.err
+}
+
+// checkValidators => the first error of the validate tags of a struct type and the types of its nested attributes
+func checkValidators(t reflect.Type, checked map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || checked[t] {
+		return nil
+	}
+	checked[t] = true
+
+	if _, err := validatorsOf(t); err != nil {
+		return err
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.PkgPath == "" && isNestedType(field.Type) {
+			if err := checkValidators(field.Type, checked); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateResource => runs the validators of the resource's validate tags and sets the resource's errors
+// NOTE: returns a 500 error for an invalid validate tag of a resource that was not registered, see RegisterResource;
+// attributes absent from a PATCH request are not validated and only required is checked
+// for null attributes, where required only means not null for null.* and pointer attributes, e.g., false and 0 are valid;
+// the fields of nested attributes are validated too; returns the resource's (422) errors, ordered by attribute declaration order
+func ValidateResource(action Action, resource Resourcer) []JsonApiError {
+	failures := []validationFailure{}
+
+	var present map[string]bool
+	if tracker, ok := resource.(presenceTracker); ok {
+		present = tracker.presentKeys()
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(resource))
+	validators, err := validatorsOf(rv.Type())
+	if err != nil {
+		return []JsonApiError{*NewError(500).Detail(err.Error()).Build()}
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !isAttribute(field) {
+			continue
+		}
+
+		name := attributeName(field)
+		if _, ok := present[name]; !ok && present != nil && action != ActionCreate {
+			continue
+		}
+
+		if err := validateField(&failures, name, field, validators[i], rv.Field(i)); err != nil {
+			return []JsonApiError{*NewError(500).Detail(err.Error()).Build()}
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	if adder, ok := resource.(validatorErrorAdder); ok {
+		resource.SetErrors(nil)
+		for _, f := range failures {
+			adder.AddValidatorError(f.key, f.validator, "")
+		}
+	} else {
+		v := validations.Validation{}
+		for _, f := range failures {
+			v.Errors = append(v.Errors, &validations.ValidationError{Key: f.key, Message: strings.TrimSpace(f.validator.DefaultMessage())})
+		}
+		resource.SetErrors(v.ErrorMap())
+	}
+	return ResourceErrors(resource)
+}
+
+// validationFailure => the first validator a field failed
+type validationFailure struct {
+	key       string
+	validator validations.Validator
+}
+
+// validatorErrorAdder => implemented by resources that embed Resource
+type validatorErrorAdder interface {
+	AddValidatorError(key string, v validations.Validator, message string)
+}
+
+// validateField => validates a field w/ its validators and, if it holds nested attributes, their fields
+// EX: the key of an inspection's location is inspections/1/location
+func validateField(failures *[]validationFailure, key string, field reflect.StructField, validators []validations.Validator, fv reflect.Value) error {
+	value := validationValue(fv)
+	for _, validator := range validators {
+		if !validates(validator, value, isNullable(fv.Type())) {
+			*failures = append(*failures, validationFailure{key: key, validator: validator})
+			break
+		}
+	}
+
+	if isNestedType(field.Type) {
+		return validateNested(failures, key, fv)
+	}
+	return nil
+}
+
+// validateNested => validates the fields of nested attributes
+func validateNested(failures *[]validationFailure, key string, fv reflect.Value) error {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			return validateNested(failures, key, fv.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := validateNested(failures, key+"/"+strconv.Itoa(i), fv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		validators, err := validatorsOf(fv.Type())
+		if err != nil {
+			return err
+		}
+		for i := 0; i < fv.NumField(); i++ {
+			field := fv.Type().Field(i)
+			if field.PkgPath != "" || field.Tag.Get("jsonapi") == "-" || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if err := validateField(failures, key+"/"+pointerToken(nestedFieldName(field)), field, validators[i], fv.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validates => true if the value satisfies the validator, where null values only fail Required
+// NOTE: the value of a nullable field, i.e., a null.* or a pointer, satisfies Required as long as it is not null,
+// whereas goar's Required also rejects zero values, EX: false or 0
+func validates(validator validations.Validator, value interface{}, nullable bool) bool {
+	_, required := validator.(validations.Required)
+	if value == nil {
+		return !required
+	}
+	if required && nullable {
+		return true
+	}
+
+	switch validator.(type) {
+	case validations.Match, validations.Email:
+		// NOTE: goar's Match panics for non-string values
+		if _, ok := value.(string); !ok {
+			return false
+		}
+	}
+	return validator.IsSatisfied(value)
+}
+
+// isNullable => true for null.* (i.e., driver.Valuer) and pointer types
+func isNullable(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr || t.Implements(valuerType)
+}
+
+// validationValue => the value goar validators expect, EX: null.Int => int, nil if null or ""
+func validationValue(v reflect.Value) interface{} {
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return nil
+		}
+		v = reflect.ValueOf(value)
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint())
+	case reflect.String:
+		if v.Len() == 0 {
+			return nil
+		}
+		return v.String()
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	}
+	return v.Interface()
+}
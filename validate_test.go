@@ -0,0 +1,207 @@
+package gsonapi
+
+import (
+	"reflect"
+
+	validations "github.com/obieq/goar-validations"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Van Resource (w/ validate tags)
+type VanResource struct {
+	Resource `jsonapi:"-"`
+	Year     null.Int      `json:"year,omitempty" jsonapi:"name=year" validate:"required,range=1900|2016"`
+	Make     null.String   `json:"make,omitempty" jsonapi:"name=make" validate:"required,min-size=2,max-size=20"`
+	Vin      *string       `json:"vin,omitempty" jsonapi:"name=vin" validate:"length=17,match=^[A-Z0-9]{1,17}$"`
+	Email    string        `json:"email,omitempty" jsonapi:"name=email" validate:"email"`
+	Seats    int           `json:"seats,omitempty" jsonapi:"name=seats" validate:"min=2,max=15"`
+	Ages     []interface{} `json:"ages,omitempty" jsonapi:"name=ages" validate:"max-size=3"`
+}
+
+func (r VanResource) GetName() string {
+	return "vans"
+}
+
+func (r *VanResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *VanResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+// Pickup Resource (w/ required null types)
+type PickupResource struct {
+	Resource `jsonapi:"-"`
+	Active   null.Bool `json:"active,omitempty" jsonapi:"name=active" validate:"required"`
+	Doors    null.Int  `json:"doors,omitempty" jsonapi:"name=doors" validate:"required"`
+}
+
+func (r PickupResource) GetName() string {
+	return "pickups"
+}
+
+func (r *PickupResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *PickupResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+// BadTagResource => a resource w/ a nested attribute whose validate tag is invalid
+type BadTagResource struct {
+	Resource `jsonapi:"-"`
+	Stops    []BadTagStop `json:"stops" jsonapi:"name=stops"`
+}
+
+type BadTagStop struct {
+	Minutes int `json:"minutes" validate:"min=soon"`
+}
+
+func (r BadTagResource) GetName() string {
+	return "bad-tags"
+}
+
+func (r *BadTagResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *BadTagResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+var _ = Describe("Validate", func() {
+	keys := func(errors []JsonApiError) []string {
+		k := []string{}
+		for _, e := range errors {
+			k = append(k, e.Source.Pointer)
+		}
+		return k
+	}
+
+	Context("GetValidators", func() {
+		field := func(name string) reflect.StructField {
+			f, _ := reflect.TypeOf(VanResource{}).FieldByName(name)
+			return f
+		}
+
+		It("should parse the validate tag in order", func() {
+			validators, err := GetValidators(field("Year"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(validators).Should(HaveLen(2))
+			Ω(validators[0]).Should(BeAssignableToTypeOf(validations.Required{}))
+			Ω(validators[1]).Should(Equal(validations.ValidRange(1900, 2016)))
+
+			validators, _ = GetValidators(field("Make"))
+			Ω(validators).Should(Equal([]validations.Validator{validations.ValidRequired(),
+				validations.ValidMinSize(2), validations.ValidMaxSize(20)}))
+		})
+
+		It("should keep the remainder of the tag as the match expression", func() {
+			validators, _ := GetValidators(field("Vin"))
+			Ω(validators).Should(HaveLen(2))
+			Ω(validators[1].(validations.Match).Regexp.String()).Should(Equal("^[A-Z0-9]{1,17}$"))
+		})
+
+		It("should return no validators w/o a validate tag", func() {
+			f, _ := reflect.TypeOf(AutomobileResource{}).FieldByName("Year")
+			Ω(GetValidators(f)).Should(BeEmpty())
+		})
+
+		It("should return an error for an invalid rule or regular expression", func() {
+			type bad struct {
+				Year int    `validate:"max=new"`
+				Vin  string `validate:"match=^[A-Z"`
+			}
+			f, _ := reflect.TypeOf(bad{}).FieldByName("Year")
+			_, err := GetValidators(f)
+			Ω(err).Should(MatchError("gson api validate tag error: Year max=new"))
+
+			f, _ = reflect.TypeOf(bad{}).FieldByName("Vin")
+			_, err = GetValidators(f)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should parse the validate tags of a type once", func() {
+			validators, err := validatorsOf(reflect.TypeOf(VanResource{}))
+			Ω(err).ShouldNot(HaveOccurred())
+			again, _ := validatorsOf(reflect.TypeOf(VanResource{}))
+			Ω(reflect.ValueOf(again).Pointer()).Should(Equal(reflect.ValueOf(validators).Pointer()))
+		})
+
+		It("should report invalid validate tags when a resource is registered instead of when it is validated", func() {
+			Ω(func() { RegisterResource(BadTagResource{}) }).Should(Panic())
+			_, ok := NewResource("bad-tags")
+			Ω(ok).Should(BeFalse())
+
+			errors := ValidateResource(ActionCreate, &BadTagResource{Stops: []BadTagStop{{Minutes: 5}}})
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("500"))
+		})
+	})
+
+	Context("ValidateResource", func() {
+		It("should pass a valid resource", func() {
+			vin := "1HGCM82633A004352"
+			r := VanResource{Year: null.IntFrom(2015), Make: null.StringFrom("Ford"), Vin: &vin,
+				Email: "a@b.com", Seats: 8, Ages: []interface{}{30}}
+			Ω(ValidateResource(ActionCreate, &r)).Should(BeEmpty())
+			Ω(r.Errors()).Should(BeEmpty())
+		})
+
+		It("should set one error per invalid attribute, in declaration order", func() {
+			vin := "1hgcm82633a004352"
+			r := VanResource{Year: null.IntFrom(2020), Vin: &vin, Email: "nope", Seats: 1,
+				Ages: []interface{}{1, 2, 3, 4}}
+
+			errors := ValidateResource(ActionCreate, &r)
+			Ω(keys(errors)).Should(Equal([]string{"/data/attributes/year", "/data/attributes/make",
+				"/data/attributes/vin", "/data/attributes/email", "/data/attributes/seats", "/data/attributes/ages"}))
+			Ω(errors[0].Status).Should(Equal("422"))
+			Ω(errors[0].Detail).Should(Equal("Range is 1900 to 2016"))
+			Ω(errors[1].Detail).Should(Equal("Required"))
+			Ω(errors[0].Code).Should(Equal("range"))
+			Ω(errors[0].Meta).Should(Equal(map[string]interface{}{"min": 1900, "max": 2016}))
+			Ω(errors[1].Code).Should(Equal("required"))
+			Ω(r.Errors()).Should(Equal(errors))
+		})
+
+		It("should only check required for null attributes", func() {
+			r := VanResource{Year: null.IntFrom(2000), Make: null.StringFrom("Ford"), Seats: 2}
+			Ω(ValidateResource(ActionCreate, &r)).Should(BeEmpty())
+		})
+
+		It("should only require null types to be set, i.e., accept false and 0", func() {
+			r := PickupResource{Active: null.BoolFrom(false), Doors: null.IntFrom(0)}
+			Ω(ValidateResource(ActionCreate, &r)).Should(BeEmpty())
+
+			errors := ValidateResource(ActionCreate, &PickupResource{})
+			Ω(keys(errors)).Should(Equal([]string{"/data/attributes/active", "/data/attributes/doors"}))
+		})
+
+		It("should validate a request before it is mapped to a model", func() {
+			r := VanResource{}
+			body := []byte(`{"data":{"type":"vans","attributes":{"year":1850,"make":"F","seats":4}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionCreate, body, &r)
+			Ω(keys(errors)).Should(Equal([]string{"/data/attributes/year", "/data/attributes/make"}))
+			Ω(errors[1].Detail).Should(Equal("Minimum size is 2"))
+		})
+
+		It("should skip attributes absent from a PATCH request", func() {
+			r := VanResource{}
+			body := []byte(`{"data":{"type":"vans","id":"1","attributes":{"seats":20}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)
+			Ω(keys(errors)).Should(Equal([]string{"/data/attributes/seats"}))
+		})
+
+		It("should fail required for attributes explicitly nulled by a PATCH request", func() {
+			r := VanResource{}
+			body := []byte(`{"data":{"type":"vans","id":"1","attributes":{"make":null}}}`)
+			errors := UnmarshalRequest(TEST_SERVER_INFO, ActionUpdate, body, &r)
+			Ω(keys(errors)).Should(Equal([]string{"/data/attributes/make"}))
+		})
+	})
+})
@@ -0,0 +1,196 @@
+package gsonapi
+
+import (
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-martini/martini"
+)
+
+// VERSION_PLACEHOLDER => replaced w/ the request's version name in JSONApiServerInfo's Prefix
+// EX: Prefix "{version}" => "v2"
+const VERSION_PLACEHOLDER = "{version}"
+
+// Transformer => converts a resource type's attributes between the internal resource
+// and a version's wire format
+type Transformer interface {
+	// TransformOutgoing => internal attributes => wire attributes
+	TransformOutgoing(attributes map[string]interface{})
+	// TransformIncoming => wire attributes => internal attributes
+	TransformIncoming(attributes map[string]interface{}) error
+}
+
+// AttributeTransformer => a Transformer that renames, adds and drops attributes
+type AttributeTransformer struct {
+	Rename map[string]string                                              // internal name => wire name
+	Add    map[string]func(attributes map[string]interface{}) interface{} // wire only attributes, computed from the internal ones
+	Drop   []string                                                       // internal attributes that do not exist on the wire
+}
+
+func (t AttributeTransformer) TransformOutgoing(attributes map[string]interface{}) {
+	if attributes == nil {
+		return
+	}
+
+	computed := map[string]interface{}{}
+	for name, compute := range t.Add {
+		computed[name] = compute(attributes)
+	}
+	for _, name := range t.Drop {
+		delete(attributes, name)
+	}
+	for internal, wire := range t.Rename {
+		if v, ok := attributes[internal]; ok {
+			delete(attributes, internal)
+			attributes[wire] = v
+		}
+	}
+	for name, v := range computed {
+		attributes[name] = v
+	}
+}
+
+func (t AttributeTransformer) TransformIncoming(attributes map[string]interface{}) error {
+	if attributes == nil {
+		return nil
+	}
+
+	for _, name := range t.Drop {
+		if _, ok := attributes[name]; ok {
+			return UnknownAttributeError(name)
+		}
+	}
+	for name := range t.Add {
+		delete(attributes, name)
+	}
+	for internal, wire := range t.Rename {
+		if v, ok := attributes[wire]; ok {
+			delete(attributes, wire)
+			attributes[internal] = v
+		}
+	}
+	return nil
+}
+
+// UnknownAttributeError => returned by a Transformer for an attribute that does not exist in its version
+type UnknownAttributeError string
+
+func (e UnknownAttributeError) Error() string {
+	return "attribute " + string(e) + " does not exist"
+}
+
+// Version => a version of the api that can be served side by side w/ other versions
+type Version struct {
+	Name         string                 // url prefix, EX: v1
+	Profile      string                 // media type profile, EX: https://carz.com/profiles/v1
+	Deprecated   time.Time              // when set, responses include a Deprecation header
+	Sunset       time.Time              // when set, responses include a Sunset header
+	Transformers map[string]Transformer // keyed by resource type
+}
+
+// versions => registered versions
+var versions = []*Version{}
+
+// RegisterVersion => registers a version of the api (call during app startup)
+func RegisterVersion(v *Version) {
+	versions = append(versions, v)
+}
+
+// VersionFor => selects the version by the request's url prefix, or else by the profile of its Accept header
+// EX: /v2/automobiles or Accept: application/vnd.api+json; profile="https://carz.com/profiles/v2"
+func VersionFor(req *http.Request) *Version {
+	prefix := strings.Split(strings.Trim(req.URL.Path, "/"), "/")[0]
+	for _, v := range versions {
+		if v.Name != "" && v.Name == prefix {
+			return v
+		}
+	}
+
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil || mediaType != "application/vnd.api+json" {
+			continue
+		}
+		for _, profile := range strings.Fields(params["profile"]) {
+			for _, v := range versions {
+				if v.Profile != "" && v.Profile == profile {
+					return v
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// VersionHandler => martini middleware that maps the request's *Version (nil if none matched),
+// as well as the JSONApiServerInfo mapped before it w/ its Version set (or a new one if none was mapped),
+// and sets the Deprecation and Sunset headers of deprecated versions
+func VersionHandler() martini.Handler {
+	return func(c martini.Context, req *http.Request, w http.ResponseWriter) {
+		v := VersionFor(req)
+		if v != nil {
+			if !v.Deprecated.IsZero() {
+				w.Header().Set("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+			}
+			if !v.Sunset.IsZero() {
+				w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			}
+		}
+
+		jasi := JSONApiServerInfo{}
+		if mapped := c.Get(reflect.TypeOf(jasi)); mapped.IsValid() {
+			jasi = mapped.Interface().(JSONApiServerInfo)
+		}
+		jasi.Version = v
+
+		c.Map(v)
+		c.Map(jasi)
+	}
+}
+
+// transformOutgoing => applies the version's transformers to a marshalled response
+func (v *Version) transformOutgoing(response interface{}) {
+	if v == nil {
+		return
+	}
+
+	for _, entry := range responseEntries(response) {
+		t, _ := entry["type"].(string)
+		if transformer, ok := v.Transformers[t]; ok {
+			attributes, _ := entry["attributes"].(map[string]interface{})
+			transformer.TransformOutgoing(attributes)
+		}
+	}
+}
+
+// transformIncoming => applies the version's transformer to a request's attributes
+func (v *Version) transformIncoming(resourceType string, attributes map[string]interface{}) *JsonApiError {
+	if v == nil {
+		return nil
+	}
+
+	if transformer, ok := v.Transformers[resourceType]; ok {
+		if err := transformer.TransformIncoming(attributes); err != nil {
+			pointer := "/data/attributes"
+			if name, ok := err.(UnknownAttributeError); ok {
+				pointer += "/" + pointerToken(formatKey(string(name)))
+			}
+			return &JsonApiError{Status: "400", Title: "Invalid Attribute", Detail: err.Error(),
+				Source: &JsonApiErrorSource{Pointer: pointer}}
+		}
+	}
+	return nil
+}
+
+// name => the version's name, or blank for a nil version
+func (v *Version) name() string {
+	if v == nil {
+		return ""
+	}
+	return v.Name
+}
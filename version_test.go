@@ -0,0 +1,160 @@
+package gsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/guregu/null.v3"
+)
+
+var _ = Describe("Version", func() {
+	var (
+		server   *martini.ClassicMartini
+		recorder *httptest.ResponseRecorder
+		v1, v2   *Version
+		sunset   time.Time
+	)
+
+	BeforeEach(func() {
+		sunset = time.Date(2016, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+		// v1 calls make "manufacturer", doesn't know about body styles and has a computed "classic" attribute
+		v1 = &Version{Name: "v1", Profile: "https://carz.com/profiles/v1",
+			Deprecated: time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC), Sunset: sunset,
+			Transformers: map[string]Transformer{"automobiles": AttributeTransformer{
+				Rename: map[string]string{"make": "manufacturer"},
+				Drop:   []string{"body-style", "ages", "inspections"},
+				Add: map[string]func(map[string]interface{}) interface{}{
+					"classic": func(attributes map[string]interface{}) interface{} {
+						year, _ := attributes["year"].(float64)
+						return year < 1970
+					},
+				},
+			}}}
+		v2 = &Version{Name: "v2", Profile: "https://carz.com/profiles/v2"}
+		RegisterVersion(v1)
+		RegisterVersion(v2)
+
+		server = martini.Classic()
+		server.Use(render.Renderer())
+		server.Use(VersionHandler())
+		recorder = httptest.NewRecorder()
+
+		handler := func(v *Version, r render.Render) {
+			auto := AutomobileResource{Year: null.IntFrom(1960), Make: null.StringFrom("Austin-Healey"),
+				BodyStyle: null.StringFrom("roadster"), Active: null.BoolFrom(true)}
+			auto.SetID("cccc-3333-dddd-4444")
+			HandleGetResponse(JSONApiServerInfo{BaseURL: "http://my.domain", Prefix: "{version}", Version: v}, nil, auto, r)
+		}
+		server.Get("/v1/automobiles/:id", handler)
+		server.Get("/v2/automobiles/:id", handler)
+		server.Get("/automobiles/:id", handler)
+	})
+
+	AfterEach(func() {
+		versions = []*Version{}
+	})
+
+	Context("Selection", func() {
+		It("should select the version by url prefix", func() {
+			request, _ := http.NewRequest("GET", "/v2/automobiles/1", nil)
+			Ω(VersionFor(request)).Should(Equal(v2))
+		})
+
+		It("should select the version by media type profile", func() {
+			request, _ := http.NewRequest("GET", "/automobiles/1", nil)
+			request.Header.Set("Accept", `text/html, application/vnd.api+json; profile="https://carz.com/profiles/v1"`)
+			Ω(VersionFor(request)).Should(Equal(v1))
+		})
+
+		It("should not select a version when nothing matches", func() {
+			request, _ := http.NewRequest("GET", "/automobiles/1", nil)
+			Ω(VersionFor(request)).Should(BeNil())
+		})
+	})
+
+	Context("Serving", func() {
+		It("should transform the attributes of a deprecated version", func() {
+			request, _ := http.NewRequest("GET", "/v1/automobiles/cccc-3333-dddd-4444", nil)
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(200))
+			Ω(recorder.Header().Get("Deprecation")).Should(Equal("@1451606400"))
+			Ω(recorder.Header().Get("Sunset")).Should(Equal("Wed, 01 Jun 2016 00:00:00 GMT"))
+			Ω(recorder.Body.String()).Should(MatchJSON(`{"data":{"type":"automobiles","id":"cccc-3333-dddd-4444",` +
+				`"attributes":{"active":true,"classic":true,"manufacturer":"Austin-Healey","year":1960},` +
+				`"relationships":{"drivers":{"data":[],"links":{` +
+				`"related":"http://my.domain/v1/automobiles/cccc-3333-dddd-4444/drivers",` +
+				`"self":"http://my.domain/v1/automobiles/cccc-3333-dddd-4444/relationships/drivers"}}}}}`))
+		})
+
+		It("should set the Version of the JSONApiServerInfo mapped before it", func() {
+			server := martini.Classic()
+			server.Use(func(c martini.Context) {
+				c.Map(JSONApiServerInfo{BaseURL: "http://my.domain", Prefix: "{version}"})
+			})
+			server.Use(VersionHandler())
+			server.Get("/v1/automobiles/:id", func(jasi JSONApiServerInfo, v *Version) string {
+				Ω(jasi.Version).Should(Equal(v1))
+				Ω(jasi.Version).Should(Equal(v))
+				return jasi.GetBaseURL() + "/" + jasi.GetPrefix()
+			})
+
+			request, _ := http.NewRequest("GET", "/v1/automobiles/cccc-3333-dddd-4444", nil)
+			server.ServeHTTP(recorder, request)
+			Ω(recorder.Code).Should(Equal(200))
+			Ω(recorder.Body.String()).Should(Equal("http://my.domain/v1"))
+		})
+
+		It("should serve the internal attributes of a version without transformers", func() {
+			request, _ := http.NewRequest("GET", "/v2/automobiles/cccc-3333-dddd-4444", nil)
+			server.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(200))
+			Ω(recorder.Header().Get("Deprecation")).Should(BeEmpty())
+			Ω(recorder.Body.String()).Should(ContainSubstring(`"make":"Austin-Healey"`))
+			Ω(recorder.Body.String()).Should(ContainSubstring(`"body-style":"roadster"`))
+			Ω(recorder.Body.String()).Should(ContainSubstring(`http://my.domain/v2/automobiles`))
+		})
+	})
+
+	Context("Requests", func() {
+		It("should transform the incoming attributes", func() {
+			resource := AutomobileResource{}
+			body := []byte(`{"data":{"type":"automobiles","attributes":{"manufacturer":"Mazda","year":2010,"classic":false}}}`)
+
+			Ω(UnmarshalRequest(JSONApiServerInfo{Version: v1}, ActionCreate, body, &resource)).Should(BeEmpty())
+			Ω(resource.Make.String).Should(Equal("Mazda"))
+			Ω(resource.Year.Int64).Should(Equal(int64(2010)))
+		})
+
+		It("should transform the attributes as they were sent before matching them to the resource's names", func() {
+			KeyFormat = CamelCaseKeys
+			defer func() { KeyFormat = DeclaredKeys }()
+
+			resource := AutomobileResource{}
+			body := []byte(`{"data":{"type":"automobiles","attributes":{"manufacturer":"Mazda","bodyStyle":"coupe"}}}`)
+			errors := UnmarshalRequest(JSONApiServerInfo{Version: v1}, ActionCreate, body, &resource)
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/attributes/bodyStyle"))
+
+			body = []byte(`{"data":{"type":"automobiles","attributes":{"manufacturer":"Mazda","year":2010}}}`)
+			Ω(UnmarshalRequest(JSONApiServerInfo{Version: v1}, ActionCreate, body, &resource)).Should(BeEmpty())
+			Ω(resource.Make.String).Should(Equal("Mazda"))
+		})
+
+		It("should return a 400 error for attributes that do not exist in the version", func() {
+			body := []byte(`{"data":{"type":"automobiles","attributes":{"body-style":"coupe"}}}`)
+
+			errors := UnmarshalRequest(JSONApiServerInfo{Version: v1}, ActionCreate, body, &AutomobileResource{})
+			Ω(errors).Should(HaveLen(1))
+			Ω(errors[0].Status).Should(Equal("400"))
+			Ω(errors[0].Source.Pointer).Should(Equal("/data/attributes/body-style"))
+		})
+	})
+})
@@ -0,0 +1,580 @@
+package gsonapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+)
+
+// WEBHOOK_SIGNATURE_HEADER => the hex HMAC-SHA256 of a delivery's body, keyed by the webhook's secret
+// EX: X-Webhook-Signature: sha256=5d5b09f6dcb2d53a5fffc60c4ac0d55fabdf556069d6631545f42aa6e3500f2e
+const WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+
+// WEBHOOK_DELIVERY_HEADER => the id of a delivery, which is the same for each of its attempts
+const WEBHOOK_DELIVERY_HEADER = "X-Webhook-Delivery"
+
+// defaults of the WebhookDispatcher's retries
+const (
+	DEFAULT_WEBHOOK_MAX_ATTEMPTS = 8
+	DEFAULT_WEBHOOK_BACKOFF      = 10 * time.Second
+	DEFAULT_WEBHOOK_MAX_BACKOFF  = time.Hour
+)
+
+// WebhookResource => a webhook subscription, i.e., where the events of which resource types and actions are sent
+// NOTE: subscriptions are configured by config.json's "webhooks" or created through ServeWebhooks;
+// empty Types or Actions match everything but the webhooks' own events and the Secret is write only;
+// a webhook only matches the events of its Tenant, which is the tenant of the request that created it
+type WebhookResource struct {
+	Resource `jsonapi:"-"`
+	URL      string   `json:"url,omitempty" jsonapi:"name=url" validate:"required"`
+	Secret   string   `json:"secret,omitempty" jsonapi:"name=secret;writeonly"`
+	Types    []string `json:"types,omitempty" jsonapi:"name=types"`
+	Actions  []string `json:"actions,omitempty" jsonapi:"name=actions"`
+	Tenant   string   `json:"tenant,omitempty" jsonapi:"name=tenant;readonly"`
+}
+
+func (r WebhookResource) GetName() string {
+	return "webhooks"
+}
+
+func (r WebhookResource) GetTenant() string {
+	return r.Tenant
+}
+
+func (r *WebhookResource) MapToModel(model interface{}) error {
+	return AutoMapToModel(r, model)
+}
+
+func (r *WebhookResource) MapFromModel(model interface{}) error {
+	return AutoMapFromModel(r, model)
+}
+
+func (r *WebhookResource) BeforeCreate(jasi JSONApiServerInfo) *JsonApiError {
+	return r.validate()
+}
+
+func (r *WebhookResource) BeforeUpdate(jasi JSONApiServerInfo) *JsonApiError {
+	return r.validate()
+}
+
+// validate => returns a 422 error if the url is not an absolute http(s) url or an action is unknown
+func (r *WebhookResource) validate() *JsonApiError {
+	if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewError(422).Detail(r.URL + " is not an http(s) url").Pointer(attributePointer("url")).Build()
+	}
+	for _, action := range r.Actions {
+		if action != string(ActionCreate) && action != string(ActionUpdate) && action != string(ActionDelete) {
+			return NewError(422).Detail(action + " is not a webhook action").Pointer(attributePointer("actions")).Build()
+		}
+	}
+	return nil
+}
+
+// matches => true if the webhook subscribes to the event
+// NOTE: the events of other tenants never match and the events of webhooks are only sent to the webhooks
+// whose Types list "webhooks"
+func (r WebhookResource) matches(event Event) bool {
+	if r.Tenant != event.Tenant || len(r.Types) == 0 && event.Type == r.GetName() {
+		return false
+	}
+	filter := EventFilter{Types: r.Types}
+	for _, action := range r.Actions {
+		filter.Actions = append(filter.Actions, Action(action))
+	}
+	return filter.Matches(event)
+}
+
+// ServeWebhooks => serves the index, get, create, update and delete routes of the webhooks stored in the repository
+// EX: ServeWebhooks(m, "/v1/webhooks", NewMemoryRepository(WebhookResource{}))
+// NOTE: the handlers get the request's JSONApiServerInfo from the martini context, i.e., map it in a middleware,
+// so that the "webhooks" Policy can authorize the caller (register one, since AllowAll lets anyone add a webhook)
+func ServeWebhooks(router martini.Router, path string, repository Repository) {
+	router.Get(path, func(jasi JSONApiServerInfo, req *http.Request, r render.Render) {
+		q, err := ParseQuery(req.URL.Query())
+		var records interface{}
+		if err == nil {
+			records, _, err = FindAllWithScope(jasi, "webhooks", repository, q)
+		}
+		HandleIndexResponse(jasi, err, records, r)
+	})
+
+	router.Get(path+"/:id", func(jasi JSONApiServerInfo, params martini.Params, r render.Render) {
+		record, err := repository.FindOne(params["id"])
+		HandleGetResponse(jasi, err, record, r)
+	})
+
+	router.Post(path, func(jasi JSONApiServerInfo, req *http.Request, r render.Render) {
+		if err := Authorize(jasi, ActionCreate, "webhooks"); err != nil {
+			renderError(403, err, r)
+			return
+		}
+		webhook := &WebhookResource{}
+		body, _ := ioutil.ReadAll(req.Body)
+		if errors := UnmarshalRequest(jasi, ActionCreate, body, webhook); len(errors) > 0 {
+			HandleErrorsResponse(errors, r)
+			return
+		}
+		webhook.Tenant = jasi.Tenant
+		err := CreateWithHooks(jasi, webhook, nil, func() *JsonApiError {
+			return repository.Create(webhook)
+		})
+		HandlePostResponse(jasi, err == nil, err, webhook, r)
+	})
+
+	router.Patch(path+"/:id", func(jasi JSONApiServerInfo, params martini.Params, req *http.Request, r render.Render) {
+		record, err := repository.FindOne(params["id"])
+		if err == nil {
+			err = AuthorizeResource(jasi, ActionUpdate, record.(*WebhookResource))
+		}
+		if err != nil {
+			renderError(404, err, r)
+			return
+		}
+		webhook := record.(*WebhookResource)
+		body, _ := ioutil.ReadAll(req.Body)
+		if errors := UnmarshalRequest(jasi, ActionUpdate, body, webhook); len(errors) > 0 {
+			HandleErrorsResponse(errors, r)
+			return
+		}
+		err = UpdateWithHooks(jasi, webhook, nil, func() *JsonApiError {
+			return repository.Update(webhook)
+		})
+		HandlePatchResponse(jasi, err == nil, err, webhook, r)
+	})
+
+	router.Delete(path+"/:id", func(jasi JSONApiServerInfo, params martini.Params, r render.Render) {
+		record, err := repository.FindOne(params["id"])
+		if err == nil {
+			err = AuthorizeResource(jasi, ActionDelete, record.(*WebhookResource))
+		}
+		if err == nil {
+			err = DeleteWithHooks(jasi, record.(*WebhookResource), nil, func() *JsonApiError {
+				return repository.Delete(params["id"])
+			})
+		}
+		HandleDeleteResponse(err, r)
+	})
+}
+
+// DeliveryStatus => the state of a queued webhook delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending => the delivery will be attempted at its NextAttempt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDead => the delivery failed its max attempts, or its webhook was removed, see WebhookDispatcher.Redeliver
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery => an event document that is queued for a webhook
+// NOTE: successful deliveries are removed from the queue
+type WebhookDelivery struct {
+	ID          string          `json:"id"`
+	Webhook     string          `json:"webhook"` // the webhook's id; its url and secret are read when the delivery is attempted
+	Body        json.RawMessage `json:"body"`
+	Status      DeliveryStatus  `json:"status"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next-attempt"`
+	LastError   string          `json:"last-error,omitempty"`
+}
+
+// WebhookDispatcher => sends the events of the webhooks' resource types and actions to their urls,
+// retrying failed deliveries w/ exponential backoff until they are dead
+// NOTE: pending deliveries are persisted in a local file so that they survive restarts
+// EX: d, _ := NewWebhookDispatcher("webhooks.queue", repository); d.Subscribe(Events); defer d.Start(time.Second)()
+type WebhookDispatcher struct {
+	Client      *http.Client
+	MaxAttempts int           // attempts before a delivery is dead
+	Backoff     time.Duration // the delay after the first failed attempt, which doubles after each one
+	MaxBackoff  time.Duration
+	Webhooks    []WebhookResource // the webhooks of config.json's "webhooks"
+	Repository  Repository        // the webhooks created through ServeWebhooks, or nil
+
+	queue      *webhookQueue
+	delivering sync.Mutex
+	now        func() time.Time
+}
+
+// NewWebhookDispatcher => a dispatcher of the configured webhooks and those of the repository (which may be nil),
+// w/ the deliveries that are still queued in the file
+func NewWebhookDispatcher(queuePath string, repository Repository) (*WebhookDispatcher, error) {
+	queue, err := openWebhookQueue(queuePath)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &WebhookDispatcher{
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		MaxAttempts: DEFAULT_WEBHOOK_MAX_ATTEMPTS,
+		Backoff:     DEFAULT_WEBHOOK_BACKOFF,
+		MaxBackoff:  DEFAULT_WEBHOOK_MAX_BACKOFF,
+		Repository:  repository,
+		queue:       queue,
+		now:         time.Now,
+	}
+	for _, c := range Config.Webhooks {
+		webhook := WebhookResource{URL: c.URL, Secret: c.Secret, Types: c.Types, Actions: c.Actions, Tenant: c.Tenant}
+		webhook.SetID(c.ID)
+		d.Webhooks = append(d.Webhooks, webhook)
+	}
+	return d, nil
+}
+
+// Subscribe => queues the deliveries of the bus' events, see Enqueue
+// NOTE: queueing errors are logged since they cannot fail the request that published the event
+func (d *WebhookDispatcher) Subscribe(bus *EventBus) (unsubscribe func()) {
+	return bus.Subscribe(EventFilter{}, func(event Event) {
+		if err := d.Enqueue(event); err != nil {
+			log.Println("gson api webhooks error:", err)
+		}
+	})
+}
+
+// Enqueue => queues a delivery of the event's document for each webhook that subscribes to it
+func (d *WebhookDispatcher) Enqueue(event Event) error {
+	webhooks, err := d.webhooks()
+	if err != nil {
+		return err
+	}
+
+	id := randomID()
+	body, _ := json.Marshal(WebhookDocument(id, event))
+	deliveries := []WebhookDelivery{}
+	for _, webhook := range webhooks {
+		if webhook.matches(event) {
+			deliveries = append(deliveries, WebhookDelivery{
+				ID:          randomID(),
+				Webhook:     webhook.GetID(),
+				Body:        body,
+				Status:      DeliveryPending,
+				NextAttempt: d.now(),
+			})
+		}
+	}
+	return d.queue.add(deliveries...)
+}
+
+// Deliver => attempts the pending deliveries that are due and returns the number of successful ones
+func (d *WebhookDispatcher) Deliver() (int, error) {
+	d.delivering.Lock()
+	defer d.delivering.Unlock()
+
+	delivered := 0
+	for _, delivery := range d.queue.due(d.now()) {
+		webhook, err := d.webhook(delivery.Webhook)
+		if err != nil {
+			return delivered, err
+		}
+		if webhook == nil {
+			err = fmt.Errorf("webhook %s does not exist", delivery.Webhook)
+		} else {
+			err = d.send(webhook, delivery)
+		}
+
+		if err == nil {
+			delivered++
+			err = d.queue.remove(delivery.ID)
+		} else {
+			delivery.Attempts++
+			delivery.LastError = err.Error()
+			if webhook == nil || delivery.Attempts >= d.MaxAttempts {
+				delivery.Status = DeliveryDead
+			} else {
+				delivery.NextAttempt = d.now().Add(d.backoff(delivery.Attempts))
+			}
+			err = d.queue.update(delivery)
+		}
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// Start => attempts the due deliveries every interval until stop is called
+func (d *WebhookDispatcher) Start(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := d.Deliver(); err != nil {
+					log.Println("gson api webhooks error:", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Deliveries => the queued deliveries w/ the status
+func (d *WebhookDispatcher) Deliveries(status DeliveryStatus) []WebhookDelivery {
+	return d.queue.list(status)
+}
+
+// Redeliver => moves a dead delivery back to pending, w/ its attempts reset
+func (d *WebhookDispatcher) Redeliver(id string) error {
+	for _, delivery := range d.queue.list(DeliveryDead) {
+		if delivery.ID == id {
+			delivery.Status = DeliveryPending
+			delivery.Attempts = 0
+			delivery.NextAttempt = d.now()
+			return d.queue.update(delivery)
+		}
+	}
+	return fmt.Errorf("delivery %s is not dead", id)
+}
+
+// webhooks => the configured webhooks and those of the repository
+func (d *WebhookDispatcher) webhooks() ([]WebhookResource, error) {
+	webhooks := append([]WebhookResource{}, d.Webhooks...)
+	if d.Repository == nil {
+		return webhooks, nil
+	}
+	records, _, err := d.Repository.FindAll(&Query{})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range primaryValues(records) {
+		if webhook, ok := v.(WebhookResource); ok {
+			webhooks = append(webhooks, webhook)
+		} else if webhook, ok := v.(*WebhookResource); ok {
+			webhooks = append(webhooks, *webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// webhook => the webhook w/ the id, or nil if it no longer exists
+func (d *WebhookDispatcher) webhook(id string) (*WebhookResource, error) {
+	webhooks, err := d.webhooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		if webhook.GetID() == id {
+			return &webhook, nil
+		}
+	}
+	return nil, nil
+}
+
+// send => posts the delivery's body to the webhook's url and returns an error unless the response is 2xx
+func (d *WebhookDispatcher) send(webhook *WebhookResource, delivery WebhookDelivery) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", JSONAPI_MEDIA_TYPE)
+	req.Header.Set(WEBHOOK_DELIVERY_HEADER, delivery.ID)
+	req.Header.Set(WEBHOOK_SIGNATURE_HEADER, SignWebhook(webhook.Secret, delivery.Body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	ioutil.ReadAll(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", webhook.URL, res.Status)
+	}
+	return nil
+}
+
+// backoff => the delay after the attempts, i.e., Backoff * 2^(attempts-1) up to MaxBackoff
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.Backoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// SignWebhook => the value of the WEBHOOK_SIGNATURE_HEADER of a body, i.e., sha256= and its hex HMAC-SHA256
+// NOTE: receivers should compare it w/ their own signature of the raw body using hmac.Equal
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDocument => the JSON:API document of an event, whose resource is related to it
+// EX: {"data": {"type": "events", "id": "...", "attributes": {"action": "update", "changes": {...}, ...},
+// "relationships": {"resource": {"data": {"type": "drivers", "id": "1"}}}}}
+// NOTE: the changes of write only or role hidden attributes are left out, e.g., a webhook's secret
+func WebhookDocument(id string, event Event) map[string]interface{} {
+	var permissions FieldPermissions
+	if event.Type == (WebhookResource{}).GetName() {
+		permissions = GetFieldPermissions(WebhookResource{})
+	} else if resource, ok := NewResource(event.Type); ok {
+		permissions = GetFieldPermissions(resource)
+	}
+	formatChanges := func(changes map[string]Change) map[string]interface{} {
+		formatted := map[string]interface{}{}
+		for name, change := range changes {
+			if p, ok := permissions[name]; ok && (p.WriteOnly || len(p.HiddenFor) > 0) {
+				continue
+			}
+			formatted[formatKey(name)] = change
+		}
+		return formatted
+	}
+
+	attributes := map[string]interface{}{
+		formatKey("action"):      event.Action,
+		formatKey("occurred-at"): event.Time,
+		formatKey("request-id"):  event.RequestID,
+		formatKey("changes"): map[string]interface{}{
+			formatKey("attributes"):    formatChanges(event.Attributes),
+			formatKey("relationships"): formatChanges(event.Relationships),
+		},
+	}
+	relationships := map[string]interface{}{
+		formatKey("resource"): map[string]interface{}{
+			"data": map[string]interface{}{"type": event.Type, "id": event.ID},
+		},
+	}
+	// NOTE: only an actor that is a resource or a name is included, since a caller may hold credentials
+	switch actor := event.Actor.(type) {
+	case string:
+		attributes[formatKey("actor")] = actor
+	case interface{ GetID() string }:
+		relationships[formatKey("actor")] = map[string]interface{}{
+			"data": map[string]interface{}{"type": resourceType(actor), "id": actor.GetID()},
+		}
+	}
+
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":          "events",
+			"id":            id,
+			"attributes":    attributes,
+			"relationships": relationships,
+		},
+	}
+}
+
+// webhookQueue => the deliveries, persisted as a json array in a file that is rewritten after each change
+type webhookQueue struct {
+	mutex      sync.Mutex
+	path       string
+	deliveries []WebhookDelivery
+}
+
+// openWebhookQueue => the queue of the file, which is created if it does not exist
+func openWebhookQueue(path string) (*webhookQueue, error) {
+	q := &webhookQueue{path: path, deliveries: []WebhookDelivery{}}
+
+	j, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, q.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(j, &q.deliveries); err != nil {
+		return nil, fmt.Errorf("webhook queue %s is corrupt: %v", path, err)
+	}
+	return q, nil
+}
+
+func (q *webhookQueue) add(deliveries ...WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.deliveries = append(q.deliveries, deliveries...)
+	return q.save()
+}
+
+func (q *webhookQueue) update(delivery WebhookDelivery) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, d := range q.deliveries {
+		if d.ID == delivery.ID {
+			q.deliveries[i] = delivery
+		}
+	}
+	return q.save()
+}
+
+func (q *webhookQueue) remove(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, d := range q.deliveries {
+		if d.ID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	q.deliveries = deliveries
+	return q.save()
+}
+
+// due => the pending deliveries whose next attempt is not after now, in queued order
+func (q *webhookQueue) due(now time.Time) []WebhookDelivery {
+	due := []WebhookDelivery{}
+	for _, d := range q.list(DeliveryPending) {
+		if !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	return due
+}
+
+func (q *webhookQueue) list(status DeliveryStatus) []WebhookDelivery {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, d := range q.deliveries {
+		if d.Status == status {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries
+}
+
+// save => writes the deliveries to a temporary file and renames it, so that the file is never partially written
+func (q *webhookQueue) save() error {
+	j, err := json.Marshal(q.deliveries)
+	if err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, j, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
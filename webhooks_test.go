@@ -0,0 +1,322 @@
+package gsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhooks", func() {
+	var (
+		dir        string
+		dispatcher *WebhookDispatcher
+		receiver   *httptest.Server
+		received   []*http.Request
+		bodies     [][]byte
+		status     int
+		now        time.Time
+	)
+
+	lotCreated := Event{Type: "lots", ID: "lot-1", Action: ActionCreate, Actor: "admin", RequestID: "req-1",
+		Attributes: map[string]Change{"name": {After: "North"}}}
+
+	BeforeEach(func() {
+		received, bodies, status = nil, nil, 200
+		receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			received = append(received, req)
+			bodies = append(bodies, body)
+			w.WriteHeader(status)
+		}))
+
+		var err error
+		dir, err = ioutil.TempDir("", "gsonapi")
+		Ω(err).ShouldNot(HaveOccurred())
+		dispatcher, err = NewWebhookDispatcher(filepath.Join(dir, "webhooks.queue"), nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		now = time.Now()
+		dispatcher.now = func() time.Time { return now }
+		dispatcher.MaxAttempts = 3
+		dispatcher.Backoff = time.Second
+
+		webhook := WebhookResource{URL: receiver.URL, Secret: "secret", Types: []string{"lots"}}
+		webhook.SetID("w1")
+		dispatcher.Webhooks = []WebhookResource{webhook}
+	})
+
+	AfterEach(func() {
+		receiver.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should load the webhooks of config.json", func() {
+		webhooks := Config.Webhooks
+		defer func() { Config.Webhooks = webhooks }()
+		Config.Webhooks = []WebhookConfig{{ID: "audit", URL: "https://audit.example.com/events", Secret: "secret",
+			Types: []string{"automobiles"}, Tenant: "acme"}}
+
+		d, err := NewWebhookDispatcher(filepath.Join(dir, "config.queue"), nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(d.Webhooks).Should(HaveLen(1))
+		Ω(d.Webhooks[0].GetID()).Should(Equal("audit"))
+		Ω(d.Webhooks[0].Secret).Should(Equal("secret"))
+		Ω(d.Webhooks[0].Types).Should(Equal([]string{"automobiles"}))
+		Ω(d.Webhooks[0].Tenant).Should(Equal("acme"))
+	})
+
+	It("should deliver a signed JSON:API document of the event", func() {
+		Ω(dispatcher.Enqueue(lotCreated)).Should(Succeed())
+		Ω(dispatcher.Enqueue(Event{Type: "drivers", ID: "1", Action: ActionCreate})).Should(Succeed())
+		Ω(dispatcher.Deliveries(DeliveryPending)).Should(HaveLen(1))
+
+		delivered, err := dispatcher.Deliver()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(delivered).Should(Equal(1))
+		Ω(dispatcher.Deliveries(DeliveryPending)).Should(BeEmpty())
+
+		Ω(received).Should(HaveLen(1))
+		Ω(received[0].Header.Get("Content-Type")).Should(Equal(JSONAPI_MEDIA_TYPE))
+		Ω(received[0].Header.Get(WEBHOOK_DELIVERY_HEADER)).ShouldNot(BeEmpty())
+		Ω(received[0].Header.Get(WEBHOOK_SIGNATURE_HEADER)).Should(Equal(SignWebhook("secret", bodies[0])))
+		Ω(SignWebhook("secret", bodies[0])).ShouldNot(Equal(SignWebhook("other", bodies[0])))
+
+		doc := map[string]interface{}{}
+		Ω(json.Unmarshal(bodies[0], &doc)).Should(Succeed())
+		data := doc["data"].(map[string]interface{})
+		Ω(data["type"]).Should(Equal("events"))
+		Ω(data["id"]).ShouldNot(BeEmpty())
+		attributes := data["attributes"].(map[string]interface{})
+		Ω(attributes["action"]).Should(Equal("create"))
+		Ω(attributes["actor"]).Should(Equal("admin"))
+		Ω(attributes["request-id"]).Should(Equal("req-1"))
+		Ω(attributes["changes"]).Should(Equal(map[string]interface{}{
+			"attributes":    map[string]interface{}{"name": map[string]interface{}{"before": nil, "after": "North"}},
+			"relationships": map[string]interface{}{},
+		}))
+		Ω(data["relationships"]).Should(Equal(map[string]interface{}{
+			"resource": map[string]interface{}{"data": map[string]interface{}{"type": "lots", "id": "lot-1"}},
+		}))
+	})
+
+	It("should retry failed deliveries w/ exponential backoff until they are dead", func() {
+		status = 500
+		Ω(dispatcher.Enqueue(lotCreated)).Should(Succeed())
+
+		dispatcher.Deliver()
+		pending := dispatcher.Deliveries(DeliveryPending)
+		Ω(pending).Should(HaveLen(1))
+		Ω(pending[0].Attempts).Should(Equal(1))
+		Ω(pending[0].LastError).Should(ContainSubstring("500 Internal Server Error"))
+		Ω(pending[0].NextAttempt).Should(Equal(now.Add(time.Second)))
+
+		dispatcher.Deliver()
+		Ω(received).Should(HaveLen(1))
+
+		now = now.Add(time.Second)
+		dispatcher.Deliver()
+		Ω(received).Should(HaveLen(2))
+		Ω(dispatcher.Deliveries(DeliveryPending)[0].NextAttempt).Should(Equal(now.Add(2 * time.Second)))
+
+		now = now.Add(2 * time.Second)
+		dispatcher.Deliver()
+		Ω(received).Should(HaveLen(3))
+		Ω(dispatcher.Deliveries(DeliveryPending)).Should(BeEmpty())
+		dead := dispatcher.Deliveries(DeliveryDead)
+		Ω(dead).Should(HaveLen(1))
+		Ω(dead[0].Attempts).Should(Equal(3))
+
+		status = 204
+		Ω(dispatcher.Redeliver(dead[0].ID)).Should(Succeed())
+		Ω(dispatcher.Redeliver(dead[0].ID)).ShouldNot(Succeed())
+		delivered, _ := dispatcher.Deliver()
+		Ω(delivered).Should(Equal(1))
+		Ω(dispatcher.Deliveries(DeliveryDead)).Should(BeEmpty())
+		Ω(received[3].Header.Get(WEBHOOK_DELIVERY_HEADER)).Should(Equal(received[0].Header.Get(WEBHOOK_DELIVERY_HEADER)))
+	})
+
+	It("should cap the backoff", func() {
+		dispatcher.MaxBackoff = 5 * time.Second
+		Ω(dispatcher.backoff(1)).Should(Equal(time.Second))
+		Ω(dispatcher.backoff(3)).Should(Equal(4 * time.Second))
+		Ω(dispatcher.backoff(4)).Should(Equal(5 * time.Second))
+		Ω(dispatcher.backoff(40)).Should(Equal(5 * time.Second))
+	})
+
+	It("should kill the deliveries of removed webhooks", func() {
+		Ω(dispatcher.Enqueue(lotCreated)).Should(Succeed())
+		dispatcher.Webhooks = nil
+
+		dispatcher.Deliver()
+		Ω(received).Should(BeEmpty())
+		dead := dispatcher.Deliveries(DeliveryDead)
+		Ω(dead).Should(HaveLen(1))
+		Ω(dead[0].LastError).Should(Equal("webhook w1 does not exist"))
+	})
+
+	It("should persist the queue", func() {
+		status = 500
+		Ω(dispatcher.Enqueue(lotCreated)).Should(Succeed())
+		dispatcher.Deliver()
+
+		reopened, err := NewWebhookDispatcher(filepath.Join(dir, "webhooks.queue"), nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		pending := reopened.Deliveries(DeliveryPending)
+		Ω(pending).Should(HaveLen(1))
+		Ω(pending[0].Attempts).Should(Equal(1))
+		Ω(pending[0].ID).Should(Equal(dispatcher.Deliveries(DeliveryPending)[0].ID))
+		Ω(pending[0].NextAttempt.Equal(now.Add(time.Second))).Should(BeTrue())
+
+		Ω(ioutil.WriteFile(filepath.Join(dir, "corrupt.queue"), []byte("{"), 0600)).Should(Succeed())
+		_, err = NewWebhookDispatcher(filepath.Join(dir, "corrupt.queue"), nil)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should deliver the events of webhooks created through the resource endpoint", func() {
+		repository := NewMemoryRepository(WebhookResource{})
+		dispatcher.Webhooks = nil
+		dispatcher.Repository = repository
+		defer dispatcher.Subscribe(Events)()
+
+		server := martini.Classic()
+		server.Use(render.Renderer())
+		server.Use(func(c martini.Context) {
+			c.Map(TEST_SERVER_INFO)
+		})
+		ServeWebhooks(server, "/v1/webhooks", repository)
+
+		post := func(body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest("POST", "/v1/webhooks", bytes.NewBufferString(body))
+			server.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		recorder := post(`{"data": {"type": "webhooks", "attributes": {"url": "ftp://example.com"}}}`)
+		Ω(recorder.Code).Should(Equal(422))
+		Ω(recorder.Body.String()).Should(ContainSubstring("/data/attributes/url"))
+
+		recorder = post(`{"data": {"type": "webhooks", "attributes": {"url": "` + receiver.URL + `", "secret": "secret", "types": ["lots"], "actions": ["create"]}}}`)
+		Ω(recorder.Code).Should(Equal(201))
+		Ω(recorder.Body.String()).ShouldNot(ContainSubstring("secret"))
+
+		recorder = httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/v1/webhooks", nil)
+		server.ServeHTTP(recorder, request)
+		Ω(recorder.Code).Should(Equal(200))
+		Ω(recorder.Body.String()).Should(ContainSubstring(receiver.URL))
+		Ω(recorder.Body.String()).ShouldNot(ContainSubstring("secret"))
+
+		lot := LotResource{Name: "North"}
+		lot.SetID("lot-1")
+		Ω(CreateWithHooks(TEST_SERVER_INFO, &lot, nil, func() *JsonApiError { return nil })).Should(BeNil())
+		Ω(DeleteWithHooks(TEST_SERVER_INFO, &lot, nil, func() *JsonApiError { return nil })).Should(BeNil())
+
+		delivered, err := dispatcher.Deliver()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(delivered).Should(Equal(1))
+		Ω(received[0].Header.Get(WEBHOOK_SIGNATURE_HEADER)).Should(Equal(SignWebhook("secret", bodies[0])))
+		Ω(string(bodies[0])).Should(ContainSubstring(`"action":"create"`))
+
+		recorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("DELETE", "/v1/webhooks/1", nil)
+		server.ServeHTTP(recorder, request)
+		Ω(recorder.Code).Should(Equal(204))
+		_, err = repository.FindOne("1")
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	It("should not deliver the secrets of webhooks", func() {
+		catchAll := WebhookResource{URL: receiver.URL, Secret: "other"}
+		catchAll.SetID("w2")
+		listener := WebhookResource{URL: receiver.URL, Secret: "listener", Types: []string{"webhooks"}}
+		listener.SetID("w3")
+		dispatcher.Webhooks = []WebhookResource{catchAll, listener}
+		defer dispatcher.Subscribe(Events)()
+
+		webhook := WebhookResource{URL: "https://example.com", Secret: "secret"}
+		webhook.SetID("w4")
+		Ω(CreateWithHooks(TEST_SERVER_INFO, &webhook, nil, func() *JsonApiError { return nil })).Should(BeNil())
+		Ω(dispatcher.Enqueue(Event{Type: "webhooks", ID: "w4", Action: ActionUpdate,
+			Attributes: map[string]Change{"secret": {Before: "secret", After: "rotated"}}})).Should(Succeed())
+		Ω(dispatcher.Enqueue(lotCreated)).Should(Succeed())
+
+		delivered, err := dispatcher.Deliver()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(delivered).Should(Equal(3))
+		Ω(received[0].Header.Get(WEBHOOK_SIGNATURE_HEADER)).Should(Equal(SignWebhook("listener", bodies[0])))
+		Ω(received[1].Header.Get(WEBHOOK_SIGNATURE_HEADER)).Should(Equal(SignWebhook("listener", bodies[1])))
+		Ω(received[2].Header.Get(WEBHOOK_SIGNATURE_HEADER)).Should(Equal(SignWebhook("other", bodies[2])))
+		for _, body := range bodies {
+			Ω(string(body)).ShouldNot(ContainSubstring("secret"))
+			Ω(string(body)).ShouldNot(ContainSubstring("rotated"))
+		}
+	})
+
+	It("should only deliver the events of the webhook's tenant", func() {
+		acme := WebhookResource{URL: receiver.URL, Secret: "acme", Types: []string{"lots"}, Tenant: "acme"}
+		acme.SetID("w2")
+		dispatcher.Webhooks = append(dispatcher.Webhooks, acme)
+		defer dispatcher.Subscribe(Events)()
+
+		jasi := TEST_SERVER_INFO
+		jasi.Tenant = "acme"
+		lot := LotResource{Name: "North"}
+		lot.SetID("lot-1")
+		Ω(CreateWithHooks(jasi, &lot, nil, func() *JsonApiError { return nil })).Should(BeNil())
+
+		delivered, err := dispatcher.Deliver()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(delivered).Should(Equal(1))
+		Ω(received[0].Header.Get(WEBHOOK_SIGNATURE_HEADER)).Should(Equal(SignWebhook("acme", bodies[0])))
+	})
+
+	It("should own the webhooks created through the resource endpoint by the request's tenant", func() {
+		repository := NewMemoryRepository(WebhookResource{})
+		server := martini.Classic()
+		server.Use(render.Renderer())
+		server.Use(func(c martini.Context, req *http.Request) {
+			jasi := TEST_SERVER_INFO
+			jasi.Tenant = req.Header.Get("X-Tenant-ID")
+			c.Map(jasi)
+		})
+		ServeWebhooks(server, "/v1/webhooks", repository)
+
+		serve := func(method string, path string, tenant string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+			request.Header.Set("X-Tenant-ID", tenant)
+			server.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		recorder := serve("POST", "/v1/webhooks", "acme", `{"data": {"type": "webhooks", "attributes": {"url": "`+receiver.URL+`", "types": ["drivers"]}}}`)
+		Ω(recorder.Code).Should(Equal(201))
+		record, err := repository.FindOne("1")
+		Ω(err).Should(BeNil())
+		Ω(record.(*WebhookResource).Tenant).Should(Equal("acme"))
+
+		Ω(serve("GET", "/v1/webhooks/1", "acme", "").Code).Should(Equal(200))
+		Ω(serve("GET", "/v1/webhooks/1", "other", "").Code).Should(Equal(404))
+		Ω(serve("DELETE", "/v1/webhooks/1", "other", "").Code).Should(Equal(404))
+		Ω(serve("GET", "/v1/webhooks", "other", "").Body.String()).ShouldNot(ContainSubstring(receiver.URL))
+	})
+
+	It("should deliver the due deliveries in the background", func() {
+		Ω(dispatcher.Enqueue(lotCreated)).Should(Succeed())
+		stop := dispatcher.Start(time.Millisecond)
+		Eventually(func() int { return len(dispatcher.Deliveries(DeliveryPending)) }).Should(Equal(0))
+		stop()
+		Ω(received).Should(HaveLen(1))
+	})
+})